@@ -0,0 +1,70 @@
+package oracle
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// archiveTableName returns the name a table owned by the user is archived
+// under. Archived tables of all users share the archive schema, so the name
+// ends in a hash of the user and table, and the table name is shortened to
+// leave room for it.
+func archiveTableName(username, table string, maxLength int) string {
+	sum := sha256.Sum256([]byte(username + "." + table))
+	suffix := "$" + strings.ToUpper(hex.EncodeToString(sum[:4]))
+	if len(table) > maxLength-len(suffix) {
+		table = table[:maxLength-len(suffix)]
+	}
+	return table + suffix
+}
+
+// archiveTables copies the tables owned by a user into the archive schema
+// with CREATE TABLE AS SELECT, so that their data is kept when the user is
+// dropped. Each archived table is commented with where it came from. Tables
+// archived by an earlier attempt at revocation are left as they are.
+func archiveTables(tx *sql.Tx, username string, quoted bool, archiveSchema string, maxLength int) ([]string, error) {
+	owner := storedUsername(username, quoted)
+
+	rows, err := tx.Query(fmt.Sprintf(ownedTablesSQL, quoteLiteral(owner)))
+	if err != nil {
+		return nil, fmt.Errorf("could not list tables to archive: %s", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []string
+	for _, table := range tables {
+		name := archiveTableName(owner, table, maxLength)
+		_, err := tx.Exec(fmt.Sprintf(archiveTableSQL,
+			archiveSchema, quoteIdentifier(name), quoteIdentifier(owner), quoteIdentifier(table)))
+		if err != nil {
+			if strings.Contains(err.Error(), "ORA-00955") {
+				continue
+			}
+			return nil, fmt.Errorf("could not archive table %s: %s", table, err)
+		}
+		comment := fmt.Sprintf("Archived from %s.%s on %s", owner, table, time.Now().UTC().Format(time.RFC3339))
+		if _, err := tx.Exec(fmt.Sprintf(archiveCommentSQL,
+			archiveSchema, quoteIdentifier(name), quoteLiteral(comment))); err != nil {
+			return nil, err
+		}
+		archived = append(archived, archiveSchema+"."+name)
+	}
+	return archived, nil
+}
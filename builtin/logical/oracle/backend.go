@@ -0,0 +1,435 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	log "github.com/mgutz/logxi/v1"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend(conf).Setup(conf)
+}
+
+func Backend(conf *logical.BackendConfig) *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			pathConfigConnection(&b),
+			pathConfigLease(&b),
+			pathConfigUsername(&b),
+			pathConfigRevocation(&b),
+			pathListRoles(&b),
+			pathListRoleSummaries(&b),
+			pathRoles(&b),
+			pathRoleRollback(&b),
+			pathRoleRevokeAll(&b),
+			pathRoleCreate(&b),
+			pathCredsVerify(&b),
+			pathListProxyRoles(&b),
+			pathProxyRoles(&b),
+			pathProxyCreds(&b),
+			pathListPendingDrops(&b),
+			pathTidy(&b),
+			pathPendingDrops(&b),
+			pathListRevocationFailures(&b),
+			pathRevocationFailures(&b),
+			pathRevocationPlan(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
+			pathStaticCreds(&b),
+		},
+
+		Secrets: []*framework.Secret{
+			secretCreds(&b),
+			secretProxyCreds(&b),
+		},
+
+		Clean: b.ResetDB,
+
+		PeriodicFunc: b.periodicFunc,
+
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: 5 * time.Minute,
+
+		Invalidate: b.invalidate,
+	}
+
+	b.roleLocks = locksutil.CreateLocks()
+	b.accountLocks = locksutil.CreateLocks()
+	b.idempotencyLocks = locksutil.CreateLocks()
+	b.poolLocks = locksutil.CreateLocks()
+	b.logger = conf.Logger
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	db   *sql.DB
+	lock sync.Mutex
+
+	// usernameLength is the length of generated usernames, as configured on
+	// the connection and limited by what the database accepts
+	usernameLength int
+
+	// dbName is the name of the database connected to, if it could be
+	// determined
+	dbName string
+
+	// connConfig is the configuration the current connection was made with
+	connConfig *connectionConfig
+
+	// roleLocks serialize credential creation for roles that require it
+	roleLocks []*locksutil.LockEntry
+
+	// accountLocks serialize the issuance and revocation of each service
+	// account
+	accountLocks []*locksutil.LockEntry
+
+	// idempotencyLocks serialize requests for credentials with the same
+	// idempotency token
+	idempotencyLocks []*locksutil.LockEntry
+
+	// limiter enforces the rate limits of roles
+	limiter issuanceLimiter
+
+	// poolLocks serialize taking credentials from each role's pool with
+	// draining it
+	poolLocks []*locksutil.LockEntry
+
+	// poolFilling records the roles whose pools are being filled, so that
+	// only one fill runs for each
+	poolFilling     map[string]bool
+	poolFillingLock sync.Mutex
+
+	// sessionBatches coalesces the session lookups of concurrent
+	// revocations
+	sessionBatches batcher
+
+	// dropBatches coalesces the drops of the users of concurrent
+	// revocations
+	dropBatches batcher
+
+	// staticRotations schedules the rotation of static roles
+	staticRotations staticRotationQueue
+
+	logger log.Logger
+}
+
+// DB returns the database connection.
+func (b *backend) DB(s logical.Storage) (*sql.DB, error) {
+	b.logger.Trace("oracle/db: enter")
+	defer b.logger.Trace("oracle/db: exit")
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// If we already have a DB, we got it!
+	if b.db != nil {
+		if err := b.db.Ping(); err == nil {
+			return b.db, nil
+		}
+		// If the ping was unsuccessful, close it and ignore errors as we'll be
+		// reestablishing anyways
+		b.db.Close()
+	}
+
+	// Otherwise, attempt to make connection
+	entry, err := s.Get("config/connection")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil,
+			fmt.Errorf("configure the DB connection with config/connection first")
+	}
+
+	var connConfig connectionConfig
+	if err := entry.DecodeJSON(&connConfig); err != nil {
+		return nil, err
+	}
+
+	b.db, err = sql.Open(oracleDriverName, connConfig.ConnectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set some connection pool settings. We don't need much of this,
+	// since the request rate shouldn't be high.
+	b.db.SetMaxOpenConns(connConfig.MaxOpenConnections)
+	b.db.SetMaxIdleConns(connConfig.MaxIdleConnections)
+
+	b.connConfig = &connConfig
+
+	// Generated usernames can't be longer than the database allows. If the
+	// limit can't be determined, assume the one of older versions.
+	b.usernameLength = connConfig.UsernameLength
+	if b.usernameLength == 0 {
+		b.usernameLength = oracleUsernameLength
+	}
+	maxLength, err := detectUsernameLength(b.db)
+	if err != nil {
+		b.logger.Warn("oracle/db: could not detect username length limit", "error", err)
+		maxLength = oracleUsernameLength
+	}
+	if b.usernameLength > maxLength {
+		b.logger.Warn("oracle/db: username_length exceeds what the database accepts", "username_length", b.usernameLength, "limit", maxLength)
+		b.usernameLength = maxLength
+	}
+
+	b.dbName, err = detectDBName(b.db)
+	if err != nil {
+		b.logger.Warn("oracle/db: could not detect database name", "error", err)
+	}
+
+	return b.db, nil
+}
+
+// UsernameLength returns the length of generated usernames. It is only set
+// once DB() has been called.
+func (b *backend) UsernameLength() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.usernameLength == 0 {
+		return oracleUsernameLength
+	}
+	return b.usernameLength
+}
+
+// ResetDB forces a connection next time DB() is called.
+func (b *backend) ResetDB() {
+	b.logger.Trace("oracle/resetdb: enter")
+	defer b.logger.Trace("oracle/resetdb: exit")
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.db != nil {
+		b.db.Close()
+	}
+
+	b.db = nil
+	b.usernameLength = 0
+	b.dbName = ""
+	b.connConfig = nil
+}
+
+// DBName returns the name of the database connected to, or an empty string
+// if it couldn't be determined. It is only set once DB() has been called.
+func (b *backend) DBName() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.dbName
+}
+
+// PasswordMode returns the default password generation mode for roles. It
+// is only set once DB() has been called.
+func (b *backend) PasswordMode() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.connConfig == nil || b.connConfig.PasswordMode == "" {
+		return passwordModeUUID
+	}
+	return b.connConfig.PasswordMode
+}
+
+// ConnectString returns the address of the database, as used by the current
+// connection but without its credentials. It is only set once DB() has been
+// called.
+func (b *backend) ConnectString() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.connConfig == nil {
+		return ""
+	}
+	return connectString(b.connConfig.ConnectionURL)
+}
+
+// TLSClientConfig returns whether credentials include the client
+// configuration for connecting over TLS, and the wallet location to use in
+// it. It is only set once DB() has been called.
+func (b *backend) TLSClientConfig() (bool, string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.connConfig == nil {
+		return false, ""
+	}
+	return b.connConfig.TLSClientConfig, b.connConfig.WalletLocation
+}
+
+// TrackingTable returns the table issuances of credentials are recorded in,
+// if any. It is only set once DB() has been called.
+func (b *backend) TrackingTable() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.connConfig == nil {
+		return ""
+	}
+	return b.connConfig.TrackingTable
+}
+
+// periodicFunc tidies up expired idempotency records, rotates the static
+// roles that are due, and tops up the pools of roles that have one, such as
+// after a restart or a failed fill. A failing step doesn't keep the others
+// from running, nor does a role that can't be read keep the pools of the
+// rest from being filled; the errors are all returned together.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	var result error
+	if err := b.tidyIdempotency(req); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := b.dropPendingUsers(req.Storage); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := b.rotateDueStaticRoles(req.Storage); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	roles, err := req.Storage.List("role/")
+	if err != nil {
+		return multierror.Append(result, err)
+	}
+	for _, name := range roles {
+		role, err := b.Role(req.Storage, name)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("failed to read role %s: %s", name, err))
+			continue
+		}
+		if role != nil && role.PoolSize > 0 {
+			b.fillPool(req.Storage, req.MountPoint, name)
+		}
+	}
+	return result
+}
+
+// leaseTTL returns the lease duration credentials are issued and renewed
+// with. Without one in config/lease, the default TTL set there is used, or
+// else the backend default, or the mount's default if that is shorter,
+// rather than leaving leases to the mount, whose default is usually the
+// system default of 32 days. It also returns whether a default TTL was
+// fallen back on.
+func (b *backend) leaseTTL(lease *configLease) (time.Duration, bool) {
+	if lease != nil && lease.Lease > 0 {
+		return lease.Lease, false
+	}
+	if lease != nil && lease.DefaultTTL > 0 {
+		return lease.DefaultTTL, true
+	}
+	ttl := defaultTTL
+	if mountTTL := b.System().DefaultLeaseTTL(); mountTTL > 0 && mountTTL < ttl {
+		ttl = mountTTL
+	}
+	return ttl, true
+}
+
+// defaultTTLWarning is the warning returned with credentials issued with the
+// default lease duration, since it may not be what the operator intended.
+func defaultTTLWarning(ttl time.Duration) string {
+	return fmt.Sprintf("No lease is configured in config/lease, so the default TTL of %s was used", ttl)
+}
+
+func (b *backend) invalidate(key string) {
+	switch {
+	case key == "config/connection":
+		b.ResetDB()
+	case strings.HasPrefix(key, "static-role/"):
+		b.staticRotations.invalidate()
+	}
+}
+
+// Lease returns the lease information
+func (b *backend) Lease(s logical.Storage) (*configLease, error) {
+	entry, err := s.Get("config/lease")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result configLease
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ConnectionConfig returns the connection configuration as stored, without
+// connecting to the database
+func (b *backend) ConnectionConfig(s logical.Storage) (*connectionConfig, error) {
+	entry, err := s.Get("config/connection")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result connectionConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RevocationConfig returns the default revocation configuration
+func (b *backend) RevocationConfig(s logical.Storage) (*revocationConfig, error) {
+	entry, err := s.Get("config/revocation")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result revocationConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UsernameConfig returns the configuration of generated usernames
+func (b *backend) UsernameConfig(s logical.Storage) (*usernameConfig, error) {
+	entry, err := s.Get("config/username")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result usernameConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+const backendHelp = `
+The Oracle backend dynamically generates database users.
+
+After mounting this backend, configure it using the endpoints within
+the "config/" path.
+`
@@ -0,0 +1,3041 @@
+package oracle
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/hashicorp/vault/logical"
+	logicaltest "github.com/hashicorp/vault/logical/testing"
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestBackend_basic(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		AcceptanceTest: true,
+		PreCheck:       func() { testAccPreCheck(t) },
+		Backend:        b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			testAccStepCreateRole(t, "web", testRole),
+			testAccStepReadCreds(t, "web"),
+		},
+	})
+}
+
+func TestBackend_roleCrud(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		AcceptanceTest: true,
+		PreCheck:       func() { testAccPreCheck(t) },
+		Backend:        b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			testAccStepCreateRole(t, "web", testRole),
+			testAccStepReadRole(t, "web", testRole),
+			testAccStepDeleteRole(t, "web"),
+			testAccStepReadRole(t, "web", ""),
+		},
+	})
+}
+
+func TestBackend_accountOptions(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		AcceptanceTest: true,
+		PreCheck:       func() { testAccPreCheck(t) },
+		Backend:        b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfig(t),
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "roles/web",
+				Data: map[string]interface{}{
+					"sql":            testRole,
+					"quotas":         map[string]interface{}{"USERS": "10M"},
+					"account_unlock": true,
+				},
+			},
+			testAccStepReadCreds(t, "web"),
+		},
+	})
+}
+
+func TestBackend_identificationValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []map[string]interface{}{
+		{"sql": testRole, "identification": "token"},
+		{"sql": testRole, "external_name": "{{name}}@EXAMPLE.COM"},
+		{"sql": testRole, "identification": "external"},
+		{"sql": testExternalRole, "identification": "external", "external_name": "{{name}}'"},
+		{"sql": testExternalRole, "identification": "external", "global_dn": "cn={{name}}"},
+		{"sql": testGlobalRole, "identification": "global"},
+		{"sql": testRole, "identification": "global", "global_dn": "cn={{name}}"},
+		{"sql": testExternalRole, "identification": "external", "verify_login": true},
+		{"sql": testRole, "identified_by_values": true},
+		{"sql": testValuesRole},
+		{"sql": testExternalRole, "identification": "external", "identified_by_values": true},
+	}
+	for _, data := range invalid {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/web",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+}
+
+func TestBackend_archiveSchemaValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"sql": testRole, "archive_schema": "archive;"},
+		{"sql": testRole, "archive_schema": "archive", "revocation_mode": "lock"},
+		{"sql": testRole, "archive_schema": "archive", "revocation_sql": "DROP USER {{name}}"},
+		{"sql": testRole, "archive_schema": "archive", "service_account": true},
+		{"sql": testRole, "archive_schema": "archive", "expiry_enforcement": "drop"},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/web",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+}
+
+func TestBackend_pendingDrops(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"sql": testRole, "drop_grace_period": "-1s"},
+		{"sql": testRole, "drop_grace_period": "1h", "revocation_mode": "lock"},
+		{"sql": testRole, "drop_grace_period": "1h", "expiry_enforcement": "drop"},
+		{"sql": testRole, "drop_when_drained": true},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/web",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+
+	internalData := map[string]interface{}{"username": "V_WEB_1234", "role": "web"}
+	if err := b.deferDrop(config.StorageView, "V_WEB_1234", internalData, time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users aren't dropped before their grace period has passed
+	if err := b.dropPendingUsers(config.StorageView); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "pending-drops/",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "V_WEB_1234" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "pending-drops/V_WEB_1234",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["role"] != "web" || resp.Data["drop_at"] == "" || resp.Data["when_drained"] != false {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Deleting it cancels the drop
+	if _, err := b.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "pending-drops/V_WEB_1234",
+		Storage:   config.StorageView,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := b.pendingDrop(config.StorageView, "V_WEB_1234"); err != nil || pending != nil {
+		t.Fatalf("expected drop to be cancelled, got %#v, %v", pending, err)
+	}
+}
+
+func TestBackend_tidyValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"username_pattern": `V\_%`, "container": "pdb1;"},
+		{"username_pattern": `V\_%`, "min_age": "-1h"},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "tidy",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+}
+
+func TestTidyUsersSQL(t *testing.T) {
+	expected := `SELECT username FROM dba_users WHERE username LIKE 'V\_%' ESCAPE '\' AND account_status NOT LIKE '%LOCKED%' AND created < SYSDATE - 3600/86400 ORDER BY username`
+	if actual := fmt.Sprintf(tidyUsersSQL, quoteLiteral(`V\_%`), 3600); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestArchiveTableName(t *testing.T) {
+	name := archiveTableName("V_WEB_1234", "ORDERS", oracleUsernameLength)
+	if !strings.HasPrefix(name, "ORDERS$") || len(name) != len("ORDERS$")+8 {
+		t.Fatalf("bad: %q", name)
+	}
+	if other := archiveTableName("V_WEB_5678", "ORDERS", oracleUsernameLength); other == name {
+		t.Fatalf("archived tables of different users share a name: %q", name)
+	}
+
+	long := archiveTableName("V_WEB_1234", strings.Repeat("A", oracleUsernameLength), oracleUsernameLength)
+	if len(long) != oracleUsernameLength || !strings.HasPrefix(long, strings.Repeat("A", oracleUsernameLength-9)+"$") {
+		t.Fatalf("bad: %q", long)
+	}
+}
+
+func TestBackend_listDetailed(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roles are stored directly since writing them requires a database
+	lastIssued := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for key, value := range map[string]interface{}{
+		"role/web":     &roleEntry{SQL: testRole},
+		"role/batch":   &roleEntry{SQL: testExternalRole, Identification: identificationExternal, Container: "PDB1"},
+		"issuance/web": &roleIssuance{LastIssued: lastIssued},
+		"config/lease": &configLease{Lease: time.Hour, LeaseMax: 24 * time.Hour},
+	} {
+		entry, err := logical.StorageEntryJSON(key, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.StorageView.Put(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role-summaries/",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"web": map[string]interface{}{
+			"identification": identificationPassword,
+			"container":      "",
+			"db_name":        "",
+			"lease":          "1h0m0s",
+			"lease_max":      "24h0m0s",
+			"last_issued":    "2017-06-01T12:00:00Z",
+		},
+		"batch": map[string]interface{}{
+			"identification": identificationExternal,
+			"container":      "PDB1",
+			"db_name":        "PDB1",
+			"lease":          "1h0m0s",
+			"lease_max":      "24h0m0s",
+			"last_issued":    "",
+		},
+	}
+	if actual := resp.Data["key_info"]; !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+
+	// Without config/lease, the lease credentials are issued with is shown
+	if err := config.StorageView.Delete("config/lease"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role-summaries/",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := resp.Data["key_info"].(map[string]interface{})["web"].(map[string]interface{})
+	if summary["lease"] != defaultTTL.String() {
+		t.Fatalf("bad: %#v", summary)
+	}
+}
+
+func TestBackend_roleRollback(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Roles are stored directly since writing them requires a database
+	for _, sql := range []string{"first", "second", "third"} {
+		if err := b.putRole(config.StorageView, "web", &roleEntry{SQL: sql}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readRole := func() *roleEntry {
+		role, err := b.Role(config.StorageView, "web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return role
+	}
+	rollback := func(data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/web/rollback",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if role := readRole(); role.Version != 3 || role.SQL != "third" {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	if resp := rollback(nil); resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if role := readRole(); role.Version != 4 || role.SQL != "second" {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	if resp := rollback(map[string]interface{}{"version": 1}); resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if role := readRole(); role.Version != 5 || role.SQL != "first" {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	if resp := rollback(map[string]interface{}{"version": 5}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error rolling back to the current version, got %#v", resp)
+	}
+}
+
+func TestBackend_requireReason(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Roles are stored directly since writing them requires a database
+	err := b.putRole(config.StorageView, "web", &roleEntry{
+		SQL:           testRole,
+		RequireReason: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/web",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response without a reason, got %#v", resp)
+	}
+
+	if actual := quoteLiteral("it's CHG-1234"); actual != "it''s CHG-1234" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestBackend_credsVerify(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"creds/web/verify", "creds/missing/verify"} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data: map[string]interface{}{
+				"username": "WEB_8D8E4A4B",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for unknown role, got %#v", resp)
+		}
+	}
+
+	if err := b.putRole(config.StorageView, "web", &roleEntry{SQL: testRole}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "creds/web/verify",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response without a username, got %#v", resp)
+	}
+}
+
+func TestRevocationError(t *testing.T) {
+	progress := &revocationProgress{}
+	err := progress.wrap(errors.New("ORA-01031: insufficient privileges"))
+	expected := "ORA-01031: insufficient privileges (after killing 0 sessions and running no statements)"
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+
+	progress.SessionsKilled = 2
+	progress.ran(`REVOKE CONNECT FROM V_WEB_1234`)
+	err = progress.wrap(errors.New("ORA-03113: end-of-file on communication channel"))
+	expected = "ORA-03113: end-of-file on communication channel (after killing 2 sessions and running 1 statements: REVOKE CONNECT FROM V_WEB_1234)"
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// Failures are still queued for retry when the database is unreachable
+	if !isTransientError(err) {
+		t.Fatalf("expected %q to be transient", err)
+	}
+}
+
+func TestSessionQuery(t *testing.T) {
+	query, args := sessionQuery(sessionQuerySQL, []string{"V_WEB_1234"}, "")
+	if query != `SELECT sid, serial#, inst_id, username, server FROM gv$session WHERE username IN (:1)` ||
+		!reflect.DeepEqual(args, []interface{}{"V_WEB_1234"}) {
+		t.Fatalf("bad: %s %#v", query, args)
+	}
+
+	query, args = sessionQuery(sessionQuerySQL, []string{"V_WEB_1234", "web_1234"}, "pdb1")
+	expected := `SELECT sid, serial#, inst_id, username, server FROM gv$session WHERE username IN (:1, :2)` +
+		` AND con_id = (SELECT con_id FROM v$containers WHERE name = UPPER(:3))`
+	if query != expected || !reflect.DeepEqual(args, []interface{}{"V_WEB_1234", "web_1234", "pdb1"}) {
+		t.Fatalf("bad: %s %#v", query, args)
+	}
+
+	// Usernames are bound and matched exactly, never substituted into the
+	// query or matched by pattern
+	if strings.Contains(query, "'") || strings.Contains(strings.ToUpper(query), "LIKE") {
+		t.Fatalf("bad: %s", query)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	var l batcher
+	var lock sync.Mutex
+	var batches [][]string
+	release := make(chan struct{})
+	query := func(usernames []string) (map[string][]oracleSession, error) {
+		lock.Lock()
+		batches = append(batches, usernames)
+		first := len(batches) == 1
+		lock.Unlock()
+		if first {
+			<-release
+		}
+		sessions := make(map[string][]oracleSession)
+		for i, username := range usernames {
+			sessions[username] = []oracleSession{{SID: i, Serial: 1, Instance: 1}}
+		}
+		return sessions, nil
+	}
+	waitFor := func(done func() bool) {
+		for deadline := time.Now().Add(5 * time.Second); !done(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out")
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]oracleSession, 10)
+	lookup := func(i int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sessions, err := l.lookupSessions(sessionQuerySQL, "PDB1", fmt.Sprintf("V_WEB_%d", i), query)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = sessions
+		}()
+	}
+
+	// A lookup with none other in flight runs at once
+	lookup(0)
+	waitFor(func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(batches) == 1
+	})
+
+	// Lookups made while it runs share the next query, and each gets the
+	// sessions of its own user only
+	for i := 1; i < len(results); i++ {
+		lookup(i)
+	}
+	waitFor(func() bool {
+		l.Lock()
+		defer l.Unlock()
+		queue := l.queues[sessionQuerySQL+"\x00PDB1"]
+		return len(queue) == 1 && len(queue[0].items) == len(results)-1
+	})
+	close(release)
+	wg.Wait()
+
+	if len(batches) != 2 || len(batches[0]) != 1 || len(batches[1]) != len(results)-1 {
+		t.Fatalf("bad: %#v", batches)
+	}
+	for i, sessions := range results {
+		if len(sessions) != 1 {
+			t.Fatalf("bad: sessions of V_WEB_%d: %#v", i, sessions)
+		}
+	}
+
+	// Errors are returned to every lookup of the batch
+	_, err := l.lookupSessions(sessionQuerySQL, "", "V_WEB_1", func([]string) (map[string][]oracleSession, error) {
+		return nil, errors.New("ORA-00942: table or view does not exist")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDropUsersBlock(t *testing.T) {
+	block := dropUsersBlock([]string{`DROP USER "v_web_1234"`, "DROP USER V_WEB_5678 CASCADE"})
+	for _, call := range []string{
+		"  drop_user('DROP USER \"v_web_1234\"');\n",
+		"  drop_user('DROP USER V_WEB_5678 CASCADE');\n",
+	} {
+		if !strings.Contains(block, call) {
+			t.Fatalf("expected %q in %s", call, block)
+		}
+	}
+}
+
+func TestKillSessionsBlock(t *testing.T) {
+	block := killSessionsBlock([]string{
+		sessionKillStatement(sessionKillSQL, "V_WEB_1234", oracleSession{SID: 12, Serial: 345, Instance: 1}),
+		sessionKillStatement(sessionKillSQL, "V_WEB_1234", oracleSession{SID: 67, Serial: 890, Instance: 2}),
+	})
+	for _, call := range []string{
+		"  kill('ALTER SYSTEM KILL SESSION ''12,345,@1'' IMMEDIATE');\n",
+		"  kill('ALTER SYSTEM KILL SESSION ''67,890,@2'' IMMEDIATE');\n",
+	} {
+		if !strings.Contains(block, call) {
+			t.Fatalf("expected %q in %s", call, block)
+		}
+	}
+}
+
+func TestBackend_issuedUserStoredUsername(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Issued users are found by tidy under their name in DBA_USERS, where
+	// only quoted usernames keep their case
+	for _, c := range []struct {
+		username string
+		quoted   bool
+		key      string
+	}{
+		{"v_web_1234", false, "user/V_WEB_1234"},
+		{"v_web_1234", true, "user/v_web_1234"},
+	} {
+		if err := b.putIssuedUser(config.StorageView, c.username, c.quoted, "web", SecretCredsType); err != nil {
+			t.Fatal(err)
+		}
+		entry, err := config.StorageView.Get(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil {
+			t.Fatalf("expected %q to be recorded under %s", c.username, c.key)
+		}
+
+		b.deleteIssuedUser(config.StorageView, c.username, c.quoted)
+		entry, err = config.StorageView.Get(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			t.Fatalf("expected %s to be deleted", c.key)
+		}
+	}
+}
+
+func TestBackend_renewUsesCurrentLease(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	renew := func(maxTTL time.Duration) *logical.Response {
+		secret := &logical.Secret{
+			InternalData: map[string]interface{}{
+				"username": "V_WEB_1234",
+				"max_ttl":  int64(maxTTL / time.Second),
+			},
+		}
+		secret.IssueTime = time.Now()
+		resp, err := b.secretCredsRenew(&logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   config.StorageView,
+			Secret:    secret,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	entry, err := logical.StorageEntryJSON("config/lease", &configLease{Lease: 2 * time.Hour, LeaseMax: 12 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lease changed since issuance is used, but a longer max TTL doesn't
+	// extend leases issued before it
+	resp := renew(time.Hour)
+	if resp.Secret.TTL > time.Hour || len(resp.Warnings) != 1 {
+		t.Fatalf("bad: %s %#v", resp.Secret.TTL, resp.Warnings)
+	}
+	resp = renew(24 * time.Hour)
+	if resp.Secret.TTL != 2*time.Hour {
+		t.Fatalf("bad: %s", resp.Secret.TTL)
+	}
+
+	// A max TTL shortened since issuance applies, with a warning
+	entry, err = logical.StorageEntryJSON("config/lease", &configLease{Lease: 2 * time.Hour, LeaseMax: 30 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+	resp = renew(24 * time.Hour)
+	if resp.Secret.TTL > 30*time.Minute {
+		t.Fatalf("bad: %s", resp.Secret.TTL)
+	}
+	if warnings := resp.Warnings; len(warnings) != 2 || !strings.Contains(warnings[1], "reduced from 24h0m0s to 30m0s") {
+		t.Fatalf("bad: %#v", warnings)
+	}
+}
+
+func TestBackend_revokeAll(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the lease data, users can't be revoked from here. Users of
+	// other roles and of proxy roles are left alone.
+	for _, user := range []struct {
+		username   string
+		role       string
+		secretType string
+	}{
+		{"V_WEB_1", "web", SecretCredsType},
+		{"V_API_1", "api", SecretCredsType},
+		{"V_WEB_2", "web", SecretProxyCredsType},
+	} {
+		if err := b.putIssuedUser(config.StorageView, user.username, false, user.role, user.secretType); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "roles/web/revoke-all",
+		Storage:    config.StorageView,
+		MountPoint: "oracle/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if revoked := resp.Data["revoked"].([]string); len(revoked) != 0 {
+		t.Fatalf("bad: %#v", revoked)
+	}
+	if skipped := resp.Data["skipped"].([]string); !reflect.DeepEqual(skipped, []string{"V_WEB_1"}) {
+		t.Fatalf("bad: %#v", skipped)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "sys/revoke-prefix/oracle/creds/web") {
+		t.Fatalf("bad: %#v", resp.Warnings)
+	}
+}
+
+func TestBackend_revocationPlan(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.putRole(config.StorageView, "web", &roleEntry{DropGracePeriod: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/revocation",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"revocation_package": "vault_admin.revoke"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	internalData := map[string]interface{}{
+		"username":        "V_WEB_1234",
+		"role":            "web",
+		"container":       "PDB1",
+		"service_trigger": "VAULT_SVC_1",
+		"quoted_username": false,
+	}
+	if err := b.putIssuedUserData(config.StorageView, "V_WEB_1234", false, "web", internalData); err != nil {
+		t.Fatal(err)
+	}
+
+	// Within the grace period the user is only locked
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "revocation-plan/V_WEB_1234",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	expected := []map[string]interface{}{
+		{"sql": "ALTER SESSION SET CONTAINER = PDB1", "binds": []interface{}{}},
+		{"sql": "ALTER USER V_WEB_1234 ACCOUNT LOCK PASSWORD EXPIRE", "binds": []interface{}{}},
+	}
+	if !reflect.DeepEqual(resp.Data["statements"], expected) || len(resp.Warnings) != 1 {
+		t.Fatalf("bad: %#v %#v", resp.Data, resp.Warnings)
+	}
+	sessions := resp.Data["sessions"].(map[string]interface{})
+	if sessions["sql"] != "BEGIN VAULT_ADMIN.REVOKE.KILL_SESSIONS(:1, :2, :3); END;" ||
+		!reflect.DeepEqual(sessions["binds"], []interface{}{"V_WEB_1234", "PDB1", 0}) {
+		t.Fatalf("bad: %#v", sessions)
+	}
+
+	// Dropped right away, the user is dropped through the revocation package
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revocation-plan/V_WEB_1234",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"drop_now": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []map[string]interface{}{
+		{"sql": "ALTER SESSION SET CONTAINER = PDB1", "binds": []interface{}{}},
+		{"sql": "DROP TRIGGER VAULT_SVC_1", "binds": []interface{}{}},
+		{"sql": "REVOKE CONNECT FROM V_WEB_1234", "binds": []interface{}{}},
+		{"sql": "BEGIN VAULT_ADMIN.REVOKE.DROP_USER(:1, :2); END;", "binds": []interface{}{"V_WEB_1234", 0}},
+	}
+	if !reflect.DeepEqual(resp.Data["statements"], expected) {
+		t.Fatalf("bad: %#v", resp.Data["statements"])
+	}
+
+	// Nothing is revoked by planning
+	user, err := b.issuedUser(config.StorageView, "V_WEB_1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil {
+		t.Fatal("expected issued user to be kept")
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "revocation-plan/V_API_1234",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got %#v", resp)
+	}
+}
+
+func TestCheckIssuingDatabase(t *testing.T) {
+	for _, c := range []struct {
+		issued  interface{}
+		current string
+		valid   bool
+	}{
+		{"ORCL", "ORCL", true},
+		{"ORCL", "orcl", true},
+		{nil, "ORCL", true},
+		{"", "ORCL", true},
+		{"ORCL", "", true},
+		{"ORCL", "TESTDB", false},
+	} {
+		internalData := map[string]interface{}{"username": "V_WEB_1234"}
+		if c.issued != nil {
+			internalData["db_name"] = c.issued
+		}
+		err := checkIssuingDatabase(internalData, c.current)
+		if (err == nil) != c.valid {
+			t.Fatalf("bad: %v, %q: %v", c.issued, c.current, err)
+		}
+		if err != nil && isTransientError(err) {
+			t.Fatalf("expected %v not to be transient", err)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	for _, c := range []struct {
+		username string
+		quoted   bool
+		valid    bool
+	}{
+		{"V_WEB_1234", false, true},
+		{"web_8d8e4a4b", false, true},
+		{"web.user@example", true, true},
+		{"", false, false},
+		{"V_WEB_1234 CASCADE", false, false},
+		{"x; DROP USER SYS", false, false},
+		{`web" CASCADE; DROP USER "SYS`, true, false},
+		{strings.Repeat("A", oracleLongUsernameLength+1), false, false},
+	} {
+		if err := validateUsername(c.username, c.quoted); (err == nil) != c.valid {
+			t.Fatalf("bad: %q, quoted %t: %v", c.username, c.quoted, err)
+		}
+	}
+}
+
+func TestBackend_revokeInvalidInternalData(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, internalData := range []map[string]interface{}{
+		{"username": "V_WEB_1234 CASCADE"},
+		{"username": "V_WEB_1234", "container": "CDB$ROOT; DROP USER SYS"},
+		{"username": "V_WEB_1234", "expiry_job": "VAULT_EXPIRE', TRUE); END;--"},
+	} {
+		_, err := b.secretCredsRevoke(&logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   config.StorageView,
+			Secret:    &logical.Secret{InternalData: internalData},
+		}, nil)
+		if err == nil || !strings.Contains(err.Error(), "internal data") {
+			t.Fatalf("expected invalid internal data error for %#v, got %v", internalData, err)
+		}
+	}
+
+	// Renewal moves the expiry job, so checks it and its container too
+	for _, internalData := range []map[string]interface{}{
+		{"username": "V_WEB_1234", "expiry_job": "VAULT_EXPIRE', TRUE); END;--"},
+		{"username": "V_WEB_1234", "expiry_job": "VAULT_EXPIRE_1234", "container": "CDB$ROOT; DROP USER SYS"},
+	} {
+		_, err := b.secretCredsRenew(&logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   config.StorageView,
+			Secret:    &logical.Secret{InternalData: internalData},
+		}, nil)
+		if err == nil || !strings.Contains(err.Error(), "internal data") {
+			t.Fatalf("expected invalid internal data error for %#v, got %v", internalData, err)
+		}
+	}
+}
+
+func TestBackend_revocationFailures(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// The container is invalid, so revocation fails for good
+	internalData := map[string]interface{}{
+		"username":  "v_web_1234",
+		"role":      "web",
+		"container": "PDB1; DROP USER SYS",
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := b.secretCredsRevoke(&logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   config.StorageView,
+			Secret:    &logical.Secret{InternalData: internalData},
+		}, nil); err == nil {
+			t.Fatal("expected revocation to fail")
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "revocation-failures/",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := resp.Data["keys"].([]string); !reflect.DeepEqual(keys, []string{"V_WEB_1234"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "revocation-failures/V_WEB_1234",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["attempts"] != 2 || resp.Data["role"] != "web" ||
+		!strings.Contains(resp.Data["error"].(string), "invalid container internal data") {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	if _, err := b.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "revocation-failures/V_WEB_1234",
+		Storage:   config.StorageView,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	failure, err := b.revocationFailure(config.StorageView, "V_WEB_1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure != nil {
+		t.Fatalf("expected failure to be dismissed, got %#v", failure)
+	}
+}
+
+func TestBackend_walRollback(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &logical.Request{Storage: config.StorageView}
+	if err := b.walRollback(req, "unknown", nil); err == nil {
+		t.Fatal("expected error rolling back an unknown type")
+	}
+
+	// Rolling back a user requires a database
+	err := b.walRollback(req, walTypeUser, map[string]interface{}{
+		"username": "web_8d8e4a4b",
+		"role":     "web",
+	})
+	if err == nil || !strings.Contains(err.Error(), "config/connection") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// As does retrying a queued revocation, which is kept until it succeeds
+	err = b.walRollback(req, walTypeRevocation, map[string]interface{}{
+		"internal_data": map[string]interface{}{
+			"username": "web_8d8e4a4b",
+			"role":     "web",
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "config/connection") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Once the database is back, a revocation failing for good is recorded
+	// instead of being retried on every pass
+	db, err := sql.Open(testIdleDriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.db = db
+	internalData := map[string]interface{}{
+		"username":  "web_8d8e4a4b",
+		"role":      "web",
+		"container": "PDB1; DROP USER SYS",
+	}
+	if err := b.walRollback(req, walTypeRevocation, map[string]interface{}{
+		"internal_data": internalData,
+	}); err != nil {
+		t.Fatalf("expected the queued revocation to be cleared: %v", err)
+	}
+	failure, err := b.revocationFailure(config.StorageView, "WEB_8D8E4A4B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure == nil || !strings.Contains(failure.Error, "internal data") {
+		t.Fatalf("expected the failure to be recorded, got %#v", failure)
+	}
+
+	for _, err := range []string{
+		"ORA-04080: trigger 'VAULT_SVC_0' does not exist",
+		"ORA-01918: user 'WEB' does not exist",
+	} {
+		if !isNotExistError(errors.New(err)) {
+			t.Fatalf("bad: %q", err)
+		}
+	}
+	if isNotExistError(errors.New("ORA-01940: cannot drop a user that is currently connected")) {
+		t.Fatal("bad: ORA-01940")
+	}
+}
+
+func TestBackend_idempotency(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &logical.Request{ClientTokenAccessor: "3a2e5e6c-0f1b-9f4b-7c86-2d4a2b5e6f7a"}
+	key := idempotencyKey(req, "web", "job-42")
+	other := idempotencyKey(&logical.Request{ClientTokenAccessor: "7c862d4a"}, "web", "job-42")
+	if key == other {
+		t.Fatal("expected idempotency keys to be scoped to the Vault token")
+	}
+
+	resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
+		"username": "web_8d8e4a4b",
+	}, map[string]interface{}{
+		"username": "web_8d8e4a4b",
+	})
+	resp.Secret.TTL = time.Hour
+	if err := b.putIdempotentIssuance(config.StorageView, key, resp); err != nil {
+		t.Fatal(err)
+	}
+	issuance, err := b.idempotentIssuance(config.StorageView, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issuance == nil || issuance.Data["username"] != "web_8d8e4a4b" || issuance.TTL != time.Hour {
+		t.Fatalf("bad: %#v", issuance)
+	}
+
+	// A retry can't get back a password encrypted to a different PGP key
+	// than it gives, or none
+	encryptedKey := idempotencyKey(req, "web", "job-43")
+	resp.Data["pgp_fingerprint"] = "c9b0ea76ea8e5ba25ab3f1fb22acd7c8fe3f46b0"
+	if err := b.putIdempotentIssuance(config.StorageView, encryptedKey, resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, pgpKey := range []string{"", pgpkeys.TestPubKey1} {
+		replay, err := b.HandleRequest(&logical.Request{
+			Operation:           logical.UpdateOperation,
+			Path:                "creds/web",
+			Storage:             config.StorageView,
+			ClientTokenAccessor: req.ClientTokenAccessor,
+			Data:                map[string]interface{}{"idempotency_token": "job-43", "pgp_key": pgpKey},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replay == nil || !replay.IsError() {
+			t.Fatalf("expected an error for pgp_key %q: %#v", pgpKey, replay)
+		}
+	}
+	if err := config.StorageView.Delete(encryptedKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired entries are ignored and removed periodically
+	entry, err := logical.StorageEntryJSON(other, &idempotentIssuance{
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+	if issuance, err := b.idempotentIssuance(config.StorageView, other); err != nil || issuance != nil {
+		t.Fatalf("bad: %#v, %v", issuance, err)
+	}
+	if err := b.periodicFunc(&logical.Request{Storage: config.StorageView}); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := config.StorageView.List("idempotency/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || "idempotency/"+keys[0] != key {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
+
+func TestBackend_periodicFuncErrors(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// A pending drop and two roles that can't be decoded each fail, but
+	// neither stops the steps or roles after it from being handled
+	for _, key := range []string{pendingDropPrefix + "WEB_8D8E4A4B", "role/a", "role/b"} {
+		if err := config.StorageView.Put(&logical.StorageEntry{Key: key, Value: []byte("{")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := b.periodicFunc(&logical.Request{Storage: config.StorageView})
+	merr, ok := err.(*multierror.Error)
+	if !ok {
+		t.Fatalf("expected a multierror: %#v", err)
+	}
+	if len(merr.Errors) != 3 {
+		t.Fatalf("bad: %v", merr.Errors)
+	}
+	if !strings.Contains(merr.Errors[2].Error(), "role b") {
+		t.Fatalf("bad: %v", merr.Errors[2])
+	}
+}
+
+func TestBackend_pool(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"sql": testRole, "pool_size": -1},
+		{"sql": testRole, "pool_size": maxPoolSize + 1},
+		{"sql": testRole, "pool_size": 2, "service_account": true},
+		{"sql": testRole, "pool_size": 2, "require_reason": true},
+		{"sql": testRole, "pool_size": 2, "allowed_variables": "app"},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/web",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(poolPrefix("web")+"1", &pooledCreds{
+		Data: map[string]interface{}{
+			"username": "pool_web_8d8e4a4b",
+		},
+		InternalData: map[string]interface{}{
+			"username": "pool_web_8d8e4a4b",
+			"role":     "web",
+		},
+		TTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &logical.Request{
+		Storage:             config.StorageView,
+		ClientTokenAccessor: "3a2e5e6c",
+	}
+	resp, err := b.takePooledCreds(req, "web", "INC-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || resp.Data["username"] != "pool_web_8d8e4a4b" || resp.Secret.TTL != time.Hour {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if resp.Data["expiration"] == nil || resp.Secret.InternalData["token_accessor"] != "3a2e5e6c" ||
+		resp.Secret.InternalData["reason"] != "INC-42" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Each user is only handed out once
+	resp, err = b.takePooledCreds(req, "web", "")
+	if err != nil || resp != nil {
+		t.Fatalf("bad: %#v, %v", resp, err)
+	}
+}
+
+func TestBackend_leaseTTL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = &logical.StaticSystemView{
+		DefaultLeaseTTLVal: 768 * time.Hour,
+		MaxLeaseTTLVal:     768 * time.Hour,
+	}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, fallback := b.leaseTTL(nil); ttl != defaultTTL || !fallback {
+		t.Fatalf("bad: %s, %t", ttl, fallback)
+	}
+	if ttl, fallback := b.leaseTTL(&configLease{Lease: 5 * time.Minute}); ttl != 5*time.Minute || fallback {
+		t.Fatalf("bad: %s, %t", ttl, fallback)
+	}
+
+	// The default TTL can be configured without a lease
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/lease",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"default_ttl": "30m"},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: %#v, %v", resp, err)
+	}
+	lease, err := b.Lease(config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl, fallback := b.leaseTTL(lease); ttl != 30*time.Minute || !fallback {
+		t.Fatalf("bad: %s, %t", ttl, fallback)
+	}
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/lease",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["default_ttl"] != int64(1800) || resp.Data["lease"] != "0s" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// A shorter mount default is kept
+	config.System = &logical.StaticSystemView{
+		DefaultLeaseTTLVal: 10 * time.Minute,
+		MaxLeaseTTLVal:     768 * time.Hour,
+	}
+	b = Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, fallback := b.leaseTTL(&configLease{}); ttl != 10*time.Minute || !fallback {
+		t.Fatalf("bad: %s, %t", ttl, fallback)
+	}
+}
+
+func TestBackend_sepsConfig(t *testing.T) {
+	config := logical.TestBackendConfig()
+	b := Backend(config)
+	b.connConfig = &connectionConfig{
+		ConnectionURL:  "system/secret@db.example.com:1521/ORCLPDB1",
+		WalletLocation: "/etc/oracle/wallet",
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"username": "v_web_8d8e4a4b",
+		},
+	}
+	b.addSEPSConfig(resp, "web")
+	if resp.Data["seps_alias"] != "VAULT_WEB" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if command := resp.Data["seps_mkstore_command"]; command != "mkstore -wrl /etc/oracle/wallet -createCredential VAULT_WEB v_web_8d8e4a4b" {
+		t.Fatalf("bad: %q", command)
+	}
+	sqlnet := resp.Data["seps_sqlnet_ora"].(string)
+	if !strings.Contains(sqlnet, "SQLNET.WALLET_OVERRIDE = TRUE") || !strings.Contains(sqlnet, "/etc/oracle/wallet") {
+		t.Fatalf("bad: %q", sqlnet)
+	}
+	if !strings.HasPrefix(resp.Data["seps_tnsnames_ora"].(string), "VAULT_WEB =") {
+		t.Fatalf("bad: %q", resp.Data["seps_tnsnames_ora"])
+	}
+}
+
+func TestEncryptPassword(t *testing.T) {
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"username": "v_web_8d8e4a4b",
+			"password": "132ae3ef-5a64-7499-351e-bfe5",
+		},
+	}
+	if err := encryptPassword(resp, pgpkeys.TestPubKey1); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["password"] == "132ae3ef-5a64-7499-351e-bfe5" || resp.Data["pgp_fingerprint"] == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	decrypted, err := pgpkeys.DecryptBytes(resp.Data["password"].(string), pgpkeys.TestPrivKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.String() != "132ae3ef-5a64-7499-351e-bfe5" {
+		t.Fatalf("bad: %q", decrypted.String())
+	}
+}
+
+func TestBackend_proxyRoleValidation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"target_schema": "app;"},
+		{"target_schema": "app", "proxy_roles": "app_read,1bad"},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "proxy-roles/web",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+}
+
+func TestBackend_configRevocation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/revocation",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"revocation_sql":     "DROP USER {{name}}",
+			"revocation_package": "vault_admin.vault_revoke",
+			"drop_retry_period":  "30s",
+			"revocation_timeout": "2m",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	stored, err := b.RevocationConfig(config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.revocationSQL() != "DROP USER {{name}}" || stored.dropRetryPeriod() != 30*time.Second ||
+		stored.revocationPackage() != "VAULT_ADMIN.VAULT_REVOKE" || stored.revocationTimeout() != 2*time.Minute {
+		t.Fatalf("bad: %#v", stored)
+	}
+	if (*revocationConfig)(nil).revocationTimeout() != 0 {
+		t.Fatalf("bad default revocation timeout")
+	}
+	if (*revocationConfig)(nil).dropRetryPeriod() != defaultDropRetryPeriod {
+		t.Fatalf("bad default drop retry period")
+	}
+
+	// Configurations stored before drop_retry_period was added retry for the
+	// default period, while an explicit 0 disables retries
+	for stored, expected := range map[string]time.Duration{
+		`{"revocation_sql": "DROP USER {{name}}"}`:                         defaultDropRetryPeriod,
+		`{"revocation_sql": "DROP USER {{name}}", "drop_retry_period": 0}`: 0,
+	} {
+		if err := config.StorageView.Put(&logical.StorageEntry{
+			Key:   "config/revocation",
+			Value: []byte(stored),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := b.RevocationConfig(config.StorageView)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.dropRetryPeriod() != expected {
+			t.Fatalf("bad drop retry period for %s: %s", stored, decoded.dropRetryPeriod())
+		}
+	}
+	if (*revocationConfig)(nil).revocationPackage() != "" {
+		t.Fatalf("bad default revocation package")
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"drop_retry_period": "-1s"},
+		{"revocation_timeout": "-1s"},
+		{"revocation_package": "vault_revoke; DROP USER SYS"},
+		{"revocation_package": "a.b.c"},
+		{"session_query_sql": "SELECT sid, serial#, inst_id, username FROM gv$session"},
+		{"session_kill_sql": "ALTER SYSTEM KILL SESSION '{{sid}},{{serial}}'; DROP USER SYS"},
+	} {
+		resp, err = b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/revocation",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %#v, got %#v", data, resp)
+		}
+	}
+}
+
+func TestRevocationDeadline(t *testing.T) {
+	// Without a timeout, revocations never time out
+	if deadline := newRevocationDeadline(nil, 0); deadline != nil || deadline.check() != nil || deadline.watch(nil) != nil {
+		t.Fatalf("bad: %#v", deadline)
+	}
+
+	deadline := newRevocationDeadline(nil, time.Minute)
+	if err := deadline.check(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once it has passed, revocation fails for good rather than being queued
+	// for retry, so that it is recorded as a revocation failure
+	deadline.at = time.Now().Add(-time.Second)
+	err := deadline.check()
+	if err == nil || err.Error() != "revocation did not complete within 1m0s" {
+		t.Fatalf("bad: %v", err)
+	}
+	if isTransientError((&revocationProgress{}).wrap(err)) {
+		t.Fatalf("expected %v not to be transient", err)
+	}
+}
+
+func TestIsSessionAccessError(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected bool
+	}{
+		{&sessionLookupError{Err: fmt.Errorf("ORA-00942: table or view does not exist")}, true},
+		{&sessionLookupError{Err: fmt.Errorf("ORA-01031: insufficient privileges")}, true},
+		{&sessionLookupError{Err: fmt.Errorf("ORA-03113: end-of-file on communication channel")}, false},
+		// Failing to kill sessions once they were found still fails the
+		// revocation
+		{fmt.Errorf("ORA-01031: insufficient privileges"), false},
+	} {
+		if actual := isSessionAccessError(c.err); actual != c.expected {
+			t.Fatalf("bad: %v: expected %t", c.err, c.expected)
+		}
+	}
+}
+
+func TestProxySessionQuery(t *testing.T) {
+	// Proxied sessions are returned by proxy user, found from the audited
+	// logons, with the proxy user bound
+	query, args := sessionQuery(proxySessionQuerySQL, []string{"V_WEB_8D8E4A4B"}, "")
+	if !strings.Contains(query, "a.dbproxy_username IN (:1)") || !strings.Contains(query, "a.sessionid = s.audsid") ||
+		!reflect.DeepEqual(args, []interface{}{"V_WEB_8D8E4A4B"}) {
+		t.Fatalf("bad: %s %#v", query, args)
+	}
+	if stmt := fmt.Sprintf(proxyRevokeThroughSQL, "APP", "V_WEB_8D8E4A4B"); stmt != "ALTER USER APP REVOKE CONNECT THROUGH V_WEB_8D8E4A4B" {
+		t.Fatalf("bad: %s", stmt)
+	}
+}
+
+func TestBackend_proxyRevokeInvalidInternalData(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, internalData := range []map[string]interface{}{
+		{"username": "V_WEB_1234; DROP USER SYS"},
+		{"username": "V_WEB_1234", "target_schema": "APP REVOKE CONNECT THROUGH SYS"},
+	} {
+		_, err := b.secretProxyCredsRevoke(&logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   config.StorageView,
+			Secret:    &logical.Secret{InternalData: internalData},
+		}, nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Fatalf("expected invalid internal data error for %#v, got %v", internalData, err)
+		}
+	}
+}
+
+func TestBackend_staticRoleValidation(t *testing.T) {
+	b, s := testStaticRoleBackend(t, nil)
+	if err := b.putStaticRole(s, "app-dual", &staticRoleEntry{
+		Username:          "APP_BLUE",
+		Password:          "blue-password",
+		RotationMode:      staticRotationModeDual,
+		SecondaryUsername: "APP_GREEN",
+		SecondaryPassword: "green-password",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing a role requires a connection, so only the checks made before
+	// connecting are exercised
+	for _, c := range []struct {
+		path string
+		data map[string]interface{}
+	}{
+		// Accounts
+		{"static-roles/app", map[string]interface{}{"username": "OTHER"}},
+		{"static-roles/app-2", map[string]interface{}{"username": "app"}},
+		{"static-roles/bad", map[string]interface{}{"username": "APP; DROP USER SYS"}},
+		{"static-roles/none", map[string]interface{}{"username": ""}},
+
+		// Rotation period, schedule and window
+		{"static-roles/app", map[string]interface{}{"rotation_period": "30s"}},
+		{"static-roles/app", map[string]interface{}{"rotation_schedule": "0 2 * * *", "rotation_period": "24h"}},
+		{"static-roles/app", map[string]interface{}{"rotation_schedule": "0 2 * *"}},
+		{"static-roles/app", map[string]interface{}{"rotation_schedule": "0 0 30 2 *"}},
+		{"static-roles/app", map[string]interface{}{"rotation_window": "1h"}},
+		{"static-roles/app", map[string]interface{}{"rotation_schedule": "0 2 * * *", "rotation_window": "1m"}},
+
+		// Rotation statements
+		{"static-roles/app", map[string]interface{}{"rotation_statements": "ALTER USER {{name}} ACCOUNT UNLOCK"}},
+		{"static-roles/app", map[string]interface{}{"rotation_statements": "ALTER USER {{name}} IDENTIFIED BY \"{{password}}\"; {{unknown}}"}},
+
+		// Importing the current password
+		{"static-roles/app", map[string]interface{}{"password": "other-password"}},
+		{"static-roles/batch", map[string]interface{}{"username": "BATCH", "password": "batch-password"}},
+		{"static-roles/report", map[string]interface{}{"username": "REPORT", "skip_initial_rotation": true}},
+
+		// Rotation modes
+		{"static-roles/app", map[string]interface{}{"rotation_mode": "dual"}},
+		{"static-roles/app-dual", map[string]interface{}{"rotation_mode": "single"}},
+		{"static-roles/batch", map[string]interface{}{"username": "BATCH", "rotation_mode": "dual"}},
+		{"static-roles/report", map[string]interface{}{"username": "REPORT", "secondary_username": "REPORT_2"}},
+		{"static-roles/etl", map[string]interface{}{"username": "ETL", "rotation_mode": "dual", "secondary_username": "etl"}},
+		{"static-roles/green", map[string]interface{}{"username": "OTHER", "rotation_mode": "dual", "secondary_username": "app_green"}},
+		{"static-roles/bad", map[string]interface{}{"username": "BAD", "rotation_mode": "blue-green"}},
+
+		// Password generation
+		{"static-roles/app", map[string]interface{}{"password_mode": "random"}},
+		{"static-roles/app", map[string]interface{}{"password_length": 20}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "uuid", "password_special_chars": "!%"}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "strong", "password_length": 7}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "strong", "password_length": 31}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "strong", "password_special_chars": "!a"}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "strong", "password_special_chars": "!'"}},
+		{"static-roles/app", map[string]interface{}{"password_mode": "strong", "password_special_chars": "! "}},
+	} {
+		resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, c.path, c.data)
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error writing %s with %#v, got %#v", c.path, c.data, resp)
+		}
+	}
+}
+
+func TestBackend_staticRoles(t *testing.T) {
+	b, s := testStaticRoleBackend(t, nil)
+
+	resp := testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-roles/app", nil)
+	if _, ok := resp.Data["password"]; ok || resp.Data["username"] != "APP" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-creds/app", nil)
+	if resp.Data["username"] != "APP" || resp.Data["password"] != testStaticPassword {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Secret != nil {
+		t.Fatalf("expected no lease: %#v", resp.Secret)
+	}
+
+	resp = testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-creds/missing", nil)
+	if !resp.IsError() {
+		t.Fatalf("expected an error for an unknown static role: %#v", resp)
+	}
+}
+
+func TestStaticRotationQueue(t *testing.T) {
+	q := &staticRotationQueue{loaded: true, byName: map[string]*staticRotation{}}
+	now := time.Now()
+	q.push("c", now.Add(time.Hour))
+	q.push("b", now.Add(-time.Minute))
+	q.push("a", now.Add(-time.Hour))
+	q.push("d", now.Add(-2*time.Hour))
+	q.remove("d")
+
+	// Rescheduling a role replaces its rotation
+	q.push("c", now.Add(-30*time.Second))
+
+	if due := q.popDue(now); !reflect.DeepEqual(due, []string{"a", "b", "c"}) {
+		t.Fatalf("bad: %#v", due)
+	}
+	if due := q.popDue(now); len(due) != 0 {
+		t.Fatalf("expected nothing due: %#v", due)
+	}
+}
+
+func TestBackend_staticRoleRotationPeriod(t *testing.T) {
+	lastRotation := time.Now().Add(-2 * time.Hour).UTC()
+	b, s := testStaticRoleBackend(t, &staticRoleEntry{
+		Username:          "APP",
+		Password:          testStaticPassword,
+		LastVaultRotation: lastRotation,
+	})
+
+	resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_period": "24h"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The next rotation is scheduled from the last one, and stored so that
+	// the queue can be loaded from it
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.RotationPeriod != 24*time.Hour || !role.NextVaultRotation.Equal(lastRotation.Add(24*time.Hour)) {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	// Clients are told when the password is next rotated
+	resp = testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-creds/app", nil)
+	if resp.Data["last_vault_rotation"] != lastRotation.Format(time.RFC3339) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(int64); ttl > int64((22*time.Hour).Seconds()) || ttl < int64((22*time.Hour-time.Minute).Seconds()) {
+		t.Fatalf("bad ttl: %d", ttl)
+	}
+	if err := b.staticRotations.load(b, s); err != nil {
+		t.Fatal(err)
+	}
+	if due := b.staticRotations.popDue(lastRotation.Add(23 * time.Hour)); len(due) != 0 {
+		t.Fatalf("expected nothing due: %#v", due)
+	}
+	if due := b.staticRotations.popDue(lastRotation.Add(24 * time.Hour)); !reflect.DeepEqual(due, []string{"app"}) {
+		t.Fatalf("bad: %#v", due)
+	}
+}
+
+func TestBackend_staticRoleRotationStatements(t *testing.T) {
+	b, s := testStaticRoleBackend(t, nil)
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.rotationStatements() != staticRotationSQL {
+		t.Fatalf("bad: %s", role.rotationStatements())
+	}
+
+	// PL/SQL blocks are given as a JSON array, so that they aren't split
+	stmts := `["ALTER USER {{name}} IDENTIFIED BY \"{{password}}\"", "BEGIN DBMS_CREDENTIAL.UPDATE_CREDENTIAL('APP_CRED', 'password', '{{password}}'); END;"]`
+	resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_statements": stmts})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err = b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.rotationStatements() != stmts {
+		t.Fatalf("bad: %s", role.rotationStatements())
+	}
+	rendered, err := renderStatements(role.rotationStatements(), map[string]string{"name": "APP", "password": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != 2 || rendered[1] != "BEGIN DBMS_CREDENTIAL.UPDATE_CREDENTIAL('APP_CRED', 'password', 'secret'); END;" {
+		t.Fatalf("bad: %#v", rendered)
+	}
+}
+
+func TestBackend_staticRoleImport(t *testing.T) {
+	// An imported password that was never rotated
+	b, s := testStaticRoleBackend(t, nil)
+
+	resp := testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-creds/app", nil)
+	if resp.Data["password"] != testStaticPassword || resp.Data["last_vault_rotation"] != "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Giving it a rotation period schedules the first rotation from now
+	resp = testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_period": "1h"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(role.NextVaultRotation); until > time.Hour || until < 59*time.Minute {
+		t.Fatalf("bad next rotation: %s", role.NextVaultRotation)
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 5-2 * * *", "*/0 * * * *", "0 2 * * SUN"} {
+		if _, err := parseCronSchedule(spec); err == nil {
+			t.Fatalf("expected an error parsing %q", spec)
+		}
+	}
+
+	// 2017-06-01 was a Thursday
+	from := time.Date(2017, 6, 1, 12, 30, 15, 0, time.UTC)
+	for spec, expected := range map[string]time.Time{
+		"* * * * *":       time.Date(2017, 6, 1, 12, 31, 0, 0, time.UTC),
+		"*/20 * * * *":    time.Date(2017, 6, 1, 12, 40, 0, 0, time.UTC),
+		"0 2 * * *":       time.Date(2017, 6, 2, 2, 0, 0, 0, time.UTC),
+		"0 2 * * 0":       time.Date(2017, 6, 4, 2, 0, 0, 0, time.UTC),
+		"0 2 * * 7":       time.Date(2017, 6, 4, 2, 0, 0, 0, time.UTC),
+		"15 1,23 * * 1-5": time.Date(2017, 6, 1, 23, 15, 0, 0, time.UTC),
+		"0 0 1 1 *":       time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		// Either day field matches when both are restricted
+		"0 0 15 * 6": time.Date(2017, 6, 3, 0, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := parseCronSchedule(spec)
+		if err != nil {
+			t.Fatalf("%q: %s", spec, err)
+		}
+		if next := schedule.next(from); !next.Equal(expected) {
+			t.Fatalf("%q: expected %s, got %s", spec, expected, next)
+		}
+	}
+
+	schedule, err := parseCronSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.next(from); !next.IsZero() {
+		t.Fatalf("expected no match, got %s", next)
+	}
+}
+
+func TestBackend_staticRoleRotationSchedule(t *testing.T) {
+	b, s := testStaticRoleBackend(t, nil)
+
+	resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_schedule": "0 2 * * *", "rotation_window": "1h"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := role.NextVaultRotation
+	if next.Hour() != 2 || next.Minute() != 0 || time.Until(next) > 24*time.Hour {
+		t.Fatalf("bad next rotation: %s", next)
+	}
+
+	// Updates leaving the schedule alone keep a rotation that is due
+	due := next.Add(-24 * time.Hour)
+	role.NextVaultRotation = due
+	if err := b.putStaticRole(s, "app", role); err != nil {
+		t.Fatal(err)
+	}
+	resp = testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_statements": staticRotationSQL, "rotation_schedule": "0 2 * * *"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err = b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !role.NextVaultRotation.Equal(due) {
+		t.Fatalf("bad next rotation: %s", role.NextVaultRotation)
+	}
+
+	// A rotation that missed its window waits for the next scheduled time,
+	// without connecting to the database
+	role.NextVaultRotation = next.Add(-48 * time.Hour)
+	if err := b.putStaticRole(s, "app", role); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.rotateScheduledStaticRole(s, "app"); err != nil {
+		t.Fatal(err)
+	}
+	role, err = b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !role.NextVaultRotation.Equal(next) || role.Password != testStaticPassword {
+		t.Fatalf("bad: %#v", role)
+	}
+}
+
+func TestBackend_staticRoleDual(t *testing.T) {
+	b, s := testStaticRoleBackend(t, &staticRoleEntry{
+		Username:          "APP_BLUE",
+		Password:          "blue-password",
+		RotationMode:      staticRotationModeDual,
+		SecondaryUsername: "APP_GREEN",
+		SecondaryPassword: "green-password",
+		SecondaryActive:   true,
+	})
+
+	// The secondary account is served, so the next rotation changes the
+	// password of the primary one
+	resp := testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-creds/app", nil)
+	if resp.Data["username"] != "APP_GREEN" || resp.Data["password"] != "green-password" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.idleUsername() != "APP_BLUE" {
+		t.Fatalf("bad: %s", role.idleUsername())
+	}
+	role.SecondaryActive = false
+	if username, password := role.activeCredentials(); username != "APP_BLUE" || password != "blue-password" || role.idleUsername() != "APP_GREEN" {
+		t.Fatalf("bad: %s, %s, %s", username, password, role.idleUsername())
+	}
+}
+
+func TestBackend_staticRoleDualPartialRotation(t *testing.T) {
+	// Creation rotated the secondary account, but failed to rotate the
+	// primary one
+	b, s := testStaticRoleBackend(t, &staticRoleEntry{
+		Username:          "APP_BLUE",
+		RotationMode:      staticRotationModeDual,
+		SecondaryUsername: "APP_GREEN",
+		SecondaryPassword: "green-password",
+		SecondaryActive:   true,
+	})
+
+	// Writing the role again rotates the primary account, which fails
+	// without a connection, rather than leaving its password as it is
+	_, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/app",
+		Storage:   s,
+		Data:      map[string]interface{}{"rotation_period": "24h"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "config/connection") {
+		t.Fatalf("expected the primary rotation to be attempted, got %v", err)
+	}
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !role.primaryRotationPending() || role.RotationPeriod != 0 {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	// Once both accounts have passwords, updates don't rotate
+	role.Password = "blue-password"
+	if err := b.putStaticRole(s, "app", role); err != nil {
+		t.Fatal(err)
+	}
+	resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_period": "24h"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestStaticRotationBackoff(t *testing.T) {
+	for failures, expected := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	} {
+		if backoff := staticRotationBackoff(failures); backoff != expected {
+			t.Fatalf("%d failures: expected %s, got %s", failures, expected, backoff)
+		}
+	}
+}
+
+func TestBackend_staticRoleRotationFailure(t *testing.T) {
+	// Without a connection configured, rotations fail
+	due := time.Now().Add(-time.Minute).UTC()
+	b, s := testStaticRoleBackend(t, &staticRoleEntry{
+		Username:          "APP",
+		Password:          testStaticPassword,
+		RotationPeriod:    time.Hour,
+		NextVaultRotation: due,
+		RotationFailures:  2,
+		RotationAttempts:  5,
+	})
+	if err := b.staticRotations.load(b, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.rotateScheduledStaticRole(s, "app"); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.RotationFailures != 3 || role.Password != testStaticPassword || !role.NextVaultRotation.Equal(due) {
+		t.Fatalf("bad: %#v", role)
+	}
+	if backoff := time.Until(role.NextRotationRetry); backoff > 4*time.Minute || backoff < 3*time.Minute {
+		t.Fatalf("bad retry: %s", role.NextRotationRetry)
+	}
+	if due := b.staticRotations.popDue(time.Now()); len(due) != 0 {
+		t.Fatalf("expected the retry to wait: %#v", due)
+	}
+
+	resp := testStaticRoleRequest(t, b, s, logical.ReadOperation, "static-roles/app", nil)
+	if resp.Data["rotation_failures"] != 3 || !strings.Contains(resp.Data["last_rotation_error"].(string), "config/connection") {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Data["rotation_attempts"] != 6 || resp.Data["last_rotation_outcome"] != "failed" ||
+		resp.Data["last_rotation_attempt"] == "" || resp.Data["last_vault_rotation"] != "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	role.RotationFailures = 0
+	if outcome := role.lastRotationOutcome(); outcome != "succeeded" {
+		t.Fatalf("bad: %s", outcome)
+	}
+	role.RotationAttempts = 0
+	if outcome := role.lastRotationOutcome(); outcome != "" {
+		t.Fatalf("bad: %s", outcome)
+	}
+}
+
+func TestBackend_staticRolePasswordSettings(t *testing.T) {
+	b, s := testStaticRoleBackend(t, nil)
+
+	resp := testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app", map[string]interface{}{
+		"password_mode":          "strong",
+		"password_length":        16,
+		"password_special_chars": "!%",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err := b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		password, err := role.generatePassword(passwordModeUUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 16 {
+			t.Fatalf("bad length: %s", password)
+		}
+		if strings.Count(password, "!")+strings.Count(password, "%") < 2 {
+			t.Fatalf("too few special characters: %s", password)
+		}
+		if strings.ContainsAny(password, passwordSpecialChars) {
+			t.Fatalf("default special characters used: %s", password)
+		}
+	}
+
+	// The settings are kept by updates that don't give them
+	resp = testStaticRoleRequest(t, b, s, logical.UpdateOperation, "static-roles/app",
+		map[string]interface{}{"rotation_period": "24h"})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	role, err = b.StaticRole(s, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.PasswordMode != passwordModeStrong || role.PasswordLength != 16 || role.PasswordSpecialChars != "!%" {
+		t.Fatalf("bad: %#v", role)
+	}
+}
+
+func TestPurgeRecyclebinSQL(t *testing.T) {
+	// Tablespaces come from the database, and are quoted as they are stored
+	stmt := fmt.Sprintf(purgeRecyclebinSQL, quoteIdentifier("users_data"), "V_WEB_1234")
+	if stmt != `PURGE TABLESPACE "users_data" USER V_WEB_1234` {
+		t.Fatalf("bad: %s", stmt)
+	}
+	if !strings.Contains(recyclebinTablespacesSQL, "owner = :1") {
+		t.Fatalf("expected the owner to be bound: %s", recyclebinTablespacesSQL)
+	}
+}
+
+func TestPxSlaveSessionQuery(t *testing.T) {
+	// The container restricts the slaves by the container of their query
+	// coordinator, which the wrapped join selects
+	query, args := sessionQuery(pxSlaveSessionQuerySQL, []string{"V_WEB_1234"}, "PDB1")
+	if !strings.HasSuffix(query, "WHERE username IN (:1) AND con_id = (SELECT con_id FROM v$containers WHERE name = UPPER(:2))") {
+		t.Fatalf("bad: %s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"V_WEB_1234", "PDB1"}) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestProxyGrantThroughSQL(t *testing.T) {
+	if stmt := proxyGrantThroughSQL("APP", "V_WEB_8D8E4A4B", nil); stmt != "ALTER USER APP GRANT CONNECT THROUGH V_WEB_8D8E4A4B" {
+		t.Fatalf("bad: %s", stmt)
+	}
+	stmt := proxyGrantThroughSQL("APP", "V_WEB_8D8E4A4B", []string{"APP_READ", "APP_WRITE"})
+	if stmt != "ALTER USER APP GRANT CONNECT THROUGH V_WEB_8D8E4A4B WITH ROLE APP_READ, APP_WRITE" {
+		t.Fatalf("bad: %s", stmt)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	for _, err := range []error{
+		driver.ErrBadConn,
+		errors.New("ORA-03113: end-of-file on communication channel"),
+		errors.New("ORA-00054: resource busy and acquire with NOWAIT specified or timeout expired"),
+	} {
+		if !isTransientError(err) {
+			t.Fatalf("expected %q to be transient", err)
+		}
+	}
+	for _, err := range []error{
+		errors.New("ORA-01031: insufficient privileges"),
+		errors.New("ORA-00942: table or view does not exist"),
+	} {
+		if isTransientError(err) {
+			t.Fatalf("expected %q not to be transient", err)
+		}
+	}
+}
+
+func TestIsPasswordVerifyError(t *testing.T) {
+	err := errors.New("ORA-28003: password verification for the specified password failed\nORA-20000: password length less than 8 characters")
+	if !isPasswordVerifyError(err) {
+		t.Fatalf("expected %q to be a password verify error", err)
+	}
+	if isPasswordVerifyError(errors.New("ORA-01920: user name 'WEB' conflicts with another user or role name")) {
+		t.Fatal("bad: ORA-01920")
+	}
+}
+
+func TestPasswordVerifier(t *testing.T) {
+	// RFC 2898 test vector for PBKDF2-HMAC-SHA512
+	key := pbkdf2SHA512([]byte("password"), []byte("salt"), 1, 64)
+	expected := "867f70cf1ade02cff3752599a3a53dc4af34c7a669815ae5d513554e1c8cf252c02d470a285a0501bad999bfe943c08f050235d7d68b1da55e63f73b60a57fce"
+	if actual := hex.EncodeToString(key); actual != expected {
+		t.Fatalf("bad key: %s", actual)
+	}
+
+	salt := make([]byte, passwordVerifierSaltLength)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	verifier := passwordVerifierWithSalt("Vault-Passw0rd", salt)
+	expected = "T:C84F9D5A3A32ACE97F3BC80FCB919ADAD118AB6AF0518AAA058FBFBC44A4F95F638CD6104E464F2B1B51709E242DDABF96DB83C1D9545EC96D102FB8C1590537000102030405060708090A0B0C0D0E0F"
+	if verifier != expected {
+		t.Fatalf("bad verifier: %s", verifier)
+	}
+
+	verifier, err := passwordVerifier("Vault-Passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifier) != len(expected) || !strings.HasPrefix(verifier, "T:") {
+		t.Fatalf("bad verifier: %s", verifier)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	if err := validateAllowedVariables([]string{"app", "consumer_group"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"name", "reason", "upper", "app-name", "1app"} {
+		if err := validateAllowedVariables([]string{name}); err == nil {
+			t.Fatalf("expected error allowing %q", name)
+		}
+	}
+
+	allowed := []string{"app", "team"}
+	variables, err := parseVariables(map[string]interface{}{"app": "checkout"}, allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"app": "checkout", "team": ""}
+	if !reflect.DeepEqual(expected, variables) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, variables)
+	}
+
+	invalid := []map[string]interface{}{
+		{"owner": "me"},
+		{"app": 1},
+		{"app": strings.Repeat("a", maxVariableLength+1)},
+	}
+	for _, raw := range invalid {
+		if _, err := parseVariables(raw, allowed); err == nil {
+			t.Fatalf("expected error parsing %#v", raw)
+		}
+	}
+}
+
+func TestRoleEntry_sessionKillSQL(t *testing.T) {
+	for _, c := range []struct {
+		role     *roleEntry
+		expected string
+	}{
+		{nil, sessionKillSQL},
+		{&roleEntry{}, sessionKillSQL},
+		{&roleEntry{SessionTermination: sessionTerminationImmediate}, sessionKillSQL},
+		{&roleEntry{SessionTermination: sessionTerminationPostTransaction}, sessionDisconnectSQL},
+	} {
+		if actual := c.role.sessionKillSQL(nil); actual != c.expected {
+			t.Fatalf("bad: expected %q, got %q", c.expected, actual)
+		}
+	}
+
+	// The configured kill SQL replaces the default, but not disconnecting
+	// sessions once their transaction is done
+	config := &revocationConfig{SessionKillSQL: "CALL admin.end_session({{sid}}, {{serial}}, {{inst_id}})"}
+	if actual := (&roleEntry{}).sessionKillSQL(config); actual != config.SessionKillSQL {
+		t.Fatalf("bad: %q", actual)
+	}
+	if actual := (&roleEntry{SessionTermination: sessionTerminationPostTransaction}).sessionKillSQL(config); actual != sessionDisconnectSQL {
+		t.Fatalf("bad: %q", actual)
+	}
+	kill := sessionKillStatement(config.SessionKillSQL, "V_WEB_1234", oracleSession{SID: 12, Serial: 345, Instance: 2})
+	if kill != "CALL admin.end_session(12, 345, 2)" {
+		t.Fatalf("bad: %q", kill)
+	}
+}
+
+func TestRoleEntry_revocationSQL(t *testing.T) {
+	purge := &revocationConfig{RevocationSQL: "DROP USER {{name}}; PURGE DBA_RECYCLEBIN"}
+	cases := []struct {
+		role     roleEntry
+		config   *revocationConfig
+		expected string
+	}{
+		{roleEntry{}, nil, defaultRevocationSQL},
+		{roleEntry{}, &revocationConfig{}, defaultRevocationSQL},
+		{roleEntry{}, purge, purge.RevocationSQL},
+		{roleEntry{RevokeCascade: true}, purge, cascadeRevocationSQL},
+		{roleEntry{RevocationMode: revocationModeLock}, purge, lockRevocationSQL},
+		{roleEntry{RevocationMode: revocationModeLock, Identification: identificationExternal}, nil, lockNoPasswordRevocationSQL},
+		{roleEntry{RevocationSQL: "DROP USER {{name}}"}, purge, "DROP USER {{name}}"},
+	}
+	for _, c := range cases {
+		if actual := c.role.revocationSQL(c.config); actual != c.expected {
+			t.Fatalf("bad: expected %q, got %q", c.expected, actual)
+		}
+	}
+}
+
+func TestExpiryJob_killsOnAllInstances(t *testing.T) {
+	job := expiryJob("VAULT_EXPIRE_1", "V_WEB_1234", "V_WEB_1234", expiryEnforcementLock, time.Hour)
+	if !strings.Contains(job, "FROM gv$session") || !strings.Contains(job, "',@'' || s.inst_id") {
+		t.Fatalf("bad: %s", job)
+	}
+	if kill := sessionKillStatement(sessionKillSQL, "V_WEB_1234", oracleSession{SID: 12, Serial: 345, Instance: 2}); kill != "ALTER SYSTEM KILL SESSION '12,345,@2' IMMEDIATE" {
+		t.Fatalf("bad: %s", kill)
+	}
+	if !strings.Contains(job, "WHEN s.server IN (''SHARED'', ''NONE'') THEN ''DISCONNECT''") {
+		t.Fatalf("bad: %s", job)
+	}
+}
+
+func TestSessionKillStatement_sharedServer(t *testing.T) {
+	// Shared server sessions, busy or idle, are disconnected rather than
+	// killed, so that their virtual circuits are freed
+	for _, server := range []string{"SHARED", "NONE"} {
+		session := oracleSession{SID: 12, Serial: 345, Instance: 2, Server: server}
+		if kill := sessionKillStatement(sessionKillSQL, "V_WEB_1234", session); kill != "ALTER SYSTEM DISCONNECT SESSION '12,345,@2' IMMEDIATE" {
+			t.Fatalf("bad: %s", kill)
+		}
+		if kill := sessionKillStatement(sessionDisconnectSQL, "V_WEB_1234", session); kill != "ALTER SYSTEM DISCONNECT SESSION '12,345,@2' POST_TRANSACTION" {
+			t.Fatalf("bad: %s", kill)
+		}
+	}
+
+	session := oracleSession{SID: 12, Serial: 345, Instance: 2, Server: "DEDICATED"}
+	if kill := sessionKillStatement(sessionKillSQL, "V_WEB_1234", session); kill != "ALTER SYSTEM KILL SESSION '12,345,@2' IMMEDIATE" {
+		t.Fatalf("bad: %s", kill)
+	}
+
+	// Custom kill SQL is left as is, with the server substituted
+	session.Server = "SHARED"
+	if kill := sessionKillStatement("CALL admin.end_session({{sid}}, {{serial}}, '{{server}}')", "V_WEB_1234", session); kill != "CALL admin.end_session(12, 345, 'SHARED')" {
+		t.Fatalf("bad: %s", kill)
+	}
+}
+
+func TestDropUserRegex(t *testing.T) {
+	for stmt, expected := range map[string]bool{
+		"DROP USER V_WEB_1234":           true,
+		"drop user \"v_web_1234\"":       true,
+		"DROP USER V_WEB_1234 CASCADE":   false,
+		"REVOKE CONNECT FROM V_WEB_1234": false,
+	} {
+		if actual := dropUserRegex.MatchString(stmt); actual != expected {
+			t.Fatalf("bad: %q: expected %t", stmt, expected)
+		}
+	}
+
+	// Both are dropped through the revocation package
+	for stmt, cascade := range map[string]string{
+		"DROP USER V_WEB_1234":         "",
+		"drop user V_WEB_1234 cascade": " cascade",
+	} {
+		match := anyDropUserRegex.FindStringSubmatch(stmt)
+		if match == nil || match[1] != cascade {
+			t.Fatalf("bad: %q: %#v", stmt, match)
+		}
+	}
+	if anyDropUserRegex.MatchString("REVOKE CONNECT FROM V_WEB_1234") {
+		t.Fatal("expected REVOKE not to match")
+	}
+}
+
+func TestOwnedObjectsError(t *testing.T) {
+	err := &ownedObjectsError{Username: "V_WEB_1234", Objects: []string{"TABLE ORDERS", "INDEX ORDERS_PK"}}
+	expected := "user V_WEB_1234 cannot be dropped because it owns 2 objects: TABLE ORDERS, INDEX ORDERS_PK; drop them, or set revoke_cascade on the role to drop them with the user"
+	if err.Error() != expected {
+		t.Fatalf("bad: %q", err.Error())
+	}
+
+	var objects []string
+	for i := 0; i < maxListedOwnedObjects+2; i++ {
+		objects = append(objects, fmt.Sprintf("TABLE T%d", i))
+	}
+	err = &ownedObjectsError{Username: "V_WEB_1234", Objects: objects}
+	if !strings.Contains(err.Error(), "TABLE T9 and") || strings.Contains(err.Error(), "TABLE T10") || !strings.Contains(err.Error(), " and 2 more;") {
+		t.Fatalf("bad: %q", err.Error())
+	}
+}
+
+func TestRoleEntry_executionMode(t *testing.T) {
+	if mode := (&roleEntry{}).executionMode(); mode != executionModeTransaction {
+		t.Fatalf("bad: %q", mode)
+	}
+	if mode := (&roleEntry{ExecutionMode: executionModeStatement}).executionMode(); mode != executionModeStatement {
+		t.Fatalf("bad: %q", mode)
+	}
+}
+
+func TestIssuanceLimiter(t *testing.T) {
+	var l issuanceLimiter
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("web", 2, time.Minute, now); !ok {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+	ok, wait := l.allow("web", 2, time.Minute, now.Add(10*time.Second))
+	if ok || wait != 50*time.Second {
+		t.Fatalf("bad: %v, %s", ok, wait)
+	}
+	if ok, _ := l.allow("app", 2, time.Minute, now); !ok {
+		t.Fatal("expected other roles to be counted separately")
+	}
+	if ok, _ := l.allow("web", 2, time.Minute, now.Add(time.Minute)); !ok {
+		t.Fatal("expected the next period to be allowed")
+	}
+}
+
+func TestRenderQuery(t *testing.T) {
+	data := map[string]string{
+		"name":     "web_8d8e4a4b",
+		"password": "secret",
+	}
+	cases := map[string]string{
+		`CREATE USER {{name}} IDENTIFIED BY "{{password}}"`: `CREATE USER web_8d8e4a4b IDENTIFIED BY "secret"`,
+		`{{name | upper}}`:        `WEB_8D8E4A4B`,
+		`{{upper name | lower}}`:  `web_8d8e4a4b`,
+		`{{replace "_" "" name}}`: `web8d8e4a4b`,
+		`{{truncate 3 name}}`:     `web`,
+		`{{truncate 30 name}}`:    `web_8d8e4a4b`,
+	}
+	for tpl, expected := range cases {
+		actual, err := renderQuery(tpl, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("bad: rendering %q, expected %q, got %q", tpl, expected, actual)
+		}
+	}
+
+	year := time.Now().UTC().Format("2006")
+	if actual, err := renderQuery(`{{timestamp "2006"}}`, data); err != nil || actual != year {
+		t.Fatalf("bad: expected %q, got %q (%v)", year, actual, err)
+	}
+
+	for _, tpl := range []string{`{{external_name}}`, `{{exec name}}`, `{{name`} {
+		if _, err := renderQuery(tpl, data); err == nil {
+			t.Fatalf("expected error rendering %q", tpl)
+		}
+	}
+}
+
+func TestManagedProfile(t *testing.T) {
+	if actual := managedProfileName("web-app.prod"); actual != "VAULT_WEB_APP_PROD" {
+		t.Fatalf("bad: %q", actual)
+	}
+	if actual := managedProfileName("a-very-long-role-name-for-testing"); len(actual) != oracleUsernameLength {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	expected := "CREATE PROFILE VAULT_WEB LIMIT PASSWORD_LIFE_TIME 5400/86400 IDLE_TIME 90"
+	if actual := managedProfileSQL(true, "VAULT_WEB", 90*time.Minute); actual != expected {
+		t.Fatalf("bad: expected %q, got %q", expected, actual)
+	}
+	expected = "ALTER PROFILE VAULT_WEB LIMIT PASSWORD_LIFE_TIME 30/86400 IDLE_TIME 1"
+	if actual := managedProfileSQL(false, "VAULT_WEB", 30*time.Second); actual != expected {
+		t.Fatalf("bad: expected %q, got %q", expected, actual)
+	}
+}
+func TestServiceTrigger(t *testing.T) {
+	trigger := serviceTriggerName("web_8d8e4a4b_0b1c_3c5a_9f0e_1")
+	if len(trigger) != oracleUsernameLength || !oracleIdentifierRegex.MatchString(trigger) {
+		t.Fatalf("bad: %q", trigger)
+	}
+
+	sql, err := renderQuery(serviceTrigger(trigger, "app.example.com"), map[string]string{
+		"name": "web_8d8e4a4b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"CREATE TRIGGER " + trigger + " AFTER LOGON ON web_8d8e4a4b.SCHEMA",
+		"<> UPPER('app.example.com')",
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Fatalf("expected %q in %q", expected, sql)
+		}
+	}
+}
+func TestBindPassword(t *testing.T) {
+	stmt, args := bindPassword(`CREATE USER web IDENTIFIED BY "PH" DEFAULT TABLESPACE 'x'`, "PH", "secret")
+	expected := `BEGIN EXECUTE IMMEDIATE 'CREATE USER web IDENTIFIED BY "' || :p1 || '" DEFAULT TABLESPACE ''x'''; END;`
+	if stmt != expected {
+		t.Fatalf("bad: expected %q, got %q", expected, stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"secret"}) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	_, args = bindPassword("PH PH", "PH", "secret")
+	if len(args) != 2 {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestGenerateUsername(t *testing.T) {
+	username, warnings, err := generateUsername("web", "token-display-name", "", "", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) == 0 {
+		t.Fatal("expected a warning about the shortened display name")
+	}
+	if len(username) != oracleUsernameLength || !oracleIdentifierRegex.MatchString(username) {
+		t.Fatalf("bad: %q", username)
+	}
+	if !strings.HasPrefix(username, "token_disp_") {
+		t.Fatalf("bad: %q", username)
+	}
+
+	username, _, err = generateUsername("web", "token", "", "", &roleEntry{UsernameCase: usernameCaseUpper}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != strings.ToUpper(username) {
+		t.Fatalf("bad: %q", username)
+	}
+
+	// Longer usernames keep the whole display name and UUID
+	username, warnings, err = generateUsername("web", "token-display-name", "", "", &roleEntry{}, nil, oracleLongUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if len(username) != len("token_display_name_")+36 || !strings.HasPrefix(username, "token_display_name_") {
+		t.Fatalf("bad: %q", username)
+	}
+
+	identity := requestIdentity(&logical.Request{ClientTokenAccessor: "3a2e5e6c-0f1b-9f4b-7c86-2d4a2b5e6f7a"})
+	if identity != "3a2e5e6c" {
+		t.Fatalf("bad: %q", identity)
+	}
+	username, _, err = generateUsername("web", "token-display-name", identity, "", &roleEntry{UsernameIdentity: true}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(username) != oracleUsernameLength || !strings.HasPrefix(username, "token_disp_3a2e5e6c_") {
+		t.Fatalf("bad: %q", username)
+	}
+	username, _, err = generateUsername("web", "token-display-name", identity, "", &roleEntry{UsernameIdentity: true}, nil, oracleLongUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(username, "token_display_name_3a2e5e6c_") {
+		t.Fatalf("bad: %q", username)
+	}
+}
+
+func TestGenerateUsername_template(t *testing.T) {
+	config := &usernameConfig{
+		Template:   "{{role | upper}}_{{display_name}}_{{uuid}}",
+		Separator:  "$",
+		Truncation: usernameTruncationEnd,
+	}
+	username, _, err := generateUsername("web-app", "token-display-name", "", "", &roleEntry{}, config, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(username) != oracleUsernameLength || !strings.HasPrefix(username, "WEB_APP_token_display_name_") {
+		t.Fatalf("bad: %q", username)
+	}
+
+	// The role's template takes precedence
+	role := &roleEntry{UsernameTemplate: "{{display_name}}#{{uuid}}"}
+	username, _, err = generateUsername("web", "token", "", "", role, config, oracleLongUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(username) != len("token#")+36 || strings.Count(username, "$") != 4 {
+		t.Fatalf("bad: %q", username)
+	}
+
+	config.Truncation = usernameTruncationError
+	if _, _, err := generateUsername("web", "token-display-name", "", "", &roleEntry{}, config, oracleUsernameLength); err == nil {
+		t.Fatal("expected error generating a username that is too long")
+	}
+
+	role = &roleEntry{UsernameTemplate: "{{display_name}}.{{uuid}}"}
+	if _, _, err := generateUsername("web", "token", "", "", role, nil, oracleUsernameLength); err == nil {
+		t.Fatal("expected error generating a username that isn't a valid identifier")
+	}
+
+	for _, tpl := range []string{"{{name}}", "{{uuid"} {
+		if err := validateUsernameTemplate(tpl); err == nil {
+			t.Fatalf("expected error validating %q", tpl)
+		}
+	}
+}
+
+func TestSanitizeDisplayName(t *testing.T) {
+	cases := map[string]string{
+		"token-display-name":        "token_display_name",
+		"ldap-john.doe@example.com": "ldap_john_doe_example_com",
+		"oidc-jöhn":                 "oidc_j_hn",
+		"--app--":                   "app",
+		"123":                       "v123",
+		"":                          "vault",
+		"--":                        "vault",
+	}
+	for displayName, expected := range cases {
+		if actual := sanitizeDisplayName(displayName); actual != expected {
+			t.Fatalf("bad: %q: expected %q, got %q", displayName, expected, actual)
+		}
+	}
+
+	username, _, err := generateUsername("web", "ldap-j.doe", "", "", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !oracleIdentifierRegex.MatchString(username) || !strings.HasPrefix(username, "ldap_j_doe_") {
+		t.Fatalf("bad: %q", username)
+	}
+}
+
+func TestGenerateUsername_hint(t *testing.T) {
+	hint := sanitizeUsernameHint("nightly-etl.job")
+	if hint != "nightlye" {
+		t.Fatalf("bad: %q", hint)
+	}
+
+	username, _, err := generateUsername("web", "token", "", hint, &roleEntry{}, nil, oracleLongUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(username, "token_nightlye_") {
+		t.Fatalf("bad: %q", username)
+	}
+}
+
+func TestGenerateUsername_random(t *testing.T) {
+	for _, tc := range []struct {
+		role   *roleEntry
+		config *usernameConfig
+	}{
+		{&roleEntry{RandomUsername: true}, nil},
+		{&roleEntry{}, &usernameConfig{Random: true}},
+	} {
+		username, warnings, err := generateUsername("web", "ldap-jdoe", "8d8e4a4b", "", tc.role, tc.config, oracleUsernameLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(username) != oracleUsernameLength || !strings.HasPrefix(username, "V_") || len(warnings) != 0 {
+			t.Fatalf("bad: %q, %v", username, warnings)
+		}
+		if strings.Contains(strings.ToLower(username), "jdoe") || strings.Contains(username, "8d8e4a4b") {
+			t.Fatalf("username includes the requester: %q", username)
+		}
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	role := &roleEntry{SQL: "CREATE USER {{name}} IDENTIFIED BY {{password}}"}
+	conn := &connectionConfig{ConnectionURL: "vault/secret@db:1521/ORCL", MaxOpenConnections: 2}
+	fingerprint, err := configFingerprint(role, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the password of the connecting user isn't a change
+	rotated := *conn
+	rotated.ConnectionURL = "vault/rotated@db:1521/ORCL"
+	if actual, _ := configFingerprint(role, &rotated); actual != fingerprint {
+		t.Fatalf("fingerprint changed with the connection credentials")
+	}
+
+	moved := *conn
+	moved.ConnectionURL = "vault/secret@other:1521/ORCL"
+	changed := *role
+	changed.RevocationSQL = "DROP USER {{name}}"
+	for _, tc := range []struct {
+		role *roleEntry
+		conn *connectionConfig
+	}{
+		{role, &moved},
+		{&changed, conn},
+		{nil, conn},
+	} {
+		if actual, _ := configFingerprint(tc.role, tc.conn); actual == fingerprint {
+			t.Fatalf("fingerprint unchanged for %#v, %#v", tc.role, tc.conn)
+		}
+	}
+}
+
+func TestServiceAccountUsername(t *testing.T) {
+	username, _, err := serviceAccountUsername("web", "ldap-jdoe", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "web_ldap_jdoe" {
+		t.Fatalf("bad: %q", username)
+	}
+
+	// Long usernames are shortened but stay distinct
+	long, _, err := serviceAccountUsername("web", "ldap-jdoe@example.com-team-a", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := serviceAccountUsername("web", "ldap-jdoe@example.com-team-b", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(long) != oracleUsernameLength || !strings.HasPrefix(long, "web_ldap_jdoe") || long == other {
+		t.Fatalf("bad: %q, %q", long, other)
+	}
+	again, _, err := serviceAccountUsername("web", "ldap-jdoe@example.com-team-a", &roleEntry{}, nil, oracleUsernameLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != long {
+		t.Fatalf("expected %q, got %q", long, again)
+	}
+}
+
+func TestUsernameLengthForVersion(t *testing.T) {
+	cases := map[string]int{
+		"11.2.0.4.0": oracleUsernameLength,
+		"12.1.0.2":   oracleUsernameLength,
+		"12.2.0":     oracleLongUsernameLength,
+		"19.0.0":     oracleLongUsernameLength,
+		"":           oracleUsernameLength,
+	}
+	for version, expected := range cases {
+		if actual := usernameLengthForVersion(version); actual != expected {
+			t.Fatalf("bad: %q: expected %d, got %d", version, expected, actual)
+		}
+	}
+}
+func TestGenerateStrongPassword(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := generatePassword(passwordModeStrong)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != oraclePasswordLength || !isLetter(password[0]) {
+			t.Fatalf("bad: %q", password)
+		}
+		for _, class := range []string{passwordUpperChars, passwordLowerChars, passwordDigitChars, passwordSpecialChars} {
+			count := 0
+			for _, c := range password {
+				if strings.ContainsRune(class, c) {
+					count++
+				}
+			}
+			if count < 2 {
+				t.Fatalf("bad: %q has fewer than two of %q", password, class)
+			}
+		}
+	}
+}
+
+func TestValidQualifiedName(t *testing.T) {
+	for _, name := range []string{"vault_credentials", "AUDIT.VAULT_CREDENTIALS"} {
+		if !validQualifiedName(name) {
+			t.Fatalf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "a.b.c", "audit.", "vault credentials", "t; DROP TABLE x"} {
+		if validQualifiedName(name) {
+			t.Fatalf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestConnectString(t *testing.T) {
+	cases := map[string]string{
+		"vault/secret@db.example.com:1521/ORCLPDB1": "db.example.com:1521/ORCLPDB1",
+		"vault/p@ss@db.example.com/ORCL":            "db.example.com/ORCL",
+		"db.example.com:1521/ORCL":                  "db.example.com:1521/ORCL",
+	}
+	for connURL, expected := range cases {
+		if actual := connectString(connURL); actual != expected {
+			t.Fatalf("bad: %q: expected %q, got %q", connURL, expected, actual)
+		}
+	}
+}
+
+func TestJDBCURL(t *testing.T) {
+	cases := map[string]string{
+		"db.example.com:1521/ORCLPDB1":   "jdbc:oracle:thin:@//db.example.com:1521/ORCLPDB1",
+		"//db.example.com/ORCL":          "jdbc:oracle:thin:@//db.example.com/ORCL",
+		"ORCL":                           "jdbc:oracle:thin:@ORCL",
+		"tcps://db.example.com:2484/APP": "jdbc:oracle:thin:@tcps://db.example.com:2484/APP",
+		"(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=db)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=ORCL)))": "jdbc:oracle:thin:@(DESCRIPTION=(ADDRESS=(PROTOCOL=TCP)(HOST=db)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=ORCL)))",
+		"": "",
+	}
+	for connectString, expected := range cases {
+		if actual := jdbcURL(connectString); actual != expected {
+			t.Fatalf("bad: %q: expected %q, got %q", connectString, expected, actual)
+		}
+	}
+}
+
+func TestTLSClientConfig(t *testing.T) {
+	descriptor := "(DESCRIPTION=(ADDRESS=(PROTOCOL=TCPS)(HOST=db)(PORT=2484))(CONNECT_DATA=(SERVICE_NAME=APP)))"
+	for connectString, expected := range map[string]bool{
+		"tcps://db.example.com:2484/APP": true,
+		descriptor:                       true,
+		"db.example.com:1521/APP":        false,
+		"tcp://db.example.com:1521/APP":  false,
+	} {
+		if actual := usesTCPS(connectString); actual != expected {
+			t.Fatalf("bad: %q: expected %t", connectString, expected)
+		}
+	}
+
+	if actual := tnsAlias("web-app"); actual != "VAULT_WEB_APP" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	expected := `VAULT_WEB =
+  (DESCRIPTION =
+    (ADDRESS = (PROTOCOL = TCPS)(HOST = db.example.com)(PORT = 2484))
+    (CONNECT_DATA = (SERVICE_NAME = APP))
+  )
+`
+	if actual := tnsnamesEntry("VAULT_WEB", "tcps://db.example.com:2484/APP:dedicated?ssl_server_dn_match=on"); actual != expected {
+		t.Fatalf("bad: expected %q, got %q", expected, actual)
+	}
+	if actual := tnsnamesEntry("VAULT_WEB", descriptor); actual != "VAULT_WEB = "+descriptor+"\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	if actual := sqlnetConfig("/etc/oracle/wallet"); !strings.Contains(actual, "(DIRECTORY = /etc/oracle/wallet)") {
+		t.Fatalf("bad: %q", actual)
+	}
+	if actual := sqlnetConfig(""); strings.Contains(actual, "WALLET_LOCATION") {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if actual := quoteIdentifier(`web_"abc"`); actual != `"web_abc"` {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestAccountOptions_alterUserSQL(t *testing.T) {
+	opts := accountOptions{}
+	if sql := opts.alterUserSQL(); sql != "" {
+		t.Fatalf("expected no statement for empty options, got %q", sql)
+	}
+
+	opts = accountOptions{
+		DefaultTablespace: "USERS",
+		Profile:           "APP_PROFILE",
+		Quotas: map[string]string{
+			"USERS": "100m",
+			"DATA":  "unlimited",
+		},
+		AccountUnlock:   true,
+		PasswordExpire:  true,
+		EditionsEnabled: true,
+	}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "ALTER USER {{name}} DEFAULT TABLESPACE USERS QUOTA UNLIMITED ON DATA QUOTA 100M ON USERS PROFILE APP_PROFILE PASSWORD EXPIRE ACCOUNT UNLOCK ENABLE EDITIONS"
+	if sql := opts.alterUserSQL(); sql != expected {
+		t.Fatalf("bad: expected %q, got %q", expected, sql)
+	}
+
+	invalid := []accountOptions{
+		{DefaultTablespace: "USERS; DROP USER SYS"},
+		{Profile: "1PROFILE"},
+		{Quotas: map[string]string{"USERS": "lots"}},
+		{Quotas: map[string]string{"US ERS": "10M"}},
+	}
+	for _, opts := range invalid {
+		if err := opts.validate(); err == nil {
+			t.Fatalf("expected error validating %#v", opts)
+		}
+	}
+}
+
+func TestObjectGrants(t *testing.T) {
+	grants, err := parseObjectGrants(map[string]interface{}{
+		"select":  []interface{}{"APP.ORDERS", "APP.CUSTOMERS"},
+		"EXECUTE": "APP.PKG_ORDERS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"GRANT EXECUTE ON APP.PKG_ORDERS TO {{name}}",
+		"GRANT SELECT ON APP.ORDERS TO {{name}}",
+		"GRANT SELECT ON APP.CUSTOMERS TO {{name}}",
+	}
+	if actual := objectGrantSQL(grants, false); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+
+	for i := range expected {
+		expected[i] += " WITH GRANT OPTION"
+	}
+	if actual := objectGrantSQL(grants, true); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+
+	invalid := []map[string]interface{}{
+		{"DROP": "APP.ORDERS"},
+		{"SELECT": "APP.ORDERS TO PUBLIC --"},
+		{"SELECT": []interface{}{1}},
+	}
+	for _, raw := range invalid {
+		if _, err := parseObjectGrants(raw); err == nil {
+			t.Fatalf("expected error parsing %#v", raw)
+		}
+	}
+}
+
+func testAccPreCheck(t *testing.T) {
+	if v := os.Getenv("ORACLE_DSN"); v == "" {
+		t.Fatal("ORACLE_DSN must be set for acceptance tests")
+	}
+}
+
+func testAccStepConfig(t *testing.T) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/connection",
+		Data: map[string]interface{}{
+			"connection_url": os.Getenv("ORACLE_DSN"),
+		},
+	}
+}
+
+func testAccStepCreateRole(t *testing.T, name string, sql string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data: map[string]interface{}{
+			"sql": sql,
+		},
+	}
+}
+
+func testAccStepDeleteRole(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/" + name,
+	}
+}
+
+func testAccStepReadCreds(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + name,
+		Check: func(resp *logical.Response) error {
+			var d struct {
+				Username string `mapstructure:"username"`
+				Password string `mapstructure:"password"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+			log.Printf("[WARN] Generated credentials: %v", d)
+
+			if d.Username == "" || d.Password == "" {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+
+			return nil
+		},
+	}
+}
+
+func testAccStepReadRole(t *testing.T, name string, sql string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "roles/" + name,
+		Check: func(resp *logical.Response) error {
+			if resp == nil {
+				if sql == "" {
+					return nil
+				}
+
+				return fmt.Errorf("bad: %#v", resp)
+			}
+
+			var d struct {
+				SQL string `mapstructure:"sql"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+
+			if d.SQL != sql {
+				return fmt.Errorf("bad: %#v", resp)
+			}
+
+			return nil
+		},
+	}
+}
+
+// testIdleDriverName is a database/sql driver whose connections can be
+// opened and pinged, but not used, for tests needing a connection that
+// doesn't get as far as running statements.
+const testIdleDriverName = "oracle-test-idle"
+
+type testIdleDriver struct{}
+
+func (testIdleDriver) Open(name string) (driver.Conn, error) { return testIdleConn{}, nil }
+
+type testIdleConn struct{}
+
+func (testIdleConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("statements can't be run on an idle test connection")
+}
+func (testIdleConn) Close() error { return nil }
+func (testIdleConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions can't be started on an idle test connection")
+}
+
+func init() {
+	sql.Register(testIdleDriverName, testIdleDriver{})
+}
+
+// testStaticRoleBackend returns a backend with the static role "app" stored
+// directly, since writing one requires a database. Without a role, one for
+// the account APP with the password testStaticPassword is stored.
+func testStaticRoleBackend(t *testing.T, role *staticRoleEntry) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+	if _, err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if role == nil {
+		role = &staticRoleEntry{
+			Username: "APP",
+			Password: testStaticPassword,
+		}
+	}
+	if err := b.putStaticRole(config.StorageView, "app", role); err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+// testStaticRoleRequest makes a request of the backend, failing the test if
+// it returns an error rather than an error response.
+func testStaticRoleRequest(t *testing.T, b *backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// testStaticPassword is the password of the static role testStaticRoleBackend
+// stores by default.
+const testStaticPassword = "132ae3ef-5a64-7499-351e-bfe5"
+
+const testRole = `
+CREATE USER {{name}} IDENTIFIED BY "{{password}}";
+GRANT CONNECT TO {{name}};
+`
+
+const testGlobalRole = `
+CREATE USER {{name}} IDENTIFIED GLOBALLY AS '{{global_dn}}';
+GRANT CONNECT TO {{name}};
+`
+
+const testValuesRole = `
+CREATE USER {{name}} IDENTIFIED BY VALUES '{{password_verifier}}';
+GRANT CONNECT TO {{name}};
+`
+
+const testExternalRole = `
+CREATE USER {{name}} IDENTIFIED EXTERNALLY AS '{{external_name}}';
+GRANT CONNECT TO {{name}};
+`
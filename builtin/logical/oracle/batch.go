@@ -0,0 +1,74 @@
+package oracle
+
+import "sync"
+
+// batcher coalesces the work of concurrent revocations into batches, queued
+// by key, such as the query and container of a session lookup. A batch runs
+// as soon as the one ahead of it in its queue is done, so the work of a
+// revocation with none other in flight runs at once, while that of those
+// arriving as a batch runs, such as when many leases expire together, is
+// gathered into the next.
+type batcher struct {
+	sync.Mutex
+	queues  map[string][]*batch
+	running map[string]bool
+}
+
+// batch is the work of one or more revocations, run together.
+type batch struct {
+	items  []string
+	run    func(items []string) (interface{}, error)
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+// add adds the item to the last batch queued for the key, or to a new one if
+// there is none or it is full with max items, and returns the result of the
+// batch once it has run. A batch is run with the function of the item that
+// started it.
+func (l *batcher) add(key, item string, max int,
+	run func(items []string) (interface{}, error)) (interface{}, error) {
+	l.Lock()
+	if l.queues == nil {
+		l.queues = make(map[string][]*batch)
+		l.running = make(map[string]bool)
+	}
+	queue := l.queues[key]
+	var current *batch
+	if len(queue) > 0 && len(queue[len(queue)-1].items) < max {
+		current = queue[len(queue)-1]
+	} else {
+		current = &batch{run: run, done: make(chan struct{})}
+		l.queues[key] = append(queue, current)
+	}
+	current.items = append(current.items, item)
+	if !l.running[key] {
+		l.running[key] = true
+		go l.drain(key)
+	}
+	l.Unlock()
+
+	<-current.done
+	return current.result, current.err
+}
+
+// drain runs the batches queued for the key in turn, until none are left.
+func (l *batcher) drain(key string) {
+	for {
+		l.Lock()
+		queue := l.queues[key]
+		if len(queue) == 0 {
+			delete(l.queues, key)
+			delete(l.running, key)
+			l.Unlock()
+			return
+		}
+		next := queue[0]
+		l.queues[key] = queue[1:]
+		l.Unlock()
+
+		next.result, next.err = next.run(next.items)
+		close(next.done)
+	}
+}
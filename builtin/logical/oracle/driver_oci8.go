@@ -0,0 +1,7 @@
+// +build oci8
+
+package oracle
+
+import (
+	_ "github.com/mattn/go-oci8"
+)
@@ -0,0 +1,103 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxDropBatchSize keeps the block dropping a batch of users well within
+// the size of a PL/SQL block.
+const maxDropBatchSize = 100
+
+// dropUsersBlock returns a PL/SQL block running the DROP USER statements in
+// a single round trip. A failing statement doesn't stop the others, and the
+// first failure is raised once they have all run.
+func dropUsersBlock(dropStmts []string) string {
+	calls := make([]string, len(dropStmts))
+	for i, stmt := range dropStmts {
+		calls[i] = fmt.Sprintf("  drop_user('%s');", quoteLiteral(stmt))
+	}
+	return fmt.Sprintf(dropUsersBlockSQL, strings.Join(calls, "\n"))
+}
+
+// dropUsers runs the DROP USER statements in the container in a single
+// block.
+func dropUsers(db *sql.DB, container string, dropStmts []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+	if _, err := tx.Exec(dropUsersBlock(dropStmts)); err != nil {
+		return err
+	}
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropUser drops the user of a revocation in the container with dropSQL.
+// The drop is batched with those of concurrent revocations in the same
+// container, so that when many leases expire at once, their users are
+// dropped together rather than with a statement each. If the batch fails,
+// the user is dropped on its own, unless the batch dropped it before
+// failing, so that it fails with its own error, and sessions opened since
+// its sessions were killed are killed again while the drop is retried.
+func (b *backend) dropUser(db *sql.DB, container, dropSQL, username string, quoted bool, retryPeriod time.Duration, kill func() error) error {
+	_, err := b.dropBatches.add(container, dropSQL, maxDropBatchSize,
+		func(dropStmts []string) (interface{}, error) {
+			return nil, dropUsers(db, container, dropStmts)
+		})
+	if err == nil {
+		return nil
+	}
+	b.logger.Trace("oracle/secretCredsRevoke: batched drop failed, dropping user on its own", "username", username, "error", err)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	exists, err := userExists(tx, username, quoted)
+	if err != nil {
+		return err
+	}
+	if exists {
+		stmt, err := tx.Prepare(dropSQL)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		if err := b.execRevocation(stmt, nil, username, retryPeriod, kill); err != nil {
+			return err
+		}
+	}
+
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
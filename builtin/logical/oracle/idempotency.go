@@ -0,0 +1,101 @@
+package oracle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// idempotentIssuance records credentials issued for an idempotency token, so
+// that a retried request with the same token gets them back instead of a
+// new user.
+type idempotentIssuance struct {
+	Data         map[string]interface{} `json:"data" mapstructure:"data" structs:"data"`
+	InternalData map[string]interface{} `json:"internal_data" mapstructure:"internal_data" structs:"internal_data"`
+	TTL          time.Duration          `json:"ttl" mapstructure:"ttl" structs:"ttl"`
+	Renewable    bool                   `json:"renewable" mapstructure:"renewable" structs:"renewable"`
+	Warnings     []string               `json:"warnings" mapstructure:"warnings" structs:"warnings"`
+	ExpiresAt    time.Time              `json:"expires_at" mapstructure:"expires_at" structs:"expires_at"`
+}
+
+// idempotencyKey returns the storage key for an idempotency token. Tokens
+// are scoped to the requesting Vault token and the role, so that one client
+// can't retrieve credentials issued to another by reusing its token.
+func idempotencyKey(req *logical.Request, roleName, token string) string {
+	sum := sha256.Sum256([]byte(req.ClientTokenAccessor + "\x00" + roleName + "\x00" + token))
+	return "idempotency/" + hex.EncodeToString(sum[:])
+}
+
+// lockIdempotencyKey serializes requests using the same idempotency token.
+// The returned function unlocks it.
+func (b *backend) lockIdempotencyKey(key string) func() {
+	lock := locksutil.LockForKey(b.idempotencyLocks, key)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// idempotentIssuance returns the credentials issued for the key, if they
+// were issued within the idempotency window.
+func (b *backend) idempotentIssuance(s logical.Storage, key string) (*idempotentIssuance, error) {
+	entry, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result idempotentIssuance
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	if time.Now().After(result.ExpiresAt) {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+func (b *backend) putIdempotentIssuance(s logical.Storage, key string, resp *logical.Response) error {
+	entry, err := logical.StorageEntryJSON(key, &idempotentIssuance{
+		Data:         resp.Data,
+		InternalData: resp.Secret.InternalData,
+		TTL:          resp.Secret.TTL,
+		Renewable:    resp.Secret.Renewable,
+		Warnings:     resp.Warnings,
+		ExpiresAt:    time.Now().Add(idempotencyWindow),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// tidyIdempotency removes the credentials recorded for idempotency tokens
+// once their window has passed.
+func (b *backend) tidyIdempotency(req *logical.Request) error {
+	keys, err := req.Storage.List("idempotency/")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		issuance, err := b.idempotentIssuance(req.Storage, "idempotency/"+key)
+		if err != nil {
+			return err
+		}
+		if issuance != nil {
+			continue
+		}
+		if err := req.Storage.Delete("idempotency/" + key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/structs"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigConnection(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/connection",
+		Fields: map[string]*framework.FieldSchema{
+			"connection_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "DB connection string",
+			},
+
+			"verify_connection": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Default:     true,
+				Description: `If set, connection_url is verified by actually connecting to the database`,
+			},
+
+			"max_open_connections": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Maximum number of open connections to the database;
+a zero uses the default value of two and a
+negative value means unlimited`,
+			},
+
+			"username_length": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Length of generated usernames, up to 128 on Oracle 12.2
+and later; a zero uses the default value of 30. It is limited to what the
+database accepts.`,
+			},
+
+			"password_mode": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: passwordModeUUID,
+				Description: `How passwords are generated for roles that don't set
+their own mode. Either "uuid" or "strong".`,
+			},
+
+			"tls_client_config": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, and the connection uses TCPS, credentials
+include sqlnet.ora and tnsnames.ora snippets for connecting over TLS`,
+			},
+
+			"wallet_location": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Directory of the wallet holding the trusted certificates
+on clients, used in the sqlnet.ora snippet`,
+			},
+
+			"tracking_table": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Table, optionally qualified with its schema, that a row
+is inserted into for each issuance of credentials and updated on revocation`,
+			},
+
+			"max_idle_connections": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Maximum number of idle connections to the database;
+a zero uses the value of max_open_connections
+and a negative value disables idle connections.
+If larger than max_open_connections it will be
+reduced to the same size.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConnectionWrite,
+			logical.ReadOperation:   b.pathConnectionRead,
+		},
+
+		HelpSynopsis:    pathConfigConnectionHelpSyn,
+		HelpDescription: pathConfigConnectionHelpDesc,
+	}
+}
+
+// pathConnectionRead reads out the connection configuration
+func (b *backend) pathConnectionRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entry, err := req.Storage.Get("config/connection")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connection configuration")
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config connectionConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: structs.New(config).Map(),
+	}, nil
+}
+
+func (b *backend) pathConnectionWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connURL := data.Get("connection_url").(string)
+	if connURL == "" {
+		return logical.ErrorResponse("connection_url parameter must be supplied"), nil
+	}
+
+	maxOpenConns := data.Get("max_open_connections").(int)
+	if maxOpenConns == 0 {
+		maxOpenConns = 2
+	}
+
+	maxIdleConns := data.Get("max_idle_connections").(int)
+	if maxIdleConns == 0 {
+		maxIdleConns = maxOpenConns
+	}
+	if maxIdleConns > maxOpenConns {
+		maxIdleConns = maxOpenConns
+	}
+
+	usernameLength := data.Get("username_length").(int)
+	if usernameLength != 0 && (usernameLength < oracleUsernameLength || usernameLength > oracleLongUsernameLength) {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"username_length must be between %d and %d", oracleUsernameLength, oracleLongUsernameLength)), nil
+	}
+
+	passwordMode := data.Get("password_mode").(string)
+	switch passwordMode {
+	case passwordModeUUID, passwordModeStrong:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid password_mode: %q", passwordMode)), nil
+	}
+
+	tlsClientConfig := data.Get("tls_client_config").(bool)
+	walletLocation := data.Get("wallet_location").(string)
+	if strings.ContainsAny(walletLocation, "()\n") {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid wallet_location: %q", walletLocation)), nil
+	}
+
+	// Don't check the connection_url if verification is disabled
+	verifyConnection := data.Get("verify_connection").(bool)
+	if verifyConnection {
+		// Verify the string
+		db, err := sql.Open(oracleDriverName, connURL)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error validating connection info: %s", err)), nil
+		}
+		defer db.Close()
+		if err := db.Ping(); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error validating connection info: %s", err)), nil
+		}
+
+		if usernameLength > oracleUsernameLength {
+			maxLength, err := detectUsernameLength(db)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"Error validating username_length: %s", err)), nil
+			}
+			if usernameLength > maxLength {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"username_length must be at most %d for this database", maxLength)), nil
+			}
+		}
+	}
+
+	trackingTable := data.Get("tracking_table").(string)
+	if trackingTable != "" && !validQualifiedName(trackingTable) {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid tracking_table: %q", trackingTable)), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/connection", connectionConfig{
+		ConnectionURL:      connURL,
+		MaxOpenConnections: maxOpenConns,
+		MaxIdleConnections: maxIdleConns,
+		UsernameLength:     usernameLength,
+		PasswordMode:       passwordMode,
+		TLSClientConfig:    tlsClientConfig,
+		WalletLocation:     walletLocation,
+		TrackingTable:      trackingTable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	// Reset the DB connection
+	b.ResetDB()
+
+	resp := &logical.Response{}
+	resp.AddWarning("Read access to this endpoint should be controlled via ACLs as it will return the connection string as it is, including passwords, if any.")
+
+	return resp, nil
+}
+
+type connectionConfig struct {
+	ConnectionURL      string `json:"connection_url" structs:"connection_url" mapstructure:"connection_url"`
+	MaxOpenConnections int    `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
+	MaxIdleConnections int    `json:"max_idle_connections" structs:"max_idle_connections" mapstructure:"max_idle_connections"`
+	UsernameLength     int    `json:"username_length" structs:"username_length" mapstructure:"username_length"`
+	PasswordMode       string `json:"password_mode" structs:"password_mode" mapstructure:"password_mode"`
+	TLSClientConfig    bool   `json:"tls_client_config" structs:"tls_client_config" mapstructure:"tls_client_config"`
+	WalletLocation     string `json:"wallet_location" structs:"wallet_location" mapstructure:"wallet_location"`
+	TrackingTable      string `json:"tracking_table" structs:"tracking_table" mapstructure:"tracking_table"`
+}
+
+const pathConfigConnectionHelpSyn = `
+Configure the connection string to talk to Oracle.
+`
+
+const pathConfigConnectionHelpDesc = `
+This path configures the connection string used to connect to Oracle.
+The value of the string is passed to the Oracle driver and takes the
+form of an Easy Connect string with credentials:
+
+"user/password@host:port/service_name"
+
+The connecting user must hold the CREATE USER, ALTER USER, DROP USER and
+ALTER SYSTEM privileges, as well as SELECT on gv$session, and must be able
+to grant the privileges used in role SQL.
+
+When configuring the connection string, the backend will verify its validity.
+
+Generated usernames are 30 characters long by default, the limit prior to
+Oracle 12.2. On later versions, "username_length" can be raised up to 128 for
+more readable usernames. It is limited to what the database accepts, based on
+its COMPATIBLE parameter, which requires SELECT on v$parameter.
+
+Passwords are generated from a UUID by default. Setting "password_mode" to
+"strong" generates mixed case alphanumeric passwords that also include the
+"_", "$" and "#" characters, for databases with strict password verify
+functions. Roles can override the mode. Strong passwords are also generated
+for users whose profile has a password verify function.
+
+When the connection uses TCPS, either as an Easy Connect string such as
+"user/password@tcps://host:2484/service_name" or a connect descriptor with a
+TCPS address, setting "tls_client_config" includes sqlnet.ora and
+tnsnames.ora snippets in generated credentials, so that clients can connect
+over TLS without configuration of their own. "wallet_location" is the
+directory clients keep the wallet holding the trusted certificates in.
+
+Setting "tracking_table" records each issuance of credentials in a table in
+the database, giving DBAs an audit trail that doesn't depend on Vault's logs.
+The table must have the following columns, and the connecting user must be
+able to insert into and update it:
+
+	CREATE TABLE vault_credentials (
+		issuance_id VARCHAR2(36) PRIMARY KEY,
+		username    VARCHAR2(128) NOT NULL,
+		role_name   VARCHAR2(128) NOT NULL,
+		mount_point VARCHAR2(256),
+		issued_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+		revoked_at  TIMESTAMP WITH TIME ZONE
+	)
+
+A row is inserted in the same transaction that creates the user, so
+credentials are not issued if it can't be. Its "revoked_at" is set when the
+lease is revoked; failing to set it is logged rather than failing the
+revocation. Vault assigns lease IDs after the backend returns the
+credentials, so each issuance is identified by a random "issuance_id" that is
+recorded with the lease instead. The table is used from the database the
+connection is made to, even for roles with a container.
+`
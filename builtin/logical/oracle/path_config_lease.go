@@ -0,0 +1,135 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigLease(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/lease",
+		Fields: map[string]*framework.FieldSchema{
+			"lease": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Default lease for roles.",
+			},
+
+			"lease_max": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Maximum time a credential is valid for.",
+			},
+
+			"default_ttl": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Lease for credentials when "lease" isn't set. Defaults
+to 1 hour, or the mount's default lease if that is shorter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathLeaseRead,
+			logical.UpdateOperation: b.pathLeaseWrite,
+		},
+
+		HelpSynopsis:    pathConfigLeaseHelpSyn,
+		HelpDescription: pathConfigLeaseHelpDesc,
+	}
+}
+
+func (b *backend) pathLeaseWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	leaseRaw := d.Get("lease").(string)
+	leaseMaxRaw := d.Get("lease_max").(string)
+
+	// Either lease can be left unset, so that only the default TTL is
+	// configured
+	var lease, leaseMax time.Duration
+	var err error
+	if leaseRaw != "" {
+		lease, err = time.ParseDuration(leaseRaw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Invalid lease: %s", err)), nil
+		}
+	}
+	if leaseMaxRaw != "" {
+		leaseMax, err = time.ParseDuration(leaseMaxRaw)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Invalid lease: %s", err)), nil
+		}
+	}
+	defaultTTL := time.Duration(d.Get("default_ttl").(int)) * time.Second
+	if defaultTTL < 0 {
+		return logical.ErrorResponse("default_ttl must not be negative"), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/lease", &configLease{
+		Lease:      lease,
+		LeaseMax:   leaseMax,
+		DefaultTTL: defaultTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathLeaseRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	lease, err := b.Lease(req.Storage)
+
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"lease":       lease.Lease.String(),
+			"lease_max":   lease.LeaseMax.String(),
+			"default_ttl": int64(lease.DefaultTTL / time.Second),
+		},
+	}, nil
+}
+
+type configLease struct {
+	Lease      time.Duration
+	LeaseMax   time.Duration
+	DefaultTTL time.Duration
+}
+
+const pathConfigLeaseHelpSyn = `
+Configure the default lease information for generated credentials.
+`
+
+const pathConfigLeaseHelpDesc = `
+This configures the default lease information used for credentials
+generated by this backend. The lease specifies the duration that a
+credential will be valid for, as well as the maximum session for
+a set of credentials.
+
+The format for the lease is "1h" or integer and then unit. The longest
+unit is hour.
+
+Renewals use the lease currently configured. The maximum lease is also
+recorded with each set of credentials when it is issued, and renewals are
+capped at the shorter of that value and the current one: shortening the
+maximum lease applies to existing credentials on their next renewal, with a
+warning, while lengthening it only affects credentials issued afterwards.
+
+Until a lease is configured, credentials are issued with the "default_ttl",
+and a warning saying so. It defaults to 1 hour, or the mount's default lease
+if that is shorter, which keeps a new mount from issuing credentials for the
+system default of 32 days. A default_ttl of 0 restores that default.
+`
@@ -0,0 +1,301 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigRevocation(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/revocation",
+		Fields: map[string]*framework.FieldSchema{
+			"revocation_sql": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Default SQL statements to be executed to revoke a user,
+for roles that don't set their own. The '{{name}}' value will be substituted.
+If empty, users are dropped.`,
+			},
+
+			"revocation_package": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Definer-rights PL/SQL package, optionally qualified with
+its schema, through which sessions are killed and users dropped, so that the
+connection user needs no ALTER SYSTEM or DROP USER privilege.`,
+			},
+
+			"session_query_sql": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Query listing the sid, serial#, inst_id and username,
+and optionally the server, of the sessions of the users bound in place of
+'{{usernames}}'. If empty, gv$session is queried.`,
+			},
+
+			"session_kill_sql": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Statement ending a session, with '{{sid}}', '{{serial}}',
+'{{inst_id}}', '{{server}}' and '{{name}}' substituted. If empty, sessions
+are killed with ALTER SYSTEM KILL SESSION ... IMMEDIATE, and shared server
+sessions disconnected with ALTER SYSTEM DISCONNECT SESSION ... IMMEDIATE.`,
+			},
+
+			"drop_retry_period": &framework.FieldSchema{
+				Type:    framework.TypeDurationSecond,
+				Default: int(defaultDropRetryPeriod / time.Second),
+				Description: `How long revocation is retried for while the user is still
+connected, killing its sessions again before each retry. 0 disables retries.`,
+			},
+
+			"revocation_timeout": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long a revocation may take. Statements still running
+once it has passed are aborted, and the revocation fails. 0 waits however
+long revocation takes.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRevocationRead,
+			logical.UpdateOperation: b.pathRevocationWrite,
+		},
+
+		HelpSynopsis:    pathConfigRevocationHelpSyn,
+		HelpDescription: pathConfigRevocationHelpDesc,
+	}
+}
+
+func (b *backend) pathRevocationWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	revocationSQL := data.Get("revocation_sql").(string)
+	revocationPackage := strings.ToUpper(data.Get("revocation_package").(string))
+	if revocationPackage != "" && !validQualifiedName(revocationPackage) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid revocation_package: %q", revocationPackage)), nil
+	}
+	sessionQuerySQL := strings.TrimSpace(data.Get("session_query_sql").(string))
+	if sessionQuerySQL != "" && !strings.Contains(sessionQuerySQL, "{{usernames}}") {
+		return logical.ErrorResponse(`session_query_sql must bind the usernames with '{{usernames}}'`), nil
+	}
+	sessionKillSQL := strings.TrimSpace(data.Get("session_kill_sql").(string))
+	if strings.Contains(sessionKillSQL, ";") {
+		return logical.ErrorResponse("session_kill_sql must be a single statement"), nil
+	}
+	dropRetryPeriod := time.Duration(data.Get("drop_retry_period").(int)) * time.Second
+	if dropRetryPeriod < 0 {
+		return logical.ErrorResponse("drop_retry_period must not be negative"), nil
+	}
+	revocationTimeout := time.Duration(data.Get("revocation_timeout").(int)) * time.Second
+	if revocationTimeout < 0 {
+		return logical.ErrorResponse("revocation_timeout must not be negative"), nil
+	}
+	if _, err := renderStatements(revocationSQL, map[string]string{
+		"name": "foo",
+	}); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error rendering revocation_sql: %s", err)), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/revocation", &revocationConfig{
+		RevocationSQL:     revocationSQL,
+		RevocationPackage: revocationPackage,
+		SessionQuerySQL:   sessionQuerySQL,
+		SessionKillSQL:    sessionKillSQL,
+		DropRetryPeriod:   &dropRetryPeriod,
+		RevocationTimeout: revocationTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRevocationRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.RevocationConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"revocation_sql":     config.RevocationSQL,
+			"revocation_package": config.RevocationPackage,
+			"session_query_sql":  config.SessionQuerySQL,
+			"session_kill_sql":   config.SessionKillSQL,
+			"drop_retry_period":  int64(config.dropRetryPeriod() / time.Second),
+			"revocation_timeout": int64(config.RevocationTimeout / time.Second),
+		},
+	}, nil
+}
+
+type revocationConfig struct {
+	RevocationSQL     string         `json:"revocation_sql" structs:"revocation_sql" mapstructure:"revocation_sql"`
+	RevocationPackage string         `json:"revocation_package" structs:"revocation_package" mapstructure:"revocation_package"`
+	SessionQuerySQL   string         `json:"session_query_sql" structs:"session_query_sql" mapstructure:"session_query_sql"`
+	SessionKillSQL    string         `json:"session_kill_sql" structs:"session_kill_sql" mapstructure:"session_kill_sql"`
+	DropRetryPeriod   *time.Duration `json:"drop_retry_period" structs:"drop_retry_period" mapstructure:"drop_retry_period"`
+	RevocationTimeout time.Duration  `json:"revocation_timeout" structs:"revocation_timeout" mapstructure:"revocation_timeout"`
+}
+
+// revocationSQL returns the SQL used to revoke users of roles that don't
+// set their own. Without a configuration, users are dropped.
+func (c *revocationConfig) revocationSQL() string {
+	if c == nil || c.RevocationSQL == "" {
+		return defaultRevocationSQL
+	}
+	return c.RevocationSQL
+}
+
+// revocationPackage returns the package sessions are killed and users dropped
+// through, or "" if they are killed and dropped directly.
+func (c *revocationConfig) revocationPackage() string {
+	if c == nil {
+		return ""
+	}
+	return c.RevocationPackage
+}
+
+// sessionQuerySQL returns the query listing the sessions of revoked users.
+// Without a configuration, gv$session is queried.
+func (c *revocationConfig) sessionQuerySQL() string {
+	if c == nil || c.SessionQuerySQL == "" {
+		return sessionQuerySQL
+	}
+	return c.SessionQuerySQL
+}
+
+// sessionKillSQL returns the statement ending the sessions of revoked users,
+// other than those of roles disconnecting them once their transaction is
+// done. Without a configuration, sessions are killed right away.
+func (c *revocationConfig) sessionKillSQL() string {
+	if c == nil || c.SessionKillSQL == "" {
+		return sessionKillSQL
+	}
+	return c.SessionKillSQL
+}
+
+// dropRetryPeriod returns how long revocation is retried for while the user
+// is still connected. Without a configuration, or one stored before it was
+// configurable, it is 10 seconds.
+func (c *revocationConfig) dropRetryPeriod() time.Duration {
+	if c == nil || c.DropRetryPeriod == nil {
+		return defaultDropRetryPeriod
+	}
+	return *c.DropRetryPeriod
+}
+
+// revocationTimeout returns how long a revocation may take, or 0 if it may
+// take however long it does.
+func (c *revocationConfig) revocationTimeout() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.RevocationTimeout
+}
+
+const pathConfigRevocationHelpSyn = `
+Configure how users are revoked.
+`
+
+const pathConfigRevocationHelpDesc = `
+This configures the SQL used to revoke users for roles that don't set their
+own "revocation_sql", "revocation_mode" or "revoke_cascade". By default,
+users are revoked with:
+
+	REVOKE CONNECT FROM {{name}};
+	DROP USER {{name}};
+
+Setting "revocation_sql" replaces this for the whole mount, for example to
+call an auditing procedure before dropping users:
+
+	CALL audit_pkg.log_drop('{{name}}');
+	DROP USER {{name}};
+
+It is also used for users whose role has been deleted. Like the SQL of
+roles, it can be a semicolon-separated string, a base64-encoded
+semicolon-separated string, a serialized JSON string array, or a
+base64-encoded serialized JSON string array, and '{{name}}' is substituted
+with the username.
+
+The users' sessions are killed before it runs, unless the role sets
+"skip_session_kill". Sessions can still be opened between then and the
+user being dropped, failing the revocation with ORA-01940. For up to
+"drop_retry_period", 10 seconds by default, the sessions are then killed
+again and the statement retried, waiting longer between each attempt.
+Setting it to 0 fails the revocation right away.
+
+Setting "revocation_timeout" bounds how long a revocation takes, so that a
+drop stuck behind a lock doesn't hold up the revocation of other leases.
+Statements still running once it has passed, counted from the start of the
+revocation, are aborted by killing the session they run in from another
+connection, and the revocation fails. The failure is listed under
+"revocation-failures/", and Vault retries the revocation later. Retries
+after ORA-01940 stop at the timeout too.
+
+Setting "revocation_package" kills sessions and drops users through a
+definer-rights PL/SQL package installed by a DBA, so that the connection
+user only needs EXECUTE on it rather than the ALTER SYSTEM and DROP USER
+privileges. The package must provide:
+
+	PROCEDURE KILL_SESSIONS(username VARCHAR2, container VARCHAR2,
+	                        post_transaction NUMBER);
+	PROCEDURE DROP_USER(username VARCHAR2, cascade NUMBER);
+
+KILL_SESSIONS is called in the database connected to, with the username as
+stored in DBA_USERS, the pluggable database the user lives in, or NULL, and 1
+for roles disconnecting sessions once their transaction is done. DROP_USER
+replaces the DROP USER statements of the revocation SQL, with 1 for CASCADE,
+and is called in the user's container, where the package must be installed
+too. Other statements, such as the REVOKE CONNECT, still run directly. The
+number of sessions killed through the package isn't known, so revocations
+only report the calls made. Scheduler jobs enforcing expiry still kill
+sessions directly.
+
+Setting "session_query_sql" and "session_kill_sql" changes how the sessions
+of revoked users are found and ended, for example to leave out sessions of a
+Resource Manager consumer group, or to end them with DISCONNECT SESSION or a
+procedure of your own. The query must return the sid, serial#, inst_id and
+username, and optionally the server, of the sessions of the users bound in
+place of '{{usernames}}', matching usernames as stored in DBA_USERS, and end
+in a WHERE clause, to which the restriction to the user's container is
+appended:
+
+	SELECT sid, serial#, inst_id, username, server FROM gv$session
+	WHERE username IN ({{usernames}}) AND resource_consumer_group <> 'BATCH'
+
+The kill statement is run for each session found, with '{{sid}}',
+'{{serial}}', '{{inst_id}}', '{{server}}', quoted, and '{{name}}', the
+username as stored, substituted:
+
+	ALTER SYSTEM DISCONNECT SESSION '{{sid}},{{serial}},@{{inst_id}}' IMMEDIATE
+
+Shared server sessions, whose server is SHARED or NONE, are only marked as
+killed by KILL SESSION until their client next makes a call, keeping their
+virtual circuit and dispatcher connection in place, so the default kill
+statement disconnects them instead. Custom session queries must return the
+server column for this.
+
+If the connection user can't read the view the session query reads, failing
+with ORA-00942 or ORA-01031, sessions aren't killed, and revocation goes
+ahead with a warning rather than failing. Dropping the user then only fails
+if it is still connected. Grant the connection user SELECT on gv$session, or
+point "session_query_sql" at a view it can read, to kill sessions again.
+
+Roles with "session_termination" set to "post_transaction" still disconnect
+sessions with POST_TRANSACTION, and neither is used with a
+"revocation_package".
+
+Writing an empty "revocation_sql" restores the default. The configuration in
+effect when a lease is revoked is used, rather than the one in effect when
+it was issued.
+`
@@ -0,0 +1,200 @@
+package oracle
+
+import (
+	"fmt"
+
+	"github.com/fatih/structs"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigUsername(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/username",
+		Fields: map[string]*framework.FieldSchema{
+			"template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Default template for generated usernames, for roles that
+don't set their own. Defaults to the display name followed by a UUID.`,
+			},
+
+			"separator": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "_",
+				Description: `Character joining the parts of the default template and
+replacing the hyphens in the UUID. One of "_", "$" or "#".`,
+			},
+
+			"random": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, usernames are random identifiers, without the
+display name or anything else about the requester, for all roles. The
+templates are then not used.`,
+			},
+
+			"truncation": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: usernameTruncationDisplayName,
+				Description: `How usernames longer than the username length are
+shortened. Either "display_name" to shorten the display name first, "end" to
+cut the end off, or "error" to refuse to issue credentials.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathUsernameRead,
+			logical.UpdateOperation: b.pathUsernameWrite,
+		},
+
+		HelpSynopsis:    pathConfigUsernameHelpSyn,
+		HelpDescription: pathConfigUsernameHelpDesc,
+	}
+}
+
+func (b *backend) pathUsernameWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	template := data.Get("template").(string)
+	if err := validateUsernameTemplate(template); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error rendering template: %s", err)), nil
+	}
+
+	separator := data.Get("separator").(string)
+	switch separator {
+	case "_", "$", "#":
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid separator: %q", separator)), nil
+	}
+
+	truncation := data.Get("truncation").(string)
+	switch truncation {
+	case usernameTruncationDisplayName, usernameTruncationEnd, usernameTruncationError:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid truncation: %q", truncation)), nil
+	}
+
+	random := data.Get("random").(bool)
+	if random && template != "" {
+		return logical.ErrorResponse(`"template" cannot be used with "random"`), nil
+	}
+
+	// Store it
+	entry, err := logical.StorageEntryJSON("config/username", &usernameConfig{
+		Template:   template,
+		Separator:  separator,
+		Truncation: truncation,
+		Random:     random,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathUsernameRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.UsernameConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: structs.New(config).Map(),
+	}, nil
+}
+
+type usernameConfig struct {
+	Template   string `json:"template" structs:"template" mapstructure:"template"`
+	Separator  string `json:"separator" structs:"separator" mapstructure:"separator"`
+	Truncation string `json:"truncation" structs:"truncation" mapstructure:"truncation"`
+	Random     bool   `json:"random" structs:"random" mapstructure:"random"`
+}
+
+// random returns whether usernames are random for all roles.
+func (c *usernameConfig) random() bool {
+	return c != nil && c.Random
+}
+
+// separator returns the character joining the parts of the default
+// template. Without a configuration, it is an underscore.
+func (c *usernameConfig) separator() string {
+	if c == nil || c.Separator == "" {
+		return "_"
+	}
+	return c.Separator
+}
+
+// truncation returns how long usernames are shortened. Without a
+// configuration, the display name is shortened first.
+func (c *usernameConfig) truncation() string {
+	if c == nil || c.Truncation == "" {
+		return usernameTruncationDisplayName
+	}
+	return c.Truncation
+}
+
+// validateUsernameTemplate renders a username template with placeholder
+// values, so that errors in it are caught when it is written rather than
+// when credentials are issued.
+func validateUsernameTemplate(tpl string) error {
+	_, err := renderQuery(tpl, map[string]string{
+		"display_name": "foo",
+		"identity":     "foo",
+		"hint":         "foo",
+		"uuid":         "foo",
+		"role":         "foo",
+	})
+	return err
+}
+
+const pathConfigUsernameHelpSyn = `
+Configure how usernames are generated.
+`
+
+const pathConfigUsernameHelpDesc = `
+This configures how usernames are generated for roles that don't set their
+own "username_template". By default, usernames are the display name of the
+requesting token followed by a UUID, joined by an underscore.
+
+The "template" is rendered in the same way as role SQL, with the following
+values available:
+
+	{{display_name}}  the display name, with invalid characters replaced
+	{{identity}}      the first characters of the requesting token's accessor
+	{{hint}}          the username hint given with the request, if any
+	{{uuid}}          a UUID, with its hyphens replaced by the separator
+	{{role}}          the name of the role, with invalid characters replaced
+
+The template functions available to role SQL can also be used, for example:
+
+	{{role | upper}}_{{display_name}}_{{uuid}}
+
+Unless the role sets "quoted_username", the result must be a valid unquoted
+identifier.
+
+The "separator" joins the parts of the default template and replaces the
+hyphens in the UUID. It can be "_", "$" or "#".
+
+Usernames longer than the username length set on the connection are
+shortened according to "truncation". With "display_name", the display name
+is shortened first, keeping at least 10 characters of it, and then the end
+is cut off if still needed. With "end", the end is cut off right away, and
+with "error", credentials are refused instead.
+
+Setting "random" makes usernames random identifiers for all roles, such as
+"V_0B1C3C5A9F0E4D2A8B6C7D1E2F3A", so that nothing about the requester, such
+as a display name revealing who they are, ends up in DBA_USERS. Templates
+aren't used then, and usernames are cut to the username length without a
+warning, and username hints are left out. Roles can also set
+"random_username" on their own. Service accounts keep their usernames,
+since they must stay the same.
+`
@@ -0,0 +1,158 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathCredsVerify(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name") + "/verify$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the credentials to verify.",
+			},
+
+			"password": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Password of the credentials. If given, the backend also
+tries to log in with them.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCredsVerifyWrite,
+		},
+
+		HelpSynopsis:    pathCredsVerifyHelpSyn,
+		HelpDescription: pathCredsVerifyHelpDesc,
+	}
+}
+
+func (b *backend) pathCredsVerifyWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	username := data.Get("username").(string)
+	password := data.Get("password").(string)
+	if username == "" {
+		return logical.ErrorResponse("username is required"), nil
+	}
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	// Unquoted usernames are stored upper cased
+	storedUsername := username
+	if !role.QuotedUsername {
+		storedUsername = strings.ToUpper(username)
+	}
+
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if role.Container != "" {
+		restoreContainer, err = switchContainer(tx, role.Container)
+		if err != nil {
+			return nil, err
+		}
+		defer restoreContainer()
+	}
+
+	var status, profile string
+	var expiryDate sql.NullString
+	err = tx.QueryRow(fmt.Sprintf(userStatusSQL, quoteLiteral(storedUsername))).Scan(&status, &expiryDate, &profile)
+	if err == sql.ErrNoRows {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"username": username,
+				"exists":   false,
+				"valid":    false,
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not look up user: %s", err)
+	}
+
+	var createSession int
+	if err := tx.QueryRow(fmt.Sprintf(createSessionQuerySQL, quoteLiteral(storedUsername))).Scan(&createSession); err != nil {
+		return nil, fmt.Errorf("could not look up privileges: %s", err)
+	}
+
+	if err := restoreContainer(); err != nil {
+		return nil, err
+	}
+
+	respData := map[string]interface{}{
+		"username":       username,
+		"exists":         true,
+		"account_status": status,
+		"profile":        profile,
+		"create_session": createSession > 0,
+	}
+	if expiryDate.Valid {
+		respData["expiry_date"] = expiryDate.String
+	}
+	valid := status == "OPEN" && createSession > 0
+
+	// Logging in also catches what the data dictionary doesn't show, such as
+	// logon triggers refusing the connection
+	if password != "" {
+		if err := checkLogin(b.ConnectString(), username, password); err != nil {
+			respData["login"] = false
+			respData["login_error"] = err.Error()
+			valid = false
+		} else {
+			respData["login"] = true
+		}
+	}
+	respData["valid"] = valid
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+const pathCredsVerifyHelpSyn = `
+Check that credentials issued for a role still work.
+`
+
+const pathCredsVerifyHelpDesc = `
+This path reports the state of a user in the database, for debugging
+credentials that have stopped working. The user is looked up in the role's
+container, if it has one.
+
+The response includes whether the user exists, its account status and
+password expiry date as reported by DBA_USERS, its profile, and whether it
+has the CREATE SESSION privilege, directly or through a role. "valid" is set
+if the account is open and can create sessions.
+
+If "password" is given, the backend also logs in as the user through the
+connection's connect string, and reports the error if that fails. This also
+catches logon triggers, such as those created for roles with
+"allowed_service", refusing the connection.
+`
@@ -0,0 +1,186 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathProxyCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "proxy-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the proxy role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathProxyCredsRead,
+		},
+
+		HelpSynopsis:    pathProxyCredsHelpSyn,
+		HelpDescription: pathProxyCredsHelpDesc,
+	}
+}
+
+func (b *backend) pathProxyCredsRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role, err := b.ProxyRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown proxy role: %s", name)), nil
+	}
+
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Proxy users are named like other users, with the defaults of a role
+	usernameConfig, err := b.UsernameConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	username, warnings, err := generateUsername(name, req.DisplayName, "", "", &roleEntry{}, usernameConfig, b.UsernameLength())
+	if err != nil {
+		return nil, err
+	}
+	password, err := generatePassword(b.PasswordMode())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Write to the WAL first, so that the user is dropped if its creation
+	// never completes
+	wal := &walUser{
+		Username: username,
+		Role:     name,
+	}
+	walID, err := framework.PutWAL(req.Storage, walTypeUser, wal)
+	if err != nil {
+		return nil, fmt.Errorf("error writing WAL entry: %s", err)
+	}
+	if err := b.putIssuedUser(req.Storage, username, false, name, SecretProxyCredsType); err != nil {
+		return nil, fmt.Errorf("error recording issued user: %s", err)
+	}
+	cleanup := func(err error) error {
+		tx.Rollback()
+		if dropErr := b.dropPartialUser(req.Storage, wal); dropErr != nil {
+			b.logger.Warn("oracle/pathProxyCredsRead: failed to drop partially created user", "username", username, "error", dropErr)
+			return err
+		}
+		if walErr := framework.DeleteWAL(req.Storage, walID); walErr != nil {
+			b.logger.Warn("oracle/pathProxyCredsRead: failed to remove WAL entry", "username", username, "error", walErr)
+		}
+		return err
+	}
+
+	// The password is passed as a bind variable, as for other users
+	placeholderUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, cleanup(err)
+	}
+	placeholder := "VAULT_PASSWORD_" + strings.Replace(placeholderUUID, "-", "", -1)
+	createSQL, args := bindPassword(Query(proxyCreationSQL, map[string]string{
+		"name":     username,
+		"password": placeholder,
+	}), placeholder, password)
+	stmts := []string{
+		createSQL,
+		Query(proxyGrantSessionSQL, map[string]string{"name": username}),
+		proxyGrantThroughSQL(role.TargetSchema, username, role.ProxyRoles),
+	}
+	for i, stmt := range stmts {
+		var stmtArgs []interface{}
+		if i == 0 {
+			stmtArgs = args
+		}
+		if _, err := tx.Exec(stmt, stmtArgs...); err != nil {
+			return nil, cleanup(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, cleanup(err)
+	}
+
+	if err := framework.DeleteWAL(req.Storage, walID); err != nil {
+		return nil, fmt.Errorf("failed to commit WAL entry: %s", err)
+	}
+
+	ttl, defaultTTLUsed := b.leaseTTL(lease)
+	respData := map[string]interface{}{
+		"username":      username,
+		"password":      password,
+		"target_schema": role.TargetSchema,
+		"proxy_user":    fmt.Sprintf("%s[%s]", username, role.TargetSchema),
+		"expiration":    time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		"role":          name,
+	}
+	if connectString := b.ConnectString(); connectString != "" {
+		respData["connect_string"] = connectString
+	}
+	resp := b.Secret(SecretProxyCredsType).Response(respData, map[string]interface{}{
+		"username":      username,
+		"role":          name,
+		"target_schema": role.TargetSchema,
+	})
+	resp.Secret.TTL = ttl
+	if defaultTTLUsed {
+		resp.AddWarning(defaultTTLWarning(ttl))
+	}
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+	return resp, nil
+}
+
+const pathProxyCredsHelpSyn = `
+Request proxy credentials for a proxy role.
+`
+
+const pathProxyCredsHelpDesc = `
+This path creates a proxy user for the role, allowed to connect through the
+role's target schema, and returns its credentials. Connect with the
+"proxy_user", which names the schema in brackets, and the password.
+
+Revoking the lease undoes this in reverse: the permission to connect
+through the schema is revoked, the sessions made through the proxy user are
+killed, and the proxy user is dropped. The revocation returns the number of
+sessions killed and the statements run, as for other credentials.
+
+Sessions made through a proxy user run as the schema, so they are told apart
+from the schema's other sessions by the logon of the proxy user recorded in
+the unified audit trail. Logons through the schema must be audited, for
+example with:
+
+	CREATE AUDIT POLICY vault_proxy_logons ACTIONS LOGON;
+	AUDIT POLICY vault_proxy_logons BY APP;
+
+and the connection user needs SELECT on gv$session and the AUDIT_VIEWER
+role. Without them, no sessions are found, or the revocation goes ahead with
+a warning, and the sessions made through the proxy user are left running.
+`
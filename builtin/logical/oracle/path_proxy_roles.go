@@ -0,0 +1,188 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListProxyRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "proxy-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathProxyRoleList,
+		},
+
+		HelpSynopsis:    pathProxyRoleHelpSyn,
+		HelpDescription: pathProxyRoleHelpDesc,
+	}
+}
+
+func pathProxyRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "proxy-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the proxy role.",
+			},
+
+			"target_schema": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Schema that proxy users created for the role connect
+through. Required.`,
+			},
+
+			"proxy_roles": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Roles of the target schema enabled in sessions made
+through the proxy. If empty, the schema's default roles are enabled.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathProxyRoleRead,
+			logical.UpdateOperation: b.pathProxyRoleCreate,
+			logical.DeleteOperation: b.pathProxyRoleDelete,
+		},
+
+		HelpSynopsis:    pathProxyRoleHelpSyn,
+		HelpDescription: pathProxyRoleHelpDesc,
+	}
+}
+
+func (b *backend) ProxyRole(s logical.Storage, n string) (*proxyRoleEntry, error) {
+	entry, err := s.Get("proxy-role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result proxyRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathProxyRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("proxy-role/")
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathProxyRoleRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.ProxyRole(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"target_schema": role.TargetSchema,
+			"proxy_roles":   role.ProxyRoles,
+		},
+	}, nil
+}
+
+func (b *backend) pathProxyRoleDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete("proxy-role/" + data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (b *backend) pathProxyRoleCreate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	// Unquoted identifiers are stored upper cased, and the schema is looked
+	// up and substituted as one
+	targetSchema := strings.ToUpper(data.Get("target_schema").(string))
+	if targetSchema == "" {
+		return logical.ErrorResponse("target_schema is required"), nil
+	}
+	if !oracleIdentifierRegex.MatchString(targetSchema) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid target_schema: %q", targetSchema)), nil
+	}
+	var proxyRoles []string
+	for _, role := range data.Get("proxy_roles").([]string) {
+		role = strings.ToUpper(strings.TrimSpace(role))
+		if !oracleIdentifierRegex.MatchString(role) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid proxy role: %q", role)), nil
+		}
+		proxyRoles = append(proxyRoles, role)
+	}
+
+	// Check that the schema exists, so that a typo fails now rather than
+	// when credentials are issued
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	exists, err := userExists(tx, targetSchema, false)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return logical.ErrorResponse(fmt.Sprintf("target_schema %s does not exist", targetSchema)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("proxy-role/"+name, &proxyRoleEntry{
+		TargetSchema: targetSchema,
+		ProxyRoles:   proxyRoles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// proxyRoleEntry is a role issuing proxy users, which can only be used to
+// connect through the target schema.
+type proxyRoleEntry struct {
+	TargetSchema string   `json:"target_schema" mapstructure:"target_schema" structs:"target_schema"`
+	ProxyRoles   []string `json:"proxy_roles" mapstructure:"proxy_roles" structs:"proxy_roles"`
+}
+
+const pathProxyRoleHelpSyn = `
+Manage the roles that can issue proxy credentials.
+`
+
+const pathProxyRoleHelpDesc = `
+This path lets you manage the roles that issue proxy credentials: short-lived
+users allowed to connect through an application schema with proxy
+authentication, rather than users with privileges of their own.
+
+Each proxy user is created with CREATE SESSION only, and the target schema
+is altered to GRANT CONNECT THROUGH it. Clients connect as the proxy user,
+naming the schema in brackets, such as "V_WEB_8D8E4A4B[APP]", and their
+sessions run as the schema, with the proxy user recorded in auditing.
+
+If "proxy_roles" is set, sessions made through the proxy only have those
+roles of the target schema enabled. Otherwise, the schema's default roles
+are enabled.
+
+The target schema must exist when the role is written.
+`
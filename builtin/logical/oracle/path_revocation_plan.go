@@ -0,0 +1,253 @@
+package oracle
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRevocationPlan(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revocation-plan/(?P<name>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the user, as stored in DBA_USERS.",
+			},
+
+			"drop_now": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the revocation is planned without the role's
+drop grace period, as when the user is dropped once the period has passed.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRevocationPlanRead,
+			logical.UpdateOperation: b.pathRevocationPlanRead,
+		},
+
+		HelpSynopsis:    pathRevocationPlanHelpSyn,
+		HelpDescription: pathRevocationPlanHelpDesc,
+	}
+}
+
+func (b *backend) pathRevocationPlanRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	// The lease's internal data is kept with the record of the issued user,
+	// and with its revocation failure, if there is one
+	var internalData map[string]interface{}
+	user, err := b.issuedUser(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && user.SecretType != SecretProxyCredsType {
+		internalData = user.InternalData
+	}
+	if internalData == nil {
+		failure, err := b.revocationFailure(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			internalData = failure.InternalData
+		}
+	}
+	if internalData == nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"no record of the lease of user %q; only users issued since leases are recorded can be planned", name)), nil
+	}
+
+	if data.Get("drop_now").(bool) {
+		copied := make(map[string]interface{}, len(internalData)+1)
+		for k, v := range internalData {
+			copied[k] = v
+		}
+		copied["drop_now"] = true
+		internalData = copied
+	}
+
+	return b.revocationPlan(req.Storage, internalData)
+}
+
+// revocationPlan returns the statements revoking the lease with the given
+// internal data would run, the way revokeSecretCreds runs them, without
+// connecting to the database. Statements are listed with the values bound
+// to them.
+func (b *backend) revocationPlan(s logical.Storage, internalData map[string]interface{}) (*logical.Response, error) {
+	target, err := b.revocationTarget(s, internalData)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	stored := storedUsername(target.Username, target.Quoted)
+
+	connConfig, err := b.ConnectionConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	revocationConfig, err := b.RevocationConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	revocationSQL := revocationConfig.revocationSQL()
+
+	resp := &logical.Response{}
+	roleName, _ := internalData["role"].(string)
+	var role *roleEntry
+	if roleName != "" {
+		role, err = b.Role(s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			resp.AddWarning(fmt.Sprintf("Role %q cannot be found. Using default revocation SQL.", roleName))
+		} else {
+			revocationSQL = role.revocationSQL(revocationConfig)
+		}
+	}
+
+	statements := []map[string]interface{}{}
+	add := func(query string, args ...interface{}) {
+		if args == nil {
+			args = []interface{}{}
+		}
+		statements = append(statements, map[string]interface{}{
+			"sql":   query,
+			"binds": args,
+		})
+	}
+
+	// Sessions are looked up and killed before the revocation statements
+	// run, unless the role leaves them
+	dropNow, _ := internalData["drop_now"].(bool)
+	deferred := role != nil && role.DropGracePeriod > 0 && !dropNow
+	generation, _ := internalData["service_account"].(string)
+	killSQL := role.sessionKillSQL(revocationConfig)
+	var sessions map[string]interface{}
+	if (role == nil || !role.SkipSessionKill) && !(deferred && role.DropWhenDrained && generation == "") {
+		if pkg := revocationConfig.revocationPackage(); pkg != "" {
+			postTransaction := 0
+			if killSQL == sessionDisconnectSQL {
+				postTransaction = 1
+			}
+			sessions = map[string]interface{}{
+				"sql":   fmt.Sprintf(packageKillSessionsSQL, pkg),
+				"binds": []interface{}{stored, target.Container, postTransaction},
+			}
+		} else {
+			query, args := sessionQuery(revocationConfig.sessionQuerySQL(), []string{stored}, target.Container)
+			sessions = map[string]interface{}{
+				"query":    query,
+				"binds":    args,
+				"kill_sql": killSQL,
+			}
+		}
+	}
+
+	if role != nil && role.StopJobs && generation == "" {
+		resp.AddWarning("The scheduler jobs owned by the user are disabled and stopped, and the parallel query slaves working for its sessions are killed, before its sessions are killed.")
+	}
+
+	if target.Container != "" {
+		add(fmt.Sprintf(setContainerSQL, target.Container))
+	}
+
+	switch {
+	case generation != "":
+		// Service accounts are only locked, by the lease holding their
+		// current password
+		account, err := b.serviceAccount(s, roleName, target.Username)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil || account.Generation != generation {
+			resp.AddWarning("The lease doesn't hold the current password of the service account, so revoking it leaves the account as it is.")
+			sessions = nil
+			statements = statements[:0]
+			break
+		}
+		add(Query(lockRevocationSQL, map[string]string{
+			"name": target.nameIdentifier(),
+		}))
+
+	case deferred:
+		lockSQL := lockRevocationSQL
+		if role.identification() != identificationPassword {
+			lockSQL = lockNoPasswordRevocationSQL
+		}
+		add(Query(lockSQL, map[string]string{
+			"name": target.nameIdentifier(),
+		}))
+		resp.AddWarning(fmt.Sprintf(
+			"The user is only locked, and dropped once the drop grace period of %s has passed. Set drop_now to plan the drop.",
+			role.DropGracePeriod))
+
+	default:
+		if target.ServiceTrigger != "" {
+			add(fmt.Sprintf(dropTriggerSQL, target.ServiceTrigger))
+		}
+		if target.ExpiryJob != "" {
+			add(fmt.Sprintf(dropJobSQL, target.ExpiryJob))
+		}
+		if role != nil && role.PurgeRecyclebin {
+			resp.AddWarning("The objects of the user in the recycle bin are purged, for each tablespace holding them, before the revocation statements run.")
+		}
+		if role != nil && role.ArchiveSchema != "" {
+			resp.AddWarning(fmt.Sprintf(
+				"The tables owned by the user are copied into %s before the revocation statements run.", role.ArchiveSchema))
+		}
+
+		rendered, err := revocationStatements(revocationSQL, target, revocationConfig.revocationPackage())
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		for _, statement := range rendered {
+			if statement.CheckOwned {
+				resp.AddWarning("The objects owned by the user are looked up before it is dropped, and revocation fails if there are any.")
+			}
+			add(statement.Query, statement.Args...)
+		}
+	}
+
+	var connect string
+	if connConfig != nil {
+		connect = connectString(connConfig.ConnectionURL)
+	}
+	resp.Data = map[string]interface{}{
+		"username":       stored,
+		"role":           roleName,
+		"container":      target.Container,
+		"connect_string": connect,
+		"sessions":       sessions,
+		"statements":     statements,
+	}
+	return resp, nil
+}
+
+const pathRevocationPlanHelpSyn = `
+Show the statements revoking a user would run, without running them.
+`
+
+const pathRevocationPlanHelpDesc = `
+This path returns what revoking the lease of a user would do, under the
+current role and revocation configuration: the connect string and container
+it would run in, how the user's sessions would be looked up and killed, and
+the revocation statements, rendered, with the values bound to them. Nothing
+is run, and the database isn't connected to, so whether the user still
+exists, which sessions it holds, and which objects it owns are not looked up.
+
+It is meant for reviewing changes to "revocation_sql", "config/revocation"
+or a role's revocation settings before leases are revoked with them, and for
+debugging revocations that fail.
+
+The user is given as stored in DBA_USERS, and the lease is found from the
+record the backend keeps of the users it issues, or from its revocation
+failure. Users issued before the lease of each user was recorded can't be
+planned.
+
+For roles with a "drop_grace_period", revocation only locks the user. Set
+"drop_now" to plan the drop that follows once the period has passed.
+`
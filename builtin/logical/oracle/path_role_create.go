@@ -0,0 +1,1250 @@
+package oracle
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRoleCreate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"reason": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reason for requesting the credentials, such as a change
+ticket. Required by roles with "require_reason" set.`,
+			},
+
+			"username_hint": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Short hint included in the generated username, such as a
+job or environment name. Only accepted by roles with "allow_username_hint"
+set.`,
+			},
+
+			"idempotency_token": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Token identifying the request. If a request with the
+same token was made for the role by the same Vault token within the last 10
+minutes, the credentials issued then are returned instead of new ones.`,
+			},
+
+			"variables": &framework.FieldSchema{
+				Type: framework.TypeMap,
+				Description: `Values of variables available to the role's SQL, e.g.
+{"app": "checkout"}. Only the variables the role allows may be given.`,
+			},
+
+			"pgp_key": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64-encoded PGP public key. If given, the password is
+returned encrypted to it, so that only the holder of the private key can
+read it.`,
+			},
+
+			"seps": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the response also includes the configuration for
+storing the credentials in a Secure External Password Store wallet, so
+clients can connect as "/@alias".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleCreateRead,
+			logical.UpdateOperation: b.pathRoleCreateRead,
+		},
+
+		HelpSynopsis:    pathRoleCreateReadHelpSyn,
+		HelpDescription: pathRoleCreateReadHelpDesc,
+	}
+}
+
+func (b *backend) pathRoleCreateRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.logger.Trace("oracle/pathRoleCreateRead: enter")
+	defer b.logger.Trace("oracle/pathRoleCreateRead: exit")
+
+	name := data.Get("name").(string)
+
+	// Check the PGP key before creating the user, so that a bad key doesn't
+	// leave one behind
+	pgpKey := data.Get("pgp_key").(string)
+	var pgpFingerprint string
+	if pgpKey != "" {
+		fingerprints, err := pgpkeys.GetFingerprints([]string{pgpKey}, nil)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing pgp_key: %s", err)), nil
+		}
+		pgpFingerprint = fingerprints[0]
+	}
+
+	// Requests retried with the same idempotency token get the credentials
+	// issued for the first one, rather than another user
+	token := data.Get("idempotency_token").(string)
+	var key string
+	if token != "" {
+		if len(token) > maxIdempotencyTokenLength {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"idempotency_token must be at most %d characters", maxIdempotencyTokenLength)), nil
+		}
+
+		key = idempotencyKey(req, name, token)
+		defer b.lockIdempotencyKey(key)()
+
+		issuance, err := b.idempotentIssuance(req.Storage, key)
+		if err != nil {
+			return nil, err
+		}
+		if issuance != nil {
+			// The stored password is encrypted to the first request's key,
+			// if it gave one, so it can't be returned for another
+			issuedFingerprint, _ := issuance.Data["pgp_fingerprint"].(string)
+			if issuedFingerprint != pgpFingerprint {
+				return logical.ErrorResponse(
+					"pgp_key differs from the one the credentials for this idempotency token were issued with"), nil
+			}
+			b.logger.Trace("oracle/pathRoleCreateRead: returning credentials issued for idempotency token")
+			resp := b.Secret(SecretCredsType).Response(issuance.Data, issuance.InternalData)
+			resp.Secret.TTL = issuance.TTL
+			resp.Secret.Renewable = issuance.Renewable
+			resp.Warnings = issuance.Warnings
+			resp.AddWarning("Returning the credentials issued earlier for this idempotency token")
+			return resp, nil
+		}
+	}
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	seps := data.Get("seps").(bool)
+	if seps && role != nil && role.identification() != identificationPassword {
+		return logical.ErrorResponse(
+			`"seps" requires a role with "identification" set to "password"`), nil
+	}
+	if pgpKey != "" && role != nil && role.identification() != identificationPassword {
+		return logical.ErrorResponse(
+			`"pgp_key" requires a role with "identification" set to "password"`), nil
+	}
+
+	// Refuse requests beyond the role's rate limit before touching the
+	// database
+	if role != nil && role.RateLimit > 0 {
+		if ok, wait := b.limiter.allow(name, role.RateLimit, role.rateLimitPeriod(), time.Now()); !ok {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"rate limit of %d requests per %s exceeded for role %s; retry in %s",
+				role.RateLimit, role.rateLimitPeriod(), name, wait)), nil
+		}
+	}
+
+	// Hand out a pre-created user if the role has a pool, and top the pool
+	// back up in the background. Requests with a username hint or variables
+	// are left to be refused as usual.
+	var resp *logical.Response
+	if role != nil && role.PoolSize > 0 && data.Get("username_hint").(string) == "" &&
+		len(data.Get("variables").(map[string]interface{})) == 0 {
+		reason := strings.TrimSpace(data.Get("reason").(string))
+		if len(reason) > maxReasonLength {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"reason must be at most %d characters", maxReasonLength)), nil
+		}
+		resp, err = b.takePooledCreds(req, name, reason)
+		if err != nil {
+			return nil, err
+		}
+		b.fillPool(req.Storage, req.MountPoint, name)
+		if resp == nil {
+			b.logger.Debug("oracle/pathRoleCreateRead: pool is empty, creating user", "role", name)
+		}
+	}
+
+	// Retry creation on errors caused by a lost connection or a busy
+	// resource. The connection pool discards broken connections itself, and
+	// is shared with other requests, so it is retried on rather than reset.
+	// A user left behind by a failed attempt is dropped by the cleanup or
+	// the WAL rollback.
+	for attempt := 1; resp == nil; attempt++ {
+		resp, err = b.createCreds(req, data)
+		if err == nil || !isTransientError(err) || attempt >= maxCreateAttempts {
+			break
+		}
+
+		b.logger.Warn("oracle/pathRoleCreateRead: transient error creating credentials, retrying", "attempt", attempt, "error", err)
+		time.Sleep(time.Duration(attempt) * createRetryDelay)
+	}
+	if err != nil || resp == nil || resp.IsError() {
+		return resp, err
+	}
+	if seps {
+		b.addSEPSConfig(resp, name)
+	}
+	if pgpKey != "" {
+		if err := encryptPassword(resp, pgpKey); err != nil {
+			// The password can't be returned, so the user is of no use
+			if _, revokeErr := b.revokeCreds(req.Storage, resp.Secret.InternalData); revokeErr != nil {
+				b.logger.Warn("oracle/pathRoleCreateRead: failed to revoke credentials", "username", resp.Data["username"], "error", revokeErr)
+			}
+			return nil, fmt.Errorf("error encrypting password: %s", err)
+		}
+	}
+	if key == "" {
+		return resp, nil
+	}
+
+	// Both leases refer to the same user, so revoking either must tolerate
+	// the user being gone already. The credentials have been issued by now,
+	// so failing to record them only loses the idempotency.
+	resp.Secret.InternalData["idempotency_key"] = key
+	if err := b.putIdempotentIssuance(req.Storage, key, resp); err != nil {
+		b.logger.Warn("oracle/pathRoleCreateRead: failed to record credentials for idempotency token", "error", err)
+	}
+	return resp, nil
+}
+
+// createCreds creates a user for the role and returns its credentials.
+func (b *backend) createCreds(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	reason := strings.TrimSpace(data.Get("reason").(string))
+
+	// Get the role
+	b.logger.Trace("oracle/pathRoleCreateRead: getting role")
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+	if role.RequireReason && reason == "" {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"a reason is required to issue credentials for role %s", name)), nil
+	}
+	if len(reason) > maxReasonLength {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"reason must be at most %d characters", maxReasonLength)), nil
+	}
+	variables, err := parseVariables(data.Get("variables").(map[string]interface{}), role.AllowedVariables)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	hint := sanitizeUsernameHint(data.Get("username_hint").(string))
+	if hint != "" && !role.AllowUsernameHint {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"role %s does not allow username hints", name)), nil
+	}
+
+	// Determine if we have a lease
+	b.logger.Trace("oracle/pathRoleCreateRead: getting lease")
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	// Get our handle
+	b.logger.Trace("oracle/pathRoleCreateRead: getting database handle")
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the configuration the credentials are issued under, so that
+	// renewal and revocation can tell when it has changed since
+	connConfig, err := b.ConnectionConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := configFingerprint(role, connConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the role's setup statements if they haven't been run yet
+	if role.SetupStatements != "" {
+		b.logger.Trace("oracle/pathRoleCreateRead: running setup statements")
+		if err := b.roleSetup(req.Storage, db, name, role); err != nil {
+			return nil, err
+		}
+	}
+
+	// Serialize creation for roles whose SQL can't safely run concurrently.
+	// The lock is held until the user has been created and committed.
+	if role.SerializeCreation {
+		b.logger.Trace("oracle/pathRoleCreateRead: acquiring role lock")
+		lock := locksutil.LockForKey(b.roleLocks, name)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	usernameConfig, err := b.UsernameConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Service accounts have the same username on every request by the same
+	// requester. Requests for the same account are serialized, so that it is
+	// only created once.
+	var username string
+	var usernameWarnings []string
+	if role.ServiceAccount {
+		username, usernameWarnings, err = serviceAccountUsername(name, req.DisplayName, role, usernameConfig, b.UsernameLength())
+		if err != nil {
+			return nil, err
+		}
+		defer b.lockServiceAccount(name, username)()
+	}
+
+	// Start a transaction
+	b.logger.Trace("oracle/pathRoleCreateRead: starting transaction")
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		b.logger.Trace("oracle/pathRoleCreateRead: rolling back transaction")
+		tx.Rollback()
+	}()
+
+	// Statements can't be cancelled through the driver, so those that run
+	// past the role's timeout are aborted by killing the transaction's
+	// session from another connection
+	var killSQL string
+	if role.StatementTimeout > 0 {
+		var session oracleSession
+		if err := tx.QueryRow(currentSessionSQL).Scan(&session.SID, &session.Serial, &session.Instance); err != nil {
+			return nil, err
+		}
+		killSQL = sessionKillStatement(sessionKillSQL, "", session)
+	}
+
+	// Switch to the role's container, if any. The session must be switched
+	// back before the transaction ends, including when it fails.
+	restoreContainer := func() error { return nil }
+	if role.Container != "" {
+		b.logger.Trace("oracle/pathRoleCreateRead: switching container")
+		restoreContainer, err = switchContainer(tx, role.Container)
+		if err != nil {
+			return nil, err
+		}
+		defer restoreContainer()
+	}
+
+	// Generate the username, checking that it isn't already taken. With the
+	// username truncated, collisions are rare but would fail the request.
+	// A service account that already exists has its password rotated
+	// instead of being created.
+	var existingAccount bool
+	if role.ServiceAccount {
+		b.logger.Trace("oracle/pathRoleCreateRead: checking for existing service account")
+		existingAccount, err = userExists(tx, username, role.QuotedUsername)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		identity := requestIdentity(req)
+		for attempt := 1; ; attempt++ {
+			username, usernameWarnings, err = generateUsername(name, req.DisplayName, identity, hint, role, usernameConfig, b.UsernameLength())
+			if err != nil {
+				return nil, err
+			}
+
+			b.logger.Trace("oracle/pathRoleCreateRead: checking for username collision")
+			exists, err := userExists(tx, username, role.QuotedUsername)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				break
+			}
+			if attempt >= maxUsernameAttempts {
+				return nil, fmt.Errorf("could not generate an unused username after %d attempts", attempt)
+			}
+			b.logger.Warn("oracle/pathRoleCreateRead: generated username already exists, retrying", "username", username)
+		}
+	}
+
+	// The username as substituted into the SQL
+	nameIdentifier := username
+	if role.QuotedUsername {
+		nameIdentifier = quoteIdentifier(username)
+	}
+
+	// Externally and globally identified users have an external principal
+	// or directory DN instead of a password
+	var externalName, globalDN string
+	switch role.identification() {
+	case identificationExternal:
+		externalName = Query(role.ExternalName, map[string]string{
+			"name": username,
+		})
+	case identificationGlobal:
+		globalDN = Query(role.GlobalDN, map[string]string{
+			"name": username,
+		})
+	}
+
+	// Keep the role's profile in line with the max TTL, so that the database
+	// expires the user's password even if revocation fails
+	accountOptions := role.AccountOptions
+	if role.ManageProfile {
+		maxTTL := lease.LeaseMax
+		if maxTTL == 0 {
+			maxTTL = b.System().MaxLeaseTTL()
+		}
+
+		b.logger.Trace("oracle/pathRoleCreateRead: updating managed profile")
+		accountOptions.Profile = managedProfileName(name)
+		if err := ensureManagedProfile(tx, accountOptions.Profile, maxTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate the password. Externally and globally identified users have
+	// none. UUID passwords have no upper case letters or special characters,
+	// so strong passwords are generated instead when the user's profile has
+	// a password verify function. They satisfy the functions Oracle ships.
+	var password, passwordMode string
+	if role.identification() == identificationPassword {
+		passwordMode = role.PasswordMode
+		if passwordMode == "" {
+			passwordMode = b.PasswordMode()
+		}
+		if passwordMode == passwordModeUUID {
+			profile := accountOptions.Profile
+			if profile == "" {
+				profile = "DEFAULT"
+			}
+			verifyFunction, err := passwordVerifyFunction(tx, profile)
+			if err != nil {
+				b.logger.Warn("oracle/pathRoleCreateRead: could not look up password verify function", "profile", profile, "error", err)
+			} else if verifyFunction != "" {
+				b.logger.Trace("oracle/pathRoleCreateRead: generating strong password for verify function", "function", verifyFunction)
+				passwordMode = passwordModeStrong
+			}
+		}
+		password, err = generatePassword(passwordMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Roles creating users with IDENTIFIED BY VALUES are given the password's
+	// verifier, so that the password itself is never sent to the database
+	var verifier string
+	if role.IdentifiedByValues {
+		verifier, err = passwordVerifier(password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Without a lease in config/lease, the backend default is used. Leases
+	// can't outlive the max TTL, so a longer lease is capped at it.
+	ttl, defaultTTLUsed := b.leaseTTL(lease)
+	maxTTL := b.System().MaxLeaseTTL()
+	if lease.LeaseMax > 0 && lease.LeaseMax < maxTTL {
+		maxTTL = lease.LeaseMax
+	}
+	var ttlWarning string
+	if maxTTL > 0 && ttl > maxTTL {
+		ttlWarning = fmt.Sprintf("TTL of %s is longer than the max TTL and was capped at %s", ttl, maxTTL)
+		ttl = maxTTL
+	}
+
+	var queries []string
+	var trigger, job string
+	if existingAccount {
+		queries = []string{serviceAccountRotateSQL}
+		if role.IdentifiedByValues {
+			queries = []string{serviceAccountRotateValuesSQL}
+		}
+	} else {
+		queries = strutil.ParseArbitraryStringSlice(role.PreCreationStatements, ";")
+		queries = append(queries, strutil.ParseArbitraryStringSlice(role.SQL, ";")...)
+		if alterUserSQL := accountOptions.alterUserSQL(); alterUserSQL != "" {
+			queries = append(queries, alterUserSQL)
+		}
+		queries = append(queries, objectGrantSQL(role.ObjectGrants, role.GrantOption)...)
+		if role.AllowedService != "" {
+			trigger = serviceTriggerName(username)
+			queries = append(queries, serviceTrigger(trigger, role.AllowedService))
+		}
+		if role.ExpiryEnforcement != "" {
+			// Unquoted usernames are stored upper cased
+			sessionUsername := username
+			if !role.QuotedUsername {
+				sessionUsername = strings.ToUpper(username)
+			}
+			job = expiryJobName(username)
+			queries = append(queries, expiryJob(job, nameIdentifier, sessionUsername, role.ExpiryEnforcement, ttl))
+		}
+		queries = append(queries, strutil.ParseArbitraryStringSlice(role.PostCreationStatements, ";")...)
+	}
+
+	// The password is passed as a bind variable rather than substituted into
+	// the statements, unless the role opts out. To find where it would be
+	// substituted, statements are first rendered with a placeholder.
+	values := map[string]string{
+		"name":          nameIdentifier,
+		"password":      password,
+		"external_name": externalName,
+		"global_dn":     globalDN,
+		"reason":        quoteLiteral(reason),
+	}
+	if role.IdentifiedByValues {
+		values["password_verifier"] = verifier
+	}
+	for variable, value := range variables {
+		values[variable] = quoteLiteral(value)
+	}
+	bindPasswords := password != "" && !role.InlinePassword
+	placeholder := ""
+	if bindPasswords {
+		placeholderUUID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		placeholder = "VAULT_PASSWORD_" + strings.Replace(placeholderUUID, "-", "", -1)
+	}
+
+	// Write to the WAL that this user will be created, so that it is dropped
+	// if its creation never completes. This is done before the user is
+	// created, since a user that can't be rolled back would be orphaned.
+	// Existing service accounts are never dropped.
+	wal := &walUser{
+		Username:       username,
+		Role:           name,
+		Container:      role.Container,
+		QuotedUsername: role.QuotedUsername,
+		ServiceTrigger: trigger,
+		ExpiryJob:      job,
+	}
+	var walID string
+	if !existingAccount {
+		walID, err = framework.PutWAL(req.Storage, walTypeUser, wal)
+		if err != nil {
+			return nil, fmt.Errorf("error writing WAL entry: %s", err)
+		}
+	}
+	if err := b.putIssuedUser(req.Storage, username, role.QuotedUsername, name, SecretCredsType); err != nil {
+		return nil, fmt.Errorf("error recording issued user: %s", err)
+	}
+
+	// If creation fails part way through, drop whatever was created so far.
+	// DDL commits implicitly, so rolling back the transaction doesn't undo
+	// it. Should dropping the user fail too, it is left to the WAL rollback.
+	// The outcome of the cleanup is kept for reporting.
+	var cleanupOutcome string
+	cleanup := func(err error) error {
+		restoreContainer()
+		tx.Rollback()
+		if existingAccount {
+			cleanupOutcome = "the existing service account was left in place"
+			return err
+		}
+
+		b.logger.Trace("oracle/pathRoleCreateRead: dropping partially created user")
+		if dropErr := b.dropPartialUser(req.Storage, wal); dropErr != nil {
+			b.logger.Warn("oracle/pathRoleCreateRead: failed to drop partially created user", "username", username, "error", dropErr)
+			cleanupOutcome = fmt.Sprintf("dropping user %s failed (%s), so it will be dropped by the WAL rollback", username, dropErr)
+			return err
+		}
+		if walErr := framework.DeleteWAL(req.Storage, walID); walErr != nil {
+			b.logger.Warn("oracle/pathRoleCreateRead: failed to remove WAL entry", "username", username, "error", walErr)
+		}
+		cleanupOutcome = fmt.Sprintf("user %s was dropped", username)
+		return err
+	}
+
+	// execQuery renders a query with the current password and executes it
+	execQuery := func(query string) error {
+		var err error
+		var args []interface{}
+		rendered := ""
+		if bindPasswords {
+			values["password"] = placeholder
+			rendered, err = renderQuery(query, values)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(rendered, placeholder) {
+				rendered, args = bindPassword(rendered, placeholder, password)
+			} else {
+				rendered = ""
+			}
+		}
+
+		// Statements that don't use the password, or only use it through a
+		// template function, are rendered with it as is
+		if rendered == "" {
+			values["password"] = password
+			rendered, err = renderQuery(query, values)
+			if err != nil {
+				return err
+			}
+		}
+
+		b.logger.Trace("oracle/pathRoleCreateRead: preparing statement")
+		stmt, err := tx.Prepare(rendered)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		b.logger.Trace("oracle/pathRoleCreateRead: executing statement")
+		return execWithTimeout(db, stmt, args, role.StatementTimeout, killSQL)
+	}
+
+	// Execute each query. In statement mode, each one is committed as soon as
+	// it has run, and a failure reports which statement failed and how it was
+	// cleaned up. The failed statement is reported as written in the role,
+	// so the password is never included.
+	var stmts []string
+	for _, query := range queries {
+		if query = strings.TrimSpace(query); len(query) > 0 {
+			stmts = append(stmts, query)
+		}
+	}
+	statementMode := role.executionMode() == executionModeStatement
+	for i, query := range stmts {
+		// When the password verify function rejects the password, generate
+		// a new one and try the statement again. Retries use strong passwords,
+		// since another UUID password would most likely be rejected too.
+		err := execQuery(query)
+		for attempt := 1; err != nil && password != "" && isPasswordVerifyError(err); attempt++ {
+			if attempt >= maxPasswordAttempts {
+				err = fmt.Errorf("password rejected %d times by the password verify function: %s", attempt, err)
+				break
+			}
+			b.logger.Debug("oracle/pathRoleCreateRead: password rejected, generating a new one", "mode", passwordMode, "error", err)
+			passwordMode = passwordModeStrong
+			if password, err = generatePassword(passwordMode); err != nil {
+				break
+			}
+			if role.IdentifiedByValues {
+				if values["password_verifier"], err = passwordVerifier(password); err != nil {
+					break
+				}
+			}
+			err = execQuery(query)
+		}
+		if err == nil && statementMode {
+			_, err = tx.Exec(commitSQL)
+		}
+		if err != nil {
+			if !statementMode {
+				return nil, cleanup(err)
+			}
+			cleanup(err)
+			committed := "no earlier statements had run"
+			switch {
+			case i == 1:
+				committed = "statement 1 had been committed"
+			case i > 1:
+				committed = fmt.Sprintf("statements 1 to %d had been committed", i)
+			}
+			return nil, fmt.Errorf("statement %d of %d failed: %s\n%s\n%s, and %s",
+				i+1, len(stmts), err, query, committed, cleanupOutcome)
+		}
+	}
+
+	// Summarize what the user has been granted, while still in its
+	// container. The summary is informational, so failing to build it
+	// doesn't fail the request.
+	var grants map[string][]string
+	var grantsErr error
+	if role.IncludeGrants {
+		b.logger.Trace("oracle/pathRoleCreateRead: summarizing grants")
+		grants, grantsErr = grantsSummary(tx, username, role.QuotedUsername)
+		if grantsErr != nil {
+			b.logger.Warn("oracle/pathRoleCreateRead: failed to summarize grants", "username", username, "error", grantsErr)
+		}
+	}
+
+	if err := restoreContainer(); err != nil {
+		return nil, cleanup(err)
+	}
+
+	// Record the issuance in the tracking table, if there is one. It is
+	// committed along with the user, so that every user is accounted for.
+	var trackingID string
+	trackingTable := b.TrackingTable()
+	if trackingTable != "" {
+		trackingID, err = uuid.GenerateUUID()
+		if err != nil {
+			return nil, cleanup(err)
+		}
+		b.logger.Trace("oracle/pathRoleCreateRead: recording issuance in tracking table")
+		if _, err := tx.Exec(fmt.Sprintf(trackingInsertSQL, trackingTable),
+			trackingID, username, name, req.MountPoint); err != nil {
+			return nil, cleanup(fmt.Errorf("could not record issuance in %s: %s", trackingTable, err))
+		}
+	}
+
+	// Commit the transaction
+	b.logger.Trace("oracle/pathRoleCreateRead: committing transaction")
+	if err := tx.Commit(); err != nil {
+		return nil, cleanup(err)
+	}
+
+	// Log in as the new user, so that a missing CREATE SESSION grant or a
+	// locked profile fails the request rather than the application. The user
+	// is dropped if it can't log in.
+	if role.VerifyLogin {
+		b.logger.Trace("oracle/pathRoleCreateRead: verifying login")
+		if err := checkLogin(b.ConnectString(), username, password); err != nil {
+			return nil, cleanup(fmt.Errorf("created user could not log in: %s", err))
+		}
+	}
+
+	// Remove the WAL entry now that the user has been created. If this
+	// fails, the user will be rolled back, so the credentials can't be
+	// returned.
+	if walID != "" {
+		if err := framework.DeleteWAL(req.Storage, walID); err != nil {
+			return nil, fmt.Errorf("failed to commit WAL entry: %s", err)
+		}
+	}
+
+	// Record which issuance holds the service account's current password,
+	// so that revoking the leases of earlier ones leaves the account alone
+	var generation string
+	if role.ServiceAccount {
+		generation, err = uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		if err := b.putServiceAccount(req.Storage, name, username, &serviceAccount{
+			Generation: generation,
+			LastIssued: time.Now().UTC(),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to record service account issuance: %s", err)
+		}
+	}
+
+	// Record the issuance for the role's summary. The user already exists at
+	// this point, so a failure here shouldn't fail the request.
+	b.logger.Trace("oracle/pathRoleCreateRead: recording issuance")
+	entry, err := logical.StorageEntryJSON("issuance/"+name, &roleIssuance{
+		LastIssued: time.Now().UTC(),
+	})
+	if err == nil {
+		err = req.Storage.Put(entry)
+	}
+	if err != nil {
+		b.logger.Warn("oracle/pathRoleCreateRead: failed to record issuance", "role", name, "error", err)
+	}
+
+	// Return the secret
+	b.logger.Trace("oracle/pathRoleCreateRead: generating secret")
+	// Include when the credentials expire, so consumers don't have to work
+	// it out from the lease duration
+	respData := map[string]interface{}{
+		"username":   username,
+		"expiration": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		"role":       name,
+	}
+
+	// Users of roles with a container live in that pluggable database
+	dbName := role.Container
+	if dbName == "" {
+		dbName = b.DBName()
+	}
+	if dbName != "" {
+		respData["db_name"] = dbName
+	}
+	if connectString := b.ConnectString(); connectString != "" {
+		respData["connect_string"] = connectString
+		respData["jdbc_url"] = jdbcURL(connectString)
+
+		// Include the client configuration for connecting over TLS
+		if tlsClientConfig, walletLocation := b.TLSClientConfig(); tlsClientConfig && usesTCPS(connectString) {
+			respData["tnsnames_ora"] = tnsnamesEntry(tnsAlias(name), connectString)
+			respData["sqlnet_ora"] = sqlnetConfig(walletLocation)
+			if walletLocation != "" {
+				respData["wallet_location"] = walletLocation
+			}
+		}
+	}
+	if grants != nil {
+		respData["grants"] = grants
+	}
+	switch role.identification() {
+	case identificationExternal:
+		if externalName != "" {
+			respData["external_name"] = externalName
+		}
+	case identificationGlobal:
+		respData["global_dn"] = globalDN
+	default:
+		respData["password"] = password
+	}
+	resp := b.Secret(SecretCredsType).Response(respData, map[string]interface{}{
+		"username":           username,
+		"role":               name,
+		"container":          role.Container,
+		"reason":             reason,
+		"variables":          variables,
+		"token_accessor":     req.ClientTokenAccessor,
+		"quoted_username":    role.QuotedUsername,
+		"service_trigger":    trigger,
+		"expiry_job":         job,
+		"service_account":    generation,
+		"tracking_table":     trackingTable,
+		"tracking_id":        trackingID,
+		"max_ttl":            int64(maxTTL / time.Second),
+		"issued_at":          time.Now().UTC().Format(time.RFC3339),
+		"config_fingerprint": fingerprint,
+		"db_name":            b.DBName(),
+	})
+	resp.Secret.TTL = ttl
+
+	// The lease's internal data is kept with the record of the user, so that
+	// the role's users can all be revoked at once. Without it, the user is
+	// only left out of revoke-all.
+	if err := b.putIssuedUserData(req.Storage, username, role.QuotedUsername, name, resp.Secret.InternalData); err != nil {
+		b.logger.Warn("oracle/pathRoleCreateRead: failed to record lease data", "username", username, "error", err)
+		resp.AddWarning(fmt.Sprintf("Could not record the lease for revoke-all: %s", err))
+	}
+	if defaultTTLUsed {
+		resp.AddWarning(defaultTTLWarning(ttl))
+	}
+	if ttlWarning != "" {
+		resp.AddWarning(ttlWarning)
+	}
+	resp.Secret.Renewable = role.renewable()
+	for _, warning := range usernameWarnings {
+		resp.AddWarning(warning)
+	}
+	if grantsErr != nil {
+		resp.AddWarning(fmt.Sprintf("Could not summarize grants: %s", grantsErr))
+	}
+	return resp, nil
+}
+
+// encryptPassword replaces the password in the response with the base64
+// encoding of its encryption to the PGP key, and adds the key's fingerprint.
+func encryptPassword(resp *logical.Response, pgpKey string) error {
+	password, _ := resp.Data["password"].(string)
+	fingerprints, encrypted, err := pgpkeys.EncryptShares([][]byte{[]byte(password)}, []string{pgpKey})
+	if err != nil {
+		return err
+	}
+	resp.Data["password"] = base64.StdEncoding.EncodeToString(encrypted[0])
+	resp.Data["pgp_fingerprint"] = fingerprints[0]
+	return nil
+}
+
+// addSEPSConfig adds what clients need to store the credentials in a Secure
+// External Password Store wallet to the response: the alias to store them
+// under, its tnsnames.ora entry, the sqlnet.ora settings, and the mkstore
+// command to run. Wallets are stored in Oracle's own format, so the wallet
+// itself is left to mkstore.
+func (b *backend) addSEPSConfig(resp *logical.Response, roleName string) {
+	connectString := b.ConnectString()
+	if connectString == "" {
+		resp.AddWarning("The connect string is unknown, so no wallet configuration is included")
+		return
+	}
+	_, walletLocation := b.TLSClientConfig()
+	mkstoreLocation := walletLocation
+	if mkstoreLocation == "" {
+		mkstoreLocation = "."
+	}
+
+	alias := tnsAlias(roleName)
+	resp.Data["seps_alias"] = alias
+	resp.Data["seps_tnsnames_ora"] = tnsnamesEntry(alias, connectString)
+	resp.Data["seps_sqlnet_ora"] = sepsSqlnetConfig(walletLocation)
+	resp.Data["seps_mkstore_command"] = fmt.Sprintf(sepsMkstoreTemplate, mkstoreLocation, alias, resp.Data["username"])
+}
+
+// parseVariables returns the values of the role's allowed variables from
+// those supplied with a request. Allowed variables that aren't supplied are
+// empty, so that templates referencing them still render.
+func parseVariables(raw map[string]interface{}, allowed []string) (map[string]string, error) {
+	variables := make(map[string]string, len(allowed))
+	for _, name := range allowed {
+		variables[name] = ""
+	}
+	for name, value := range raw {
+		if !strutil.StrListContains(allowed, name) {
+			return nil, fmt.Errorf("variable %q is not allowed by the role", name)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of variable %q must be a string", name)
+		}
+		if len(s) > maxVariableLength {
+			return nil, fmt.Errorf("value of variable %q must be at most %d characters", name, maxVariableLength)
+		}
+		variables[name] = s
+	}
+	return variables, nil
+}
+
+// requestIdentity returns the identity of the requester included in
+// usernames: the start of the token's accessor, which, unlike the token, can
+// be shared with DBAs.
+func requestIdentity(req *logical.Request) string {
+	identity := strings.Replace(req.ClientTokenAccessor, "-", "", -1)
+	if len(identity) > identityLength {
+		identity = identity[:identityLength]
+	}
+	return identity
+}
+
+// sanitizeDisplayName makes a display name usable at the start of an
+// unquoted identifier. Display names from LDAP or OIDC logins can contain
+// hyphens, dots, "@" and non-ASCII characters, so every character other than
+// an ASCII letter, digit or underscore is replaced with an underscore, and
+// runs of them are collapsed. The result always starts with a letter.
+func sanitizeDisplayName(displayName string) string {
+	var buf bytes.Buffer
+	for _, c := range displayName {
+		if c < utf8.RuneSelf && (isLetter(byte(c)) || c >= '0' && c <= '9') {
+			buf.WriteRune(c)
+			continue
+		}
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '_' {
+			buf.WriteByte('_')
+		}
+	}
+
+	sanitized := strings.TrimRight(buf.String(), "_")
+	switch {
+	case sanitized == "":
+		return "vault"
+	case !isLetter(sanitized[0]):
+		return "v" + sanitized
+	}
+	return sanitized
+}
+
+// sanitizeUsernameHint keeps the ASCII letters, digits and underscores of a
+// username hint, up to maxUsernameHintLength of them.
+func sanitizeUsernameHint(hint string) string {
+	var buf bytes.Buffer
+	for _, c := range hint {
+		if c < utf8.RuneSelf && (isLetter(byte(c)) || c >= '0' && c <= '9' || c == '_') {
+			buf.WriteRune(c)
+		}
+	}
+
+	sanitized := buf.String()
+	if len(sanitized) > maxUsernameHintLength {
+		sanitized = sanitized[:maxUsernameHintLength]
+	}
+	return sanitized
+}
+
+// generateUsername generates a username of at most the given length for the
+// role. Unless the role or the configuration sets a template, it is made of
+// the display name, the requester's identity if the role includes it, the
+// username hint if one was given, and a UUID. The display name is sanitized
+// first, and longer usernames keep more of it.
+func generateUsername(roleName, displayName, identity, hint string, role *roleEntry, config *usernameConfig, length int) (string, []string, error) {
+	userUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", nil, err
+	}
+	separator := config.separator()
+
+	// Random usernames leave out anything about the requester, so templates
+	// aren't used for them
+	if role.RandomUsername || config.random() {
+		username, err := finishUsername(randomUsername(userUUID, separator, length), role)
+		return username, nil, err
+	}
+
+	// Hyphens are not valid in unquoted Oracle identifiers, so they are
+	// replaced in the UUID
+	userUUID = strings.Replace(userUUID, "-", separator, -1)
+
+	tpl := role.UsernameTemplate
+	if tpl == "" && config != nil {
+		tpl = config.Template
+	}
+	if tpl == "" {
+		tpl = "{{display_name}}" + separator
+		if role.UsernameIdentity {
+			tpl += "{{identity}}" + separator
+		}
+		if hint != "" {
+			tpl += "{{hint}}" + separator
+		}
+		tpl += "{{uuid}}"
+	}
+
+	render := func(displayName string) (string, error) {
+		return renderQuery(tpl, map[string]string{
+			"display_name": displayName,
+			"identity":     identity,
+			"hint":         hint,
+			"uuid":         userUUID,
+			"role":         sanitizeDisplayName(roleName),
+		})
+	}
+
+	displayName = sanitizeDisplayName(displayName)
+	username, err := render(displayName)
+	if err != nil {
+		return "", nil, err
+	}
+	var warnings []string
+	if len(username) > length {
+		switch config.truncation() {
+		case usernameTruncationError:
+			return "", nil, fmt.Errorf("generated username %q is longer than %d characters", username, length)
+		case usernameTruncationDisplayName:
+			keep := len(displayName) - (len(username) - length)
+			if keep < oracleDisplayNameLength {
+				keep = oracleDisplayNameLength
+			}
+			if keep < len(displayName) {
+				username, err = render(displayName[:keep])
+				if err != nil {
+					return "", nil, err
+				}
+				warnings = append(warnings, fmt.Sprintf(
+					"display name %q was shortened to %q to fit the username length of %d",
+					displayName, displayName[:keep], length))
+			}
+		}
+		if len(username) > length {
+			username = username[:length]
+			warnings = append(warnings, fmt.Sprintf(
+				"username was truncated to the username length of %d", length))
+		}
+	}
+
+	username, err = finishUsername(username, role)
+	if err != nil {
+		return "", nil, err
+	}
+	return username, warnings, nil
+}
+
+// randomUsername returns a username made of a fixed prefix and the hex
+// digits of the UUID, cut to the username length. The prefix makes it a
+// valid identifier, which can't start with a digit.
+func randomUsername(userUUID, separator string, length int) string {
+	username := randomUsernamePrefix + separator + strings.Replace(userUUID, "-", "", -1)
+	if len(username) > length {
+		username = username[:length]
+	}
+	return username
+}
+
+// finishUsername applies the role's username case to a generated username
+// and checks that it can be used.
+func finishUsername(username string, role *roleEntry) (string, error) {
+	switch role.usernameCase() {
+	case usernameCaseUpper:
+		username = strings.ToUpper(username)
+	case usernameCaseLower:
+		username = strings.ToLower(username)
+	}
+	if role.QuotedUsername {
+		username = strings.Replace(username, `"`, "", -1)
+	} else if !oracleIdentifierRegex.MatchString(username) {
+		return "", fmt.Errorf("generated username %q is not a valid identifier", username)
+	}
+	if username == "" {
+		return "", fmt.Errorf("generated username is empty")
+	}
+	return username, nil
+}
+
+// execWithTimeout executes the statement, killing the session it runs in
+// with killSQL if it hasn't completed within the timeout. A zero timeout
+// waits for the statement however long it takes.
+func execWithTimeout(db *sql.DB, stmt *sql.Stmt, args []interface{}, timeout time.Duration, killSQL string) error {
+	if timeout <= 0 {
+		_, err := stmt.Exec(args...)
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := stmt.Exec(args...)
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	}
+
+	// The statement fails once its session is killed, and has to have
+	// returned before the transaction can be used again
+	_, killErr := db.Exec(killSQL)
+	<-done
+	if killErr != nil {
+		return fmt.Errorf("statement did not complete within %s, and its session could not be killed: %s", timeout, killErr)
+	}
+	return fmt.Errorf("statement did not complete within %s", timeout)
+}
+
+// roleSetup runs the role's setup statements, unless the same statements
+// have already been run for the role. They run in their own transaction,
+// and are recorded as done as soon as it commits, so a failure creating the
+// user doesn't cause them to run again.
+func (b *backend) roleSetup(s logical.Storage, db *sql.DB, name string, role *roleEntry) error {
+	// Hold the role lock so concurrent requests don't run the setup twice
+	lock := locksutil.LockForKey(b.roleLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	hash := setupStatementsHash(role.SetupStatements)
+	state, err := b.roleSetupState(s, name)
+	if err != nil {
+		return err
+	}
+	if state != nil && state.StatementsHash == hash {
+		return nil
+	}
+
+	stmts, err := renderStatements(role.SetupStatements, nil)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if role.Container != "" {
+		restoreContainer, err = switchContainer(tx, role.Container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error running setup statements: %s", err)
+		}
+	}
+
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON("setup/"+name, &roleSetupState{
+		StatementsHash: hash,
+		CompletedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+const pathRoleCreateReadHelpSyn = `
+Request database credentials for a certain role.
+`
+
+const pathRoleCreateReadHelpDesc = `
+This path reads database credentials for a certain role. The
+database credentials will be generated on demand and will be automatically
+revoked when the lease is up. The time at which the lease expires, unless
+renewed, is returned as "expiration". The name of the role is returned as
+"role", and the name of the database the user was created in as "db_name":
+the role's container if it has one, or else the database connected to.
+
+Users are recorded in the write-ahead log before they are created. If their
+creation never completes, for instance because Vault stops part way through,
+they are dropped by the periodic rollback once the entry is five minutes old.
+If one of the role's statements fails, whatever the earlier statements created
+is dropped straight away, and the error from the database is returned.
+Roles with a "statement_timeout" abort statements that take longer, by
+killing the session they run in, and drop the partially created user.
+Creation is retried up to three times on errors caused by a lost connection,
+such as ORA-03113 or ORA-03135, or by a busy resource.
+
+Roles with "include_grants" set also return "grants", listing the system
+privileges, roles and object privileges granted directly to the user.
+
+The name of the database connected to is recorded with the lease. If
+config/connection has been pointed at another database by the time the lease
+is revoked, revocation fails, and is recorded under "revocation-failures/",
+rather than succeeding without finding the user and leaving it behind.
+
+If the display name or the username had to be shortened to fit the username
+length, or the lease was capped at the max TTL, the response includes
+warnings saying so.
+
+The address of the database, taken from the connection string without its
+credentials, is returned as "connect_string", so that applications can
+connect using the credentials alone. The same address is returned as
+"jdbc_url" in the format of the Oracle JDBC thin driver.
+
+If the connection uses TCPS and "tls_client_config" is set on the connection,
+snippets of sqlnet.ora and tnsnames.ora for connecting over TLS are returned
+as "sqlnet_ora" and "tnsnames_ora", along with the "wallet_location" clients
+should keep the trusted certificates in, if one is configured.
+
+A "reason" can be given by writing to this path instead of reading it. It is
+recorded with the lease and is available to the role's SQL. Roles with
+"require_reason" set refuse to issue credentials without one.
+
+Clients that retry requests can give an "idempotency_token". If a request
+with the same token was made for the role with the same Vault token within
+the last 10 minutes, the credentials issued for it are returned again rather
+than creating another user. The credentials are kept in Vault's storage for
+that long. Both leases refer to the same user, so revoking either revokes the
+credentials, and revoking the other once the user is gone succeeds.
+
+Roles can also allow "variables" to be given in the same way, such as the
+application the credentials are for. Their values are available to the
+role's SQL as '{{app}}', with single quotes doubled, so they are meant to be
+used within string literals.
+
+Setting "seps" returns what clients need to keep the credentials in a Secure
+External Password Store wallet and connect as "/@alias" without changing
+their code: the alias as "seps_alias", its tnsnames.ora entry as
+"seps_tnsnames_ora", the sqlnet.ora settings as "seps_sqlnet_ora", and the
+mkstore command adding the credentials to the wallet as
+"seps_mkstore_command". mkstore prompts for the password, so it doesn't
+appear in the command line. The wallet is the connection's
+"wallet_location", if set, or the current directory. When later credentials
+replace them, use -modifyCredential instead of -createCredential.
+
+If a base64-encoded PGP public key is given as "pgp_key", the password is
+returned encrypted to it and base64 encoded, along with the key's
+fingerprint as "pgp_fingerprint". This way, whoever relays the request, such
+as a break-glass tool, never sees the password. Decrypt it with:
+
+  echo "<password>" | base64 -d | gpg -d
+`
@@ -0,0 +1,105 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRoleRevokeAll(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name") + "/revoke-all$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRoleRevokeAllWrite,
+		},
+
+		HelpSynopsis:    pathRoleRevokeAllHelpSyn,
+		HelpDescription: pathRoleRevokeAllHelpDesc,
+	}
+}
+
+func (b *backend) pathRoleRevokeAllWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	// Credentials waiting in the pool would otherwise be handed out after
+	// the role's users have been revoked
+	if err := b.drainPool(req.Storage, name); err != nil {
+		return nil, err
+	}
+
+	storedNames, err := req.Storage.List(issuedUserPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{}
+	revoked := []string{}
+	skipped := []string{}
+	for _, storedName := range storedNames {
+		user, err := b.issuedUser(req.Storage, storedName)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || user.Role != name || user.SecretType == SecretProxyCredsType {
+			continue
+		}
+
+		// Users issued before the internal data of their lease was kept, or
+		// whose creation is still in progress, can't be revoked from here
+		if user.InternalData == nil {
+			skipped = append(skipped, storedName)
+			continue
+		}
+
+		// The role's users are revoked right away, without any grace period
+		b.logger.Info("oracle/revokeAll: revoking user", "role", name, "username", storedName)
+		if _, err := b.revokeCreds(req.Storage, user.InternalData); err != nil {
+			resp.AddWarning(fmt.Sprintf("Could not revoke user %s: %s", storedName, err))
+			continue
+		}
+		revoked = append(revoked, storedName)
+	}
+
+	if len(skipped) > 0 {
+		resp.AddWarning(fmt.Sprintf(
+			"Users issued without a record of their lease were not revoked: %s. Revoke their leases with sys/revoke-prefix/%screds/%s.",
+			strings.Join(skipped, ", "), req.MountPoint, name))
+	}
+	resp.Data = map[string]interface{}{
+		"revoked": revoked,
+		"skipped": skipped,
+	}
+	return resp, nil
+}
+
+const pathRoleRevokeAllHelpSyn = `
+Revoke all the users issued for a role.
+`
+
+const pathRoleRevokeAllHelpDesc = `
+This path revokes every user issued for the role that hasn't been revoked
+yet, so that the credentials of a compromised role can be invalidated in one
+call. The users are revoked as their leases would be, but right away: a
+"drop_grace_period" is not applied. Credentials waiting in the role's pool
+are revoked too. The role itself is left as it is, and can also have been
+deleted.
+
+The leases of the revoked users are left in Vault, and succeed without doing
+anything once they expire or are revoked. To remove them as well, revoke
+them with sys/revoke-prefix on the role's creds path.
+
+Users are found from the record the backend keeps of the users it issues.
+Users issued before the lease of each user was recorded are listed as
+"skipped" and are not revoked; revoke their leases with sys/revoke-prefix
+instead.
+`
@@ -0,0 +1,93 @@
+package oracle
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRoleRollback(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name") + "/rollback$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"version": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Version of the role to restore. Defaults to the version
+before the current one.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRoleRollbackWrite,
+		},
+
+		HelpSynopsis:    pathRoleRollbackHelpSyn,
+		HelpDescription: pathRoleRollbackHelpDesc,
+	}
+}
+
+func (b *backend) pathRoleRollbackWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	version := data.Get("version").(int)
+
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+	}
+
+	history, err := b.roleHistory(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil || len(history.Versions) == 0 {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"role %s has no previous versions", name)), nil
+	}
+
+	var previous *roleEntry
+	if version == 0 {
+		previous = history.Versions[len(history.Versions)-1]
+	} else {
+		for _, entry := range history.Versions {
+			if entry.Version == version {
+				previous = entry
+				break
+			}
+		}
+	}
+	if previous == nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"version %d of role %s not found", version, name)), nil
+	}
+
+	// The restored definition is stored as a new version, so the rollback can
+	// itself be undone
+	if err := b.putRole(req.Storage, name, previous); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathRoleRollbackHelpSyn = `
+Restore a previous version of a role.
+`
+
+const pathRoleRollbackHelpDesc = `
+This path restores a role to one of its previous versions. Up to 10 previous
+versions are kept for each role. If "version" is not given, the version
+before the current one is restored.
+
+The restored definition is stored as a new version of the role, so that the
+current definition is kept and the rollback itself can be undone.
+`
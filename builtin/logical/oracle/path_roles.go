@@ -0,0 +1,1781 @@
+package oracle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func pathListRoleSummaries(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role-summaries/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleSummaryList,
+		},
+
+		HelpSynopsis:    pathRoleSummariesHelpSyn,
+		HelpDescription: pathRoleSummariesHelpDesc,
+	}
+}
+
+func pathRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"sql": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "SQL string to create a user. See help for more info.",
+			},
+
+			"setup_statements": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `SQL statements run once, when credentials are first
+issued for the role, e.g. to create a tablespace used by its users. They are
+run again only if they change.`,
+			},
+
+			"pre_creation_statements": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `SQL statements executed in the same session before "sql",
+e.g. ALTER SESSION statements. The same values as in "sql" will be
+substituted.`,
+			},
+
+			"post_creation_statements": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `SQL statements executed in the same session after the
+user has been created and configured. The same values as in "sql" will be
+substituted.`,
+			},
+
+			"identification": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: identificationPassword,
+				Description: `How created users are authenticated. One of "password",
+in which case a password is generated for each user, "external" for users
+authenticated by the operating system or Kerberos, or "global" for users
+authenticated by an enterprise directory. Only "password" users have a
+password.`,
+			},
+
+			"external_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template for the external principal of created users
+when "identification" is "external", e.g. '{{name}}@EXAMPLE.COM'. The
+'{{name}}' value will be substituted.`,
+			},
+
+			"global_dn": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template for the directory DN of created users when
+"identification" is "global", e.g. 'cn={{name}},ou=db,dc=example,dc=com'.
+The '{{name}}' value will be substituted.`,
+			},
+
+			"container": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Container (PDB) to create users in, if different from the
+one the connection uses. Users are revoked in the same container.`,
+			},
+
+			"renewable": &framework.FieldSchema{
+				Type:    framework.TypeBool,
+				Default: true,
+				Description: `If false, credentials issued for the role cannot be
+renewed and expire at their original TTL.`,
+			},
+
+			"require_reason": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, a reason must be given when requesting
+credentials for the role.`,
+			},
+
+			"username_case": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: usernameCasePreserve,
+				Description: `Case of generated usernames. One of "preserve", "upper"
+or "lower".`,
+			},
+
+			"username_template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Template for generated usernames. Defaults to the template
+set in config/username.`,
+			},
+
+			"username_identity": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, generated usernames include the start of the
+requesting token's accessor, so sessions can be traced back to the requester.`,
+			},
+
+			"random_username": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, generated usernames are random identifiers, without
+the display name or anything else about the requester.`,
+			},
+
+			"quoted_username": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the '{{name}}' value is substituted as a quoted
+identifier, so Oracle keeps the case of the username.`,
+			},
+
+			"statement_timeout": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Maximum time each statement creating a user may take.
+Statements that take longer are aborted and the partially created user is
+dropped. Zero waits indefinitely.`,
+			},
+
+			"service_account": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, each requester gets the same user on every
+request, created on the first one and given a new password on later ones.
+Requires password identification.`,
+			},
+
+			"verify_login": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the backend logs in as each created user before
+returning its credentials, and drops it if that fails. Requires password
+identification.`,
+			},
+
+			"allow_username_hint": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, requests for credentials may give a
+"username_hint" to include in the generated username.`,
+			},
+
+			"include_grants": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, credentials include a summary of the privileges
+and roles granted to the user.`,
+			},
+
+			"execution_mode": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: executionModeTransaction,
+				Description: `How the statements creating a user are run. Either
+"transaction" to run them in one transaction, or "statement" to commit each
+one as it runs and report which one failed.`,
+			},
+
+			"rate_limit": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Maximum number of requests for credentials accepted for
+the role per "rate_limit_period", on each Vault node. Zero means unlimited.`,
+			},
+
+			"rate_limit_period": &framework.FieldSchema{
+				Type:    framework.TypeDurationSecond,
+				Default: 60,
+				Description: `Period "rate_limit" applies to, in seconds or as a
+duration such as "1m".`,
+			},
+
+			"pool_size": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Number of users created ahead of time for the role, so
+that credentials are handed out without waiting for the database. Zero, the
+default, creates each user on request.`,
+			},
+
+			"serialize_creation": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, credentials for the role are created one at a
+time, for SQL that can't safely run concurrently.`,
+			},
+
+			"skip_session_kill": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the sessions of a user are not killed when it is
+revoked.`,
+			},
+
+			"session_termination": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: sessionTerminationImmediate,
+				Description: `How the sessions of a user are ended when it is revoked.
+Either "immediate" to kill them right away, or "post_transaction" to
+disconnect them once their current transaction ends.`,
+			},
+
+			"revocation_mode": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: revocationModeDrop,
+				Description: `How users are revoked. Either "drop" to drop the user,
+or "lock" to lock the user and expire its password, keeping its schema. Only
+applies when "revocation_sql" is not set.`,
+			},
+
+			"expiry_enforcement": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `If set, a scheduler job is registered in the database
+that enforces the expiry of the lease even if revocation fails. Either "lock"
+to lock the user and kill its sessions, or "drop" to also drop it.`,
+			},
+
+			"drop_grace_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `If set, users are locked on revocation, and only dropped
+once this period has passed, leaving time to recover them.`,
+			},
+
+			"drop_when_drained": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the sessions of users are left to finish on
+revocation, and users are dropped once they have none left, or once the
+"drop_grace_period" has passed, whichever comes first.`,
+			},
+
+			"archive_schema": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `If set, the tables owned by a user are copied into this
+schema before the user is dropped with CASCADE on revocation.`,
+			},
+
+			"revoke_cascade": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, users are dropped with CASCADE on revocation,
+dropping any objects they own. Only applies when "revocation_sql" is not set.`,
+			},
+
+			"purge_recyclebin": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the objects users have dropped into the recycle bin
+are purged on revocation, before the revocation statements run.`,
+			},
+
+			"stop_jobs": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the scheduler jobs owned by users are disabled and
+stopped on revocation, and the parallel query slaves working for their
+sessions are killed, before their sessions are killed.`,
+			},
+
+			"password_mode": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How passwords are generated for the role. Either "uuid"
+or "strong". Defaults to the mode set on the connection.`,
+			},
+
+			"inline_password": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the password is substituted into the SQL as is,
+rather than passed as a bind variable.`,
+			},
+
+			"identified_by_values": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the password verifier is computed by the backend
+and available to the SQL as '{{password_verifier}}', for use with IDENTIFIED
+BY VALUES, so the password itself never reaches the database. Requires
+password identification.`,
+			},
+
+			"revocation_sql": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `SQL statements to be executed to revoke a user. Must be a semicolon-separated
+string, a base64-encoded semicolon-separated string, a serialized JSON string
+array, or a base64-encoded serialized JSON string array. The '{{name}}' value
+will be substituted.`,
+			},
+
+			"default_tablespace": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Default tablespace assigned to created users.",
+			},
+
+			"temporary_tablespace": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Temporary tablespace assigned to created users.",
+			},
+
+			"profile": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Profile assigned to created users.",
+			},
+
+			"manage_profile": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, a profile is created and maintained for the role,
+with a password lifetime and idle time matching the max TTL, and assigned to
+created users. Cannot be used with "profile".`,
+			},
+
+			"quotas": &framework.FieldSchema{
+				Type: framework.TypeMap,
+				Description: `Map of tablespace names to the quota granted on them,
+e.g. {"USERS": "100M"}. A quota can be a size or "UNLIMITED".`,
+			},
+
+			"account_unlock": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, created users are explicitly unlocked.",
+			},
+
+			"password_expire": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the password of created users is expired and
+must be changed on first login.`,
+			},
+
+			"grant_option": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the object grants are made WITH GRANT OPTION, so
+created users can grant the privileges on to others.`,
+			},
+
+			"allowed_service": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `If set, created users can only connect through this
+database service, enforced by a logon trigger.`,
+			},
+
+			"editions_enabled": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, created users are editions enabled, for use with
+edition-based redefinition.`,
+			},
+
+			"allowed_variables": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Names of the variables that requests for credentials may
+supply, available to the role's SQL as '{{name}}' is.`,
+			},
+
+			"object_grants": &framework.FieldSchema{
+				Type: framework.TypeMap,
+				Description: `Map of object privileges to the objects they are granted
+on, e.g. {"SELECT": ["APP.ORDERS", "APP.CUSTOMERS"]}. Each entry is expanded
+to a GRANT statement run after the user is created.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.UpdateOperation: b.pathRoleCreate,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func (b *backend) Role(s logical.Storage, n string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) roleHistory(s logical.Storage, n string) (*roleHistory, error) {
+	entry, err := s.Get("role-history/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleHistory
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// putRole stores the role as the next version of the named role, keeping the
+// version it replaces in the role's history so that it can be rolled back.
+func (b *backend) putRole(s logical.Storage, n string, role *roleEntry) error {
+	existing, err := b.Role(s, n)
+	if err != nil {
+		return err
+	}
+
+	role.Version = 1
+	if existing != nil {
+		history, err := b.roleHistory(s, n)
+		if err != nil {
+			return err
+		}
+		if history == nil {
+			history = &roleHistory{}
+		}
+		history.Versions = append(history.Versions, existing)
+		if len(history.Versions) > maxRoleVersions {
+			history.Versions = history.Versions[len(history.Versions)-maxRoleVersions:]
+		}
+
+		entry, err := logical.StorageEntryJSON("role-history/"+n, history)
+		if err != nil {
+			return err
+		}
+		if err := s.Put(entry); err != nil {
+			return err
+		}
+
+		role.Version = existing.Version + 1
+	}
+
+	entry, err := logical.StorageEntryJSON("role/"+n, role)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+func (b *backend) roleSetupState(s logical.Storage, n string) (*roleSetupState, error) {
+	entry, err := s.Get("setup/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleSetupState
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) roleIssuance(s logical.Storage, n string) (*roleIssuance, error) {
+	entry, err := s.Get("issuance/" + n)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleIssuance
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathRoleDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	// Drop the users waiting in the pool while the role's revocation SQL is
+	// still available
+	if err := b.drainPool(req.Storage, name); err != nil {
+		return nil, err
+	}
+
+	err := req.Storage.Delete("role/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete("issuance/" + name); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete("role-history/" + name); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete("setup/" + name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRoleRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	role, err := b.Role(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	history, err := b.roleHistory(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	previousVersions := []int{}
+	if history != nil {
+		for _, version := range history.Versions {
+			previousVersions = append(previousVersions, version.Version)
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"version":                  role.Version,
+			"previous_versions":        previousVersions,
+			"sql":                      role.SQL,
+			"setup_statements":         role.SetupStatements,
+			"pre_creation_statements":  role.PreCreationStatements,
+			"post_creation_statements": role.PostCreationStatements,
+			"identification":           role.identification(),
+			"external_name":            role.ExternalName,
+			"global_dn":                role.GlobalDN,
+			"container":                role.Container,
+			"renewable":                role.renewable(),
+			"require_reason":           role.RequireReason,
+			"username_case":            role.usernameCase(),
+			"username_template":        role.UsernameTemplate,
+			"username_identity":        role.UsernameIdentity,
+			"random_username":          role.RandomUsername,
+			"quoted_username":          role.QuotedUsername,
+			"service_account":          role.ServiceAccount,
+			"verify_login":             role.VerifyLogin,
+			"allow_username_hint":      role.AllowUsernameHint,
+			"include_grants":           role.IncludeGrants,
+			"execution_mode":           role.executionMode(),
+			"rate_limit":               role.RateLimit,
+			"rate_limit_period":        int64(role.rateLimitPeriod().Seconds()),
+			"pool_size":                role.PoolSize,
+			"serialize_creation":       role.SerializeCreation,
+			"statement_timeout":        int64(role.StatementTimeout.Seconds()),
+			"password_mode":            role.PasswordMode,
+			"inline_password":          role.InlinePassword,
+			"identified_by_values":     role.IdentifiedByValues,
+			"skip_session_kill":        role.SkipSessionKill,
+			"session_termination":      role.sessionTermination(),
+			"revocation_sql":           role.RevocationSQL,
+			"revocation_mode":          role.revocationMode(),
+			"revoke_cascade":           role.RevokeCascade,
+			"purge_recyclebin":         role.PurgeRecyclebin,
+			"stop_jobs":                role.StopJobs,
+			"drop_grace_period":        int64(role.DropGracePeriod.Seconds()),
+			"drop_when_drained":        role.DropWhenDrained,
+			"archive_schema":           role.ArchiveSchema,
+			"expiry_enforcement":       role.ExpiryEnforcement,
+			"default_tablespace":       role.AccountOptions.DefaultTablespace,
+			"temporary_tablespace":     role.AccountOptions.TemporaryTablespace,
+			"profile":                  role.AccountOptions.Profile,
+			"manage_profile":           role.ManageProfile,
+			"quotas":                   role.AccountOptions.Quotas,
+			"account_unlock":           role.AccountOptions.AccountUnlock,
+			"password_expire":          role.AccountOptions.PasswordExpire,
+			"editions_enabled":         role.AccountOptions.EditionsEnabled,
+			"object_grants":            role.ObjectGrants,
+			"grant_option":             role.GrantOption,
+			"allowed_service":          role.AllowedService,
+			"allowed_variables":        role.AllowedVariables,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+// pathRoleSummaryList lists the roles along with a summary of each, so that
+// a mount can be audited without reading every role.
+func (b *backend) pathRoleSummaryList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+	resp := logical.ListResponse(entries)
+
+	// Leases are configured for the whole backend, but are included in each
+	// summary so a role's effective TTLs can be seen at a glance
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+	ttl, _ := b.leaseTTL(lease)
+	maxTTL := b.System().MaxLeaseTTL()
+	if lease.LeaseMax > 0 && (maxTTL == 0 || lease.LeaseMax < maxTTL) {
+		maxTTL = lease.LeaseMax
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	keyInfo := make(map[string]interface{}, len(entries))
+	for _, name := range entries {
+		role, err := b.Role(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			continue
+		}
+
+		issuance, err := b.roleIssuance(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		lastIssued := ""
+		if issuance != nil {
+			lastIssued = issuance.LastIssued.Format(time.RFC3339)
+		}
+
+		// Users of roles with a container live in that pluggable database.
+		// Otherwise the database connected to is only known once the
+		// connection has been used.
+		dbName := role.Container
+		if dbName == "" {
+			dbName = b.DBName()
+		}
+
+		keyInfo[name] = map[string]interface{}{
+			"identification": role.identification(),
+			"container":      role.Container,
+			"db_name":        dbName,
+			"lease":          ttl.String(),
+			"lease_max":      maxTTL.String(),
+			"last_issued":    lastIssued,
+		}
+	}
+	resp.Data["key_info"] = keyInfo
+
+	return resp, nil
+}
+
+func (b *backend) pathRoleCreate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	sql := data.Get("sql").(string)
+
+	identification := data.Get("identification").(string)
+	externalName := data.Get("external_name").(string)
+	globalDN := data.Get("global_dn").(string)
+	switch identification {
+	case identificationPassword, identificationExternal, identificationGlobal:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid identification: %q", identification)), nil
+	}
+	if externalName != "" && identification != identificationExternal {
+		return logical.ErrorResponse(
+			`"external_name" can only be set when "identification" is "external"`), nil
+	}
+	if globalDN != "" && identification != identificationGlobal {
+		return logical.ErrorResponse(
+			`"global_dn" can only be set when "identification" is "global"`), nil
+	}
+	if identification == identificationGlobal && globalDN == "" {
+		return logical.ErrorResponse(
+			`"global_dn" is required when "identification" is "global"`), nil
+	}
+	if identification != identificationPassword && strings.Contains(sql, "{{password}}") {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"%s identified users have no password; remove '{{password}}' from the SQL",
+			identification)), nil
+	}
+	if strings.Contains(externalName, "'") || strings.Contains(globalDN, "'") {
+		return logical.ErrorResponse("external_name and global_dn must not contain quotes"), nil
+	}
+
+	container := data.Get("container").(string)
+	if container != "" && !oracleIdentifierRegex.MatchString(container) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid container: %q", container)), nil
+	}
+
+	renewable := data.Get("renewable").(bool)
+
+	revocationSQL := data.Get("revocation_sql").(string)
+	revokeCascade := data.Get("revoke_cascade").(bool)
+	if revokeCascade && revocationSQL != "" {
+		return logical.ErrorResponse(
+			`"revoke_cascade" cannot be used with "revocation_sql"`), nil
+	}
+	sessionTermination := data.Get("session_termination").(string)
+	switch sessionTermination {
+	case sessionTerminationImmediate, sessionTerminationPostTransaction:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid session_termination: %q", sessionTermination)), nil
+	}
+	if sessionTermination != sessionTerminationImmediate && data.Get("skip_session_kill").(bool) {
+		return logical.ErrorResponse(
+			`"session_termination" cannot be used with "skip_session_kill"`), nil
+	}
+
+	revocationMode := data.Get("revocation_mode").(string)
+	switch revocationMode {
+	case revocationModeDrop:
+	case revocationModeLock:
+		if revocationSQL != "" || revokeCascade {
+			return logical.ErrorResponse(
+				`"revocation_mode" "lock" cannot be used with "revocation_sql" or "revoke_cascade"`), nil
+		}
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid revocation_mode: %q", revocationMode)), nil
+	}
+
+	expiryEnforcement := data.Get("expiry_enforcement").(string)
+	switch expiryEnforcement {
+	case "", expiryEnforcementLock, expiryEnforcementDrop:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid expiry_enforcement: %q", expiryEnforcement)), nil
+	}
+
+	serviceAccount := data.Get("service_account").(bool)
+	if serviceAccount && identification != identificationPassword {
+		return logical.ErrorResponse(
+			`"service_account" requires "identification" to be "password"`), nil
+	}
+	if serviceAccount && expiryEnforcement != "" {
+		return logical.ErrorResponse(
+			`"service_account" cannot be used with "expiry_enforcement"`), nil
+	}
+
+	dropGracePeriod := time.Duration(data.Get("drop_grace_period").(int)) * time.Second
+	if dropGracePeriod < 0 {
+		return logical.ErrorResponse("drop_grace_period must not be negative"), nil
+	}
+	if dropGracePeriod > 0 {
+		if revocationMode != revocationModeDrop {
+			return logical.ErrorResponse(
+				`"drop_grace_period" requires "revocation_mode" to be "drop"`), nil
+		}
+		if serviceAccount || expiryEnforcement == expiryEnforcementDrop {
+			return logical.ErrorResponse(
+				`"drop_grace_period" cannot be used with "service_account" or "expiry_enforcement" "drop"`), nil
+		}
+	}
+	dropWhenDrained := data.Get("drop_when_drained").(bool)
+	if dropWhenDrained && dropGracePeriod == 0 {
+		return logical.ErrorResponse(
+			`"drop_when_drained" requires "drop_grace_period", which bounds how long sessions are left to finish`), nil
+	}
+
+	// Unquoted identifiers are stored upper cased, and the schema is
+	// substituted as one
+	archiveSchema := strings.ToUpper(data.Get("archive_schema").(string))
+	if archiveSchema != "" {
+		if !oracleIdentifierRegex.MatchString(archiveSchema) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid archive_schema: %q", archiveSchema)), nil
+		}
+		if revocationSQL != "" || revocationMode != revocationModeDrop {
+			return logical.ErrorResponse(
+				`"archive_schema" requires "revocation_mode" to be "drop", and cannot be used with "revocation_sql"`), nil
+		}
+		if serviceAccount || expiryEnforcement == expiryEnforcementDrop {
+			return logical.ErrorResponse(
+				`"archive_schema" cannot be used with "service_account" or "expiry_enforcement" "drop"`), nil
+		}
+	}
+
+	allowUsernameHint := data.Get("allow_username_hint").(bool)
+	if allowUsernameHint && serviceAccount {
+		return logical.ErrorResponse(
+			`"allow_username_hint" cannot be used with "service_account"`), nil
+	}
+
+	executionMode := data.Get("execution_mode").(string)
+	switch executionMode {
+	case executionModeTransaction, executionModeStatement:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid execution_mode: %q", executionMode)), nil
+	}
+
+	rateLimit := data.Get("rate_limit").(int)
+	rateLimitPeriod := time.Duration(data.Get("rate_limit_period").(int)) * time.Second
+	if rateLimit < 0 {
+		return logical.ErrorResponse("rate_limit must not be negative"), nil
+	}
+	if rateLimitPeriod <= 0 {
+		return logical.ErrorResponse("rate_limit_period must be positive"), nil
+	}
+
+	// The statements creating the user must use the verifier instead of the
+	// password, or the password would still be sent to the database
+	identifiedByValues := data.Get("identified_by_values").(bool)
+	creationSQL := strings.Join([]string{
+		data.Get("pre_creation_statements").(string),
+		sql,
+		data.Get("post_creation_statements").(string),
+	}, "\n")
+	if identifiedByValues {
+		if identification != identificationPassword {
+			return logical.ErrorResponse(
+				`"identified_by_values" requires "identification" to be "password"`), nil
+		}
+		if strings.Contains(creationSQL, "{{password}}") {
+			return logical.ErrorResponse(
+				`"identified_by_values" is set; use '{{password_verifier}}' instead of '{{password}}' in the SQL`), nil
+		}
+		if !strings.Contains(sql, "{{password_verifier}}") {
+			return logical.ErrorResponse(
+				`"identified_by_values" is set, but the SQL doesn't reference '{{password_verifier}}'`), nil
+		}
+	} else if strings.Contains(creationSQL, "{{password_verifier}}") {
+		return logical.ErrorResponse(
+			`'{{password_verifier}}' is only available when "identified_by_values" is set`), nil
+	}
+
+	verifyLogin := data.Get("verify_login").(bool)
+	if verifyLogin && identification != identificationPassword {
+		return logical.ErrorResponse(
+			`"verify_login" requires "identification" to be "password"`), nil
+	}
+
+	usernameCase := data.Get("username_case").(string)
+	switch usernameCase {
+	case usernameCasePreserve, usernameCaseUpper, usernameCaseLower:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid username_case: %q", usernameCase)), nil
+	}
+
+	accountOptions := accountOptions{
+		DefaultTablespace:   data.Get("default_tablespace").(string),
+		TemporaryTablespace: data.Get("temporary_tablespace").(string),
+		Profile:             data.Get("profile").(string),
+		AccountUnlock:       data.Get("account_unlock").(bool),
+		PasswordExpire:      data.Get("password_expire").(bool),
+		EditionsEnabled:     data.Get("editions_enabled").(bool),
+	}
+	if quotasRaw := data.Get("quotas").(map[string]interface{}); len(quotasRaw) > 0 {
+		accountOptions.Quotas = make(map[string]string, len(quotasRaw))
+		for tablespace, quotaRaw := range quotasRaw {
+			quota, ok := quotaRaw.(string)
+			if !ok {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"quota for tablespace %q must be a string", tablespace)), nil
+			}
+			accountOptions.Quotas[tablespace] = quota
+		}
+	}
+	if err := accountOptions.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	allowedService := data.Get("allowed_service").(string)
+	if allowedService != "" && !oracleServiceNameRegex.MatchString(allowedService) {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid allowed_service: %q", allowedService)), nil
+	}
+
+	passwordMode := data.Get("password_mode").(string)
+	switch passwordMode {
+	case "", passwordModeUUID, passwordModeStrong:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid password_mode: %q", passwordMode)), nil
+	}
+
+	manageProfile := data.Get("manage_profile").(bool)
+	if manageProfile && accountOptions.Profile != "" {
+		return logical.ErrorResponse(
+			`"manage_profile" cannot be used with "profile"`), nil
+	}
+
+	objectGrants, err := parseObjectGrants(data.Get("object_grants").(map[string]interface{}))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	statementTimeout := time.Duration(data.Get("statement_timeout").(int)) * time.Second
+	if statementTimeout < 0 {
+		return logical.ErrorResponse("statement_timeout must not be negative"), nil
+	}
+
+	usernameTemplate := data.Get("username_template").(string)
+	if err := validateUsernameTemplate(usernameTemplate); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error rendering username_template: %s", err)), nil
+	}
+
+	randomUsername := data.Get("random_username").(bool)
+	if randomUsername && usernameTemplate != "" {
+		return logical.ErrorResponse(
+			`"random_username" cannot be used with "username_template"`), nil
+	}
+	if randomUsername {
+		for _, field := range []string{"username_identity", "allow_username_hint", "service_account"} {
+			if data.Get(field).(bool) {
+				return logical.ErrorResponse(fmt.Sprintf(
+					`"random_username" cannot be used with %q`, field)), nil
+			}
+		}
+	}
+
+	allowedVariables := data.Get("allowed_variables").([]string)
+	if err := validateAllowedVariables(allowedVariables); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Pooled users are created before anyone requests them, so nothing about
+	// the requester can go into them
+	poolSize := data.Get("pool_size").(int)
+	if poolSize < 0 || poolSize > maxPoolSize {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"pool_size must be between 0 and %d", maxPoolSize)), nil
+	}
+	if poolSize > 0 {
+		for _, field := range []string{"service_account", "require_reason", "username_identity", "allow_username_hint"} {
+			if data.Get(field).(bool) {
+				return logical.ErrorResponse(fmt.Sprintf(
+					`"pool_size" cannot be used with %q`, field)), nil
+			}
+		}
+		if expiryEnforcement != "" {
+			return logical.ErrorResponse(
+				`"pool_size" cannot be used with "expiry_enforcement"`), nil
+		}
+		if len(allowedVariables) > 0 {
+			return logical.ErrorResponse(
+				`"pool_size" cannot be used with "allowed_variables"`), nil
+		}
+	}
+
+	// Render the templates with placeholder values, so that errors in them
+	// are caught now rather than when credentials are issued or revoked
+	placeholders := map[string]string{
+		"name":              "foo",
+		"password":          "bar",
+		"password_verifier": "T:00",
+		"external_name":     "foo",
+		"global_dn":         "cn=foo",
+		"reason":            "foo",
+	}
+	for _, variable := range allowedVariables {
+		placeholders[variable] = "foo"
+	}
+	var queries []string
+	for _, field := range []string{"pre_creation_statements", "sql", "post_creation_statements"} {
+		stmts, err := renderStatements(data.Get(field).(string), placeholders)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error rendering %s: %s", field, err)), nil
+		}
+		queries = append(queries, stmts...)
+	}
+	if _, err := renderStatements(data.Get("setup_statements").(string), nil); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error rendering setup_statements: %s", err)), nil
+	}
+	if _, err := renderStatements(revocationSQL, map[string]string{
+		"name": "foo",
+	}); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"Error rendering revocation_sql: %s", err)), nil
+	}
+
+	// Get our connection
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that the archive schema exists, so that a typo fails now rather
+	// than when users are revoked
+	if archiveSchema != "" {
+		exists, err := schemaExists(db, container, archiveSchema)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return logical.ErrorResponse(fmt.Sprintf("archive_schema %s does not exist", archiveSchema)), nil
+		}
+	}
+
+	// Test the query by trying to prepare it
+	for _, query := range queries {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error testing query: %s", err)), nil
+		}
+		stmt.Close()
+	}
+
+	// Store it
+	err = b.putRole(req.Storage, name, &roleEntry{
+		SQL:                    sql,
+		SetupStatements:        data.Get("setup_statements").(string),
+		PreCreationStatements:  data.Get("pre_creation_statements").(string),
+		PostCreationStatements: data.Get("post_creation_statements").(string),
+		Identification:         identification,
+		ExternalName:           externalName,
+		GlobalDN:               globalDN,
+		Container:              container,
+		Renewable:              &renewable,
+		RequireReason:          data.Get("require_reason").(bool),
+		UsernameCase:           usernameCase,
+		UsernameTemplate:       usernameTemplate,
+		UsernameIdentity:       data.Get("username_identity").(bool),
+		RandomUsername:         randomUsername,
+		QuotedUsername:         data.Get("quoted_username").(bool),
+		ServiceAccount:         serviceAccount,
+		VerifyLogin:            verifyLogin,
+		AllowUsernameHint:      allowUsernameHint,
+		IncludeGrants:          data.Get("include_grants").(bool),
+		ExecutionMode:          executionMode,
+		RateLimit:              rateLimit,
+		RateLimitPeriod:        rateLimitPeriod,
+		PoolSize:               poolSize,
+		SerializeCreation:      data.Get("serialize_creation").(bool),
+		StatementTimeout:       statementTimeout,
+		PasswordMode:           passwordMode,
+		InlinePassword:         data.Get("inline_password").(bool),
+		IdentifiedByValues:     identifiedByValues,
+		SkipSessionKill:        data.Get("skip_session_kill").(bool),
+		SessionTermination:     sessionTermination,
+		RevocationSQL:          revocationSQL,
+		RevocationMode:         revocationMode,
+		RevokeCascade:          revokeCascade,
+		PurgeRecyclebin:        data.Get("purge_recyclebin").(bool),
+		StopJobs:               data.Get("stop_jobs").(bool),
+		DropGracePeriod:        dropGracePeriod,
+		DropWhenDrained:        dropWhenDrained,
+		ArchiveSchema:          archiveSchema,
+		ExpiryEnforcement:      expiryEnforcement,
+		AccountOptions:         accountOptions,
+		ManageProfile:          manageProfile,
+		ObjectGrants:           objectGrants,
+		GrantOption:            data.Get("grant_option").(bool),
+		AllowedService:         allowedService,
+		AllowedVariables:       allowedVariables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Users already in the pool were created from the previous version of
+	// the role, so they are replaced. The role has been saved by now, so a
+	// failure is reported rather than failing the request.
+	var resp *logical.Response
+	if err := b.drainPool(req.Storage, name); err != nil {
+		b.logger.Warn("oracle/pathRoleCreate: failed to drain pool", "role", name, "error", err)
+		resp = &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("Could not replace the users in the pool: %s", err))
+	}
+	if poolSize > 0 {
+		b.fillPool(req.Storage, req.MountPoint, name)
+	}
+
+	return resp, nil
+}
+
+type roleEntry struct {
+	Version                int                 `json:"version" mapstructure:"version" structs:"version"`
+	SQL                    string              `json:"sql" mapstructure:"sql" structs:"sql"`
+	SetupStatements        string              `json:"setup_statements" mapstructure:"setup_statements" structs:"setup_statements"`
+	PreCreationStatements  string              `json:"pre_creation_statements" mapstructure:"pre_creation_statements" structs:"pre_creation_statements"`
+	PostCreationStatements string              `json:"post_creation_statements" mapstructure:"post_creation_statements" structs:"post_creation_statements"`
+	Identification         string              `json:"identification" mapstructure:"identification" structs:"identification"`
+	ExternalName           string              `json:"external_name" mapstructure:"external_name" structs:"external_name"`
+	GlobalDN               string              `json:"global_dn" mapstructure:"global_dn" structs:"global_dn"`
+	Container              string              `json:"container" mapstructure:"container" structs:"container"`
+	Renewable              *bool               `json:"renewable" mapstructure:"renewable" structs:"renewable"`
+	RequireReason          bool                `json:"require_reason" mapstructure:"require_reason" structs:"require_reason"`
+	UsernameCase           string              `json:"username_case" mapstructure:"username_case" structs:"username_case"`
+	UsernameTemplate       string              `json:"username_template" mapstructure:"username_template" structs:"username_template"`
+	UsernameIdentity       bool                `json:"username_identity" mapstructure:"username_identity" structs:"username_identity"`
+	RandomUsername         bool                `json:"random_username" mapstructure:"random_username" structs:"random_username"`
+	QuotedUsername         bool                `json:"quoted_username" mapstructure:"quoted_username" structs:"quoted_username"`
+	ServiceAccount         bool                `json:"service_account" mapstructure:"service_account" structs:"service_account"`
+	VerifyLogin            bool                `json:"verify_login" mapstructure:"verify_login" structs:"verify_login"`
+	AllowUsernameHint      bool                `json:"allow_username_hint" mapstructure:"allow_username_hint" structs:"allow_username_hint"`
+	IncludeGrants          bool                `json:"include_grants" mapstructure:"include_grants" structs:"include_grants"`
+	ExecutionMode          string              `json:"execution_mode" mapstructure:"execution_mode" structs:"execution_mode"`
+	RateLimit              int                 `json:"rate_limit" mapstructure:"rate_limit" structs:"rate_limit"`
+	RateLimitPeriod        time.Duration       `json:"rate_limit_period" mapstructure:"rate_limit_period" structs:"rate_limit_period"`
+	PoolSize               int                 `json:"pool_size" mapstructure:"pool_size" structs:"pool_size"`
+	SerializeCreation      bool                `json:"serialize_creation" mapstructure:"serialize_creation" structs:"serialize_creation"`
+	StatementTimeout       time.Duration       `json:"statement_timeout" mapstructure:"statement_timeout" structs:"statement_timeout"`
+	PasswordMode           string              `json:"password_mode" mapstructure:"password_mode" structs:"password_mode"`
+	InlinePassword         bool                `json:"inline_password" mapstructure:"inline_password" structs:"inline_password"`
+	IdentifiedByValues     bool                `json:"identified_by_values" mapstructure:"identified_by_values" structs:"identified_by_values"`
+	SkipSessionKill        bool                `json:"skip_session_kill" mapstructure:"skip_session_kill" structs:"skip_session_kill"`
+	SessionTermination     string              `json:"session_termination" mapstructure:"session_termination" structs:"session_termination"`
+	RevocationSQL          string              `json:"revocation_sql" mapstructure:"revocation_sql" structs:"revocation_sql"`
+	RevocationMode         string              `json:"revocation_mode" mapstructure:"revocation_mode" structs:"revocation_mode"`
+	RevokeCascade          bool                `json:"revoke_cascade" mapstructure:"revoke_cascade" structs:"revoke_cascade"`
+	PurgeRecyclebin        bool                `json:"purge_recyclebin" mapstructure:"purge_recyclebin" structs:"purge_recyclebin"`
+	StopJobs               bool                `json:"stop_jobs" mapstructure:"stop_jobs" structs:"stop_jobs"`
+	DropGracePeriod        time.Duration       `json:"drop_grace_period" mapstructure:"drop_grace_period" structs:"drop_grace_period"`
+	DropWhenDrained        bool                `json:"drop_when_drained" mapstructure:"drop_when_drained" structs:"drop_when_drained"`
+	ArchiveSchema          string              `json:"archive_schema" mapstructure:"archive_schema" structs:"archive_schema"`
+	ExpiryEnforcement      string              `json:"expiry_enforcement" mapstructure:"expiry_enforcement" structs:"expiry_enforcement"`
+	AccountOptions         accountOptions      `json:"account_options" mapstructure:"account_options" structs:"account_options"`
+	ManageProfile          bool                `json:"manage_profile" mapstructure:"manage_profile" structs:"manage_profile"`
+	ObjectGrants           map[string][]string `json:"object_grants" mapstructure:"object_grants" structs:"object_grants"`
+	GrantOption            bool                `json:"grant_option" mapstructure:"grant_option" structs:"grant_option"`
+	AllowedService         string              `json:"allowed_service" mapstructure:"allowed_service" structs:"allowed_service"`
+	AllowedVariables       []string            `json:"allowed_variables" mapstructure:"allowed_variables" structs:"allowed_variables"`
+}
+
+// roleHistory holds the prior versions of a role, oldest first.
+type roleHistory struct {
+	Versions []*roleEntry `json:"versions" mapstructure:"versions" structs:"versions"`
+}
+
+// roleSetupState records that a role's setup statements have been run. The
+// hash of the statements is kept so that they are run again if they change.
+type roleSetupState struct {
+	StatementsHash string    `json:"statements_hash" mapstructure:"statements_hash" structs:"statements_hash"`
+	CompletedAt    time.Time `json:"completed_at" mapstructure:"completed_at" structs:"completed_at"`
+}
+
+// roleIssuance records when credentials were last issued for a role. It is
+// stored separately from the role so that issuing credentials never rewrites
+// the role definition.
+type roleIssuance struct {
+	LastIssued time.Time `json:"last_issued" mapstructure:"last_issued" structs:"last_issued"`
+}
+
+// identification returns how users created by the role are authenticated.
+// Roles stored before identification was configurable use passwords.
+func (r *roleEntry) identification() string {
+	if r.Identification == "" {
+		return identificationPassword
+	}
+	return r.Identification
+}
+
+// renewable returns whether credentials issued for the role can be renewed.
+// Roles stored before renewability was configurable are renewable.
+func (r *roleEntry) renewable() bool {
+	if r.Renewable == nil {
+		return true
+	}
+	return *r.Renewable
+}
+
+// usernameCase returns the case of usernames generated for the role. Roles
+// stored before the case was configurable preserve it.
+func (r *roleEntry) usernameCase() string {
+	if r.UsernameCase == "" {
+		return usernameCasePreserve
+	}
+	return r.UsernameCase
+}
+
+// executionMode returns how the statements creating users of the role are
+// run. Roles stored before the mode was configurable use a transaction.
+func (r *roleEntry) executionMode() string {
+	if r.ExecutionMode == "" {
+		return executionModeTransaction
+	}
+	return r.ExecutionMode
+}
+
+// rateLimitPeriod returns the period the role's rate limit applies to.
+// Roles stored before it was configurable use a minute.
+func (r *roleEntry) rateLimitPeriod() time.Duration {
+	if r.RateLimitPeriod <= 0 {
+		return time.Minute
+	}
+	return r.RateLimitPeriod
+}
+
+// sessionTermination returns how the sessions of revoked users are ended.
+// Roles stored before it was configurable kill them right away.
+func (r *roleEntry) sessionTermination() string {
+	if r.SessionTermination == "" {
+		return sessionTerminationImmediate
+	}
+	return r.SessionTermination
+}
+
+// sessionKillSQL returns the statement ending a session of a revoked user,
+// into which its SID, serial number and instance are substituted. Users
+// whose role is gone have their sessions ended with the kill SQL of the
+// revocation configuration.
+func (r *roleEntry) sessionKillSQL(config *revocationConfig) string {
+	if r != nil && r.sessionTermination() == sessionTerminationPostTransaction {
+		return sessionDisconnectSQL
+	}
+	return config.sessionKillSQL()
+}
+
+// revocationMode returns how users of the role are revoked. Roles stored
+// before the mode was configurable drop their users.
+func (r *roleEntry) revocationMode() string {
+	if r.RevocationMode == "" {
+		return revocationModeDrop
+	}
+	return r.RevocationMode
+}
+
+// revocationSQL returns the SQL used to revoke users of the role. Roles
+// dropping users without their own SQL use the mount's default.
+func (r *roleEntry) revocationSQL(config *revocationConfig) string {
+	if r.RevocationSQL != "" {
+		return r.RevocationSQL
+	}
+	if r.revocationMode() == revocationModeLock {
+		if r.identification() != identificationPassword {
+			return lockNoPasswordRevocationSQL
+		}
+		return lockRevocationSQL
+	}
+	if r.RevokeCascade || r.ArchiveSchema != "" {
+		return cascadeRevocationSQL
+	}
+	return config.revocationSQL()
+}
+
+// accountOptions holds the account clauses applied to a user once the role's
+// SQL has created it.
+type accountOptions struct {
+	DefaultTablespace   string            `json:"default_tablespace" mapstructure:"default_tablespace" structs:"default_tablespace"`
+	TemporaryTablespace string            `json:"temporary_tablespace" mapstructure:"temporary_tablespace" structs:"temporary_tablespace"`
+	Profile             string            `json:"profile" mapstructure:"profile" structs:"profile"`
+	Quotas              map[string]string `json:"quotas" mapstructure:"quotas" structs:"quotas"`
+	AccountUnlock       bool              `json:"account_unlock" mapstructure:"account_unlock" structs:"account_unlock"`
+	PasswordExpire      bool              `json:"password_expire" mapstructure:"password_expire" structs:"password_expire"`
+	EditionsEnabled     bool              `json:"editions_enabled" mapstructure:"editions_enabled" structs:"editions_enabled"`
+}
+
+func (o *accountOptions) validate() error {
+	for field, value := range map[string]string{
+		"default_tablespace":   o.DefaultTablespace,
+		"temporary_tablespace": o.TemporaryTablespace,
+		"profile":              o.Profile,
+	} {
+		if value != "" && !oracleIdentifierRegex.MatchString(value) {
+			return fmt.Errorf("invalid %s: %q", field, value)
+		}
+	}
+
+	for tablespace, quota := range o.Quotas {
+		if !oracleIdentifierRegex.MatchString(tablespace) {
+			return fmt.Errorf("invalid tablespace in quotas: %q", tablespace)
+		}
+		if !oracleSizeRegex.MatchString(quota) {
+			return fmt.Errorf("invalid quota for tablespace %s: %q", tablespace, quota)
+		}
+	}
+
+	return nil
+}
+
+// alterUserSQL returns the ALTER USER statement applying the options, or an
+// empty string if none are set. The '{{name}}' value is left to be
+// substituted.
+func (o *accountOptions) alterUserSQL() string {
+	var clauses []string
+	if o.DefaultTablespace != "" {
+		clauses = append(clauses, "DEFAULT TABLESPACE "+o.DefaultTablespace)
+	}
+	if o.TemporaryTablespace != "" {
+		clauses = append(clauses, "TEMPORARY TABLESPACE "+o.TemporaryTablespace)
+	}
+
+	tablespaces := make([]string, 0, len(o.Quotas))
+	for tablespace := range o.Quotas {
+		tablespaces = append(tablespaces, tablespace)
+	}
+	sort.Strings(tablespaces)
+	for _, tablespace := range tablespaces {
+		clauses = append(clauses, fmt.Sprintf("QUOTA %s ON %s",
+			strings.ToUpper(o.Quotas[tablespace]), tablespace))
+	}
+
+	if o.Profile != "" {
+		clauses = append(clauses, "PROFILE "+o.Profile)
+	}
+	if o.PasswordExpire {
+		clauses = append(clauses, "PASSWORD EXPIRE")
+	}
+	if o.AccountUnlock {
+		clauses = append(clauses, "ACCOUNT UNLOCK")
+	}
+	if o.EditionsEnabled {
+		clauses = append(clauses, "ENABLE EDITIONS")
+	}
+
+	if len(clauses) == 0 {
+		return ""
+	}
+
+	return "ALTER USER {{name}} " + strings.Join(clauses, " ")
+}
+
+// validateAllowedVariables checks that the names of variables requests may
+// supply can be referenced in templates and don't shadow the values and
+// functions the backend provides.
+func validateAllowedVariables(names []string) error {
+	funcs := templateFuncs(time.Time{})
+	for _, name := range names {
+		if !templateVariableRegex.MatchString(name) {
+			return fmt.Errorf("invalid variable name: %q", name)
+		}
+		if _, ok := funcs[name]; ok || strutil.StrListContains(reservedVariables, name) {
+			return fmt.Errorf("variable name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+// parseObjectGrants converts the raw object_grants field into a map of upper
+// cased privileges to the objects they are granted on. Objects may be given
+// as a list or as a comma-separated string.
+func parseObjectGrants(raw map[string]interface{}) (map[string][]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	grants := make(map[string][]string, len(raw))
+	for privilege, objectsRaw := range raw {
+		privilege = strings.ToUpper(strings.TrimSpace(privilege))
+		if !strutil.StrListContains(oracleObjectPrivileges, privilege) {
+			return nil, fmt.Errorf("invalid object privilege: %q", privilege)
+		}
+
+		var objects []string
+		switch objectsRaw := objectsRaw.(type) {
+		case string:
+			objects = strutil.ParseStringSlice(objectsRaw, ",")
+		case []interface{}:
+			for _, objectRaw := range objectsRaw {
+				object, ok := objectRaw.(string)
+				if !ok {
+					return nil, fmt.Errorf("objects for privilege %s must be strings", privilege)
+				}
+				objects = append(objects, strings.TrimSpace(object))
+			}
+		default:
+			return nil, fmt.Errorf("objects for privilege %s must be a list or a comma-separated string", privilege)
+		}
+
+		for _, object := range objects {
+			if !oracleObjectNameRegex.MatchString(object) {
+				return nil, fmt.Errorf("invalid object for privilege %s: %q", privilege, object)
+			}
+		}
+		grants[privilege] = append(grants[privilege], objects...)
+	}
+
+	return grants, nil
+}
+
+// objectGrantSQL expands the object grants into GRANT statements, ordered by
+// privilege, optionally allowing the user to grant the privileges on. The
+// '{{name}}' value is left to be substituted.
+func objectGrantSQL(grants map[string][]string, grantOption bool) []string {
+	privileges := make([]string, 0, len(grants))
+	for privilege := range grants {
+		privileges = append(privileges, privilege)
+	}
+	sort.Strings(privileges)
+
+	suffix := ""
+	if grantOption {
+		suffix = " WITH GRANT OPTION"
+	}
+
+	var stmts []string
+	for _, privilege := range privileges {
+		for _, object := range grants[privilege] {
+			stmts = append(stmts, fmt.Sprintf("GRANT %s ON %s TO {{name}}%s", privilege, object, suffix))
+		}
+	}
+
+	return stmts
+}
+
+const pathRoleHelpSyn = `
+Manage the roles that can be created with this backend.
+`
+
+const pathRoleHelpDesc = `
+This path lets you manage the roles that can be created with this backend.
+
+The "sql" parameter customizes the SQL string used to create the user.
+This can be a sequence of SQL queries. Some substitution will be done to the
+SQL string for certain keys. The names of the variables must be surrounded
+by "{{" and "}}" to be replaced.
+
+  * "name" - The random username generated for the DB user.
+
+  * "password" - The random password generated for the DB user.
+
+Values can be transformed with the functions "upper", "lower", "replace" and
+"truncate", and the current UTC time can be formatted with "timestamp", using
+Go's reference time layout. For example:
+
+	{{name | lower}}
+	{{replace "_" "" name}}
+	{{truncate 8 name}}
+	{{timestamp "2006-01-02"}}
+
+Example of a decent SQL query to use:
+
+	CREATE USER {{name}} IDENTIFIED BY "{{password}}";
+	GRANT CONNECT TO {{name}};
+
+Note the password must be quoted, as generated passwords may contain
+characters that are not valid in an unquoted Oracle password.
+
+Passwords are generated as set by the connection's "password_mode", unless
+the role sets its own. With "strong", passwords are mixed case alphanumerics
+that also include the "_", "$" and "#" characters, which satisfies strict
+password verify functions. When the user's profile has a password
+verify function, strong passwords are generated even if the mode is "uuid".
+Looking up the function requires SELECT on dba_profiles; without it, the
+mode is used as set.
+
+If the password verify function still rejects a password, failing with
+ORA-28003, a new strong password is generated and the statement is retried,
+up to 3 passwords in all.
+
+Oracle doesn't accept bind variables in DDL, so to keep the password out of
+the statement text, statements using "{{password}}" are run through EXECUTE
+IMMEDIATE in an anonymous PL/SQL block, with the password passed as a bind
+variable. Statements that can't be run this way, such as PL/SQL blocks, can
+fall back to having the password substituted as is by setting
+"inline_password". The password is also substituted as is when it is passed
+through a template function.
+
+Even when bound, the password is part of the DDL that the database runs,
+and may show up in its audit trail. Setting "identified_by_values" keeps it
+out of the database entirely: the backend computes the 12c password
+verifier (the "T:" verifier, salted PBKDF2 with SHA-512) and makes it
+available as "{{password_verifier}}", to be used with IDENTIFIED BY VALUES:
+
+  CREATE USER {{name}} IDENTIFIED BY VALUES '{{password_verifier}}';
+
+The SQL must then not reference "{{password}}". Only the 12c verifier is
+computed, so clients must support the 12c authentication protocol. Oracle
+doesn't run password verify functions for IDENTIFIED BY VALUES.
+
+Setting "identification" to "external" creates users authenticated by the
+operating system or Kerberos instead. No password is generated, so the SQL
+must not reference "{{password}}". If "external_name" is set, the templated
+principal is available to the SQL as "{{external_name}}" and is returned
+alongside the username:
+
+	CREATE USER {{name}} IDENTIFIED EXTERNALLY AS '{{external_name}}';
+	GRANT CONNECT TO {{name}};
+
+Setting "identification" to "global" creates users for Enterprise User
+Security, mapped to a DN in the enterprise directory. The "global_dn"
+template is required; the templated DN is available to the SQL as
+"{{global_dn}}" and is returned alongside the username:
+
+	CREATE USER {{name}} IDENTIFIED GLOBALLY AS '{{global_dn}}';
+	GRANT CONNECT TO {{name}};
+
+The "setup_statements" parameter holds SQL run once, in its own transaction,
+the first time credentials are issued for the role, such as creating a
+tablespace or an Oracle role for its users. Once they have succeeded they are
+not run again unless they change, so they should be written to be safe to
+re-run. No values are substituted into them.
+
+The "pre_creation_statements" and "post_creation_statements" parameters run
+additional SQL in the same session before and after the user is created,
+such as setting the current schema or the DDL lock timeout:
+
+	ALTER SESSION SET CURRENT_SCHEMA = APP;
+	ALTER SESSION SET DDL_LOCK_TIMEOUT = 30;
+
+The "default_tablespace", "temporary_tablespace", "profile", "quotas",
+"account_unlock", "password_expire" and "editions_enabled" parameters set
+common account options on the created user without having to include them in
+the SQL. They are applied with a single ALTER USER statement after the role
+SQL has run.
+
+Setting "manage_profile" has the backend create a profile for the role,
+named after it, and assign it to created users. Whenever credentials are
+issued, the profile's PASSWORD_LIFE_TIME and IDLE_TIME are set to match the
+max TTL, so that the database expires the user even if revocation fails. The
+profile is not dropped when the role is deleted.
+
+Setting "allowed_service" restricts created users to connecting through the
+named database service. An AFTER LOGON trigger is created on each user's
+schema, refusing connections made through any other service, and is dropped
+when the user is revoked. This requires the connection user to have the
+CREATE ANY TRIGGER and ADMINISTER DATABASE TRIGGER privileges.
+
+The "object_grants" parameter lists object privileges to grant to the user,
+keyed by privilege, e.g. {"SELECT": ["APP.ORDERS", "APP.CUSTOMERS"]}. Each
+object is granted with its own GRANT statement once the user is created, so
+simple read-only roles need no GRANT statements in their SQL. Setting
+"grant_option" makes the grants WITH GRANT OPTION, for roles whose users
+delegate access to others. WITH ADMIN OPTION applies to system privileges and
+Oracle roles, which are granted in the role SQL.
+
+The "container" parameter creates users in the given pluggable database
+instead of the container the connection uses. The session is switched to the
+container before the role SQL runs, and the container is recorded with the
+lease so that the user is dropped from the same container on revocation. This
+requires the connection user to be a common user with the SET CONTAINER
+privilege. Only the user's sessions in that container are killed on
+revocation, never those of local users with the same name in other PDBs;
+users created without a container while connected to the root of a CDB are
+common users, and their sessions are killed in every container.
+
+Setting "renewable" to false marks credentials issued for the role as
+non-renewable, so they are guaranteed to be revoked at the end of their
+original TTL.
+
+Setting "require_reason" requires a reason, such as a change ticket, to be
+given when requesting credentials. The reason is recorded with the lease and
+is available to the SQL as "{{reason}}", escaped for use in a quoted string,
+so that it can be recorded in a tracking table:
+
+	INSERT INTO audit.vault_issuance (username, reason)
+		VALUES ('{{name}}', '{{reason}}');
+
+The "allowed_variables" parameter lists variables that requests for
+credentials may supply, so that one role can serve many applications. Each is
+available to the SQL by its name, escaped in the same way as the reason, and
+is empty if not supplied:
+
+	INSERT INTO audit.vault_issuance (username, app)
+		VALUES ('{{name}}', '{{app}}');
+
+The "username_case" parameter sets the case of generated usernames to
+"upper" or "lower"; by default it is left as generated. Oracle upper cases
+unquoted identifiers, so to create users with lower case usernames,
+"quoted_username" must also be set. The "{{name}}" value is then substituted
+as a quoted identifier, in the role SQL as well as in the revocation SQL.
+
+The "username_template" parameter sets how usernames are generated for the
+role, overriding the template set in config/username. See the help for that
+path for the values available to it.
+
+Setting "username_identity" includes the first characters of the requesting
+token's accessor in usernames generated by the default template, after the
+display name, so that a DBA can trace a session back to the token that
+requested the credentials, for instance with "vault list
+auth/token/accessors". Templates can include it as "{{identity}}". The full
+accessor is recorded with the lease.
+
+Setting "random_username" makes generated usernames random identifiers, such
+as "V_0B1C3C5A9F0E4D2A8B6C7D1E2F3A", leaving out the display name, which may
+reveal who requested the credentials, and anything else about the
+requester. It can't be combined with "username_template",
+"username_identity", "allow_username_hint" or "service_account". Usernames
+can be made random for all roles with "random" in config/username.
+
+Setting "allow_username_hint" lets requests for credentials give a
+"username_hint", such as the name of a job or environment, so that users can
+be told apart at a glance. Only ASCII letters, digits and underscores are
+kept, up to 8 of them. The default template includes the hint after the
+display name and identity, and templates can include it as "{{hint}}".
+Requests with a hint for roles that don't allow them are refused.
+
+Setting "service_account" gives each requester a stable user, for
+applications that need a consistent schema identity. Vault doesn't track
+identities across tokens, so the requester is identified by the display name
+of its token, such as "approle" or "ldap-jdoe", and the username is the role
+name followed by the display name. The user is created with the role's SQL
+on the first request; later requests only give it a new password and unlock
+it. Revoking the lease of the latest request locks the user and expires its
+password, while revoking earlier leases leaves it alone, since their
+passwords have already been replaced. Service accounts are never dropped by
+Vault, including when the role is deleted. "username_template" does not
+apply to them, and they cannot be used with "expiry_enforcement".
+
+Setting "verify_login" makes the backend log in as each user it creates
+before returning the credentials, so that a missing CREATE SESSION grant or a
+profile that locks the account is caught at issuance rather than in the
+application. The login goes through the connection's connect string, which
+must therefore reach the role's container and any "allowed_service". If it
+fails, the user is dropped and the error is returned.
+
+Setting "include_grants" adds a "grants" summary to the credentials, listing
+the system privileges, roles and object privileges granted directly to the
+user as recorded in DBA_SYS_PRIVS, DBA_ROLE_PRIVS and DBA_TAB_PRIVS, so that
+consumers and auditors can see what the credentials can do. Privileges held
+through roles are not expanded. This requires SELECT on those views; if the
+summary can't be built, the credentials are returned with a warning instead.
+
+Oracle commits DDL implicitly, so the transaction the statements creating a
+user run in only undoes DML when one of them fails. Roles mixing DDL and DML
+can set "execution_mode" to "statement" to make this explicit: each
+statement is committed as soon as it has run, and if one fails, the error
+says which statement it was, as written in the role, which earlier
+statements had been committed, and whether the partially created user was
+dropped. Cleanup of anything else the earlier statements did is left to the
+role's SQL.
+
+Setting "rate_limit" caps the number of requests for credentials accepted
+for the role in each "rate_limit_period", one minute by default, so that a
+runaway client can't flood the database with new users and exhaust its
+PROCESSES or SESSIONS. Requests beyond the limit are refused until the
+period is over. The count is kept in memory by each Vault node, and requests
+returning credentials for an idempotency token are not counted.
+
+Setting "pool_size" has the backend create that many users ahead of time,
+in the background, and hand them out on request. Requests then don't wait
+for the database, and an empty pool falls back to creating a user. The
+pool is topped up after each request and periodically. Pooled users are
+created without knowing who will request them, so their usernames are
+generated with the display name "pool", and the pool can't be used with
+"service_account", "require_reason", "username_identity",
+"allow_username_hint", "expiry_enforcement" or "allowed_variables".
+Updating the role drops the users in its pool and creates new ones, and
+deleting the role drops them.
+
+Setting "serialize_creation" makes credentials for the role be created one at
+a time, for role SQL that touches shared objects such as sequences or
+packages. Other roles continue to create credentials concurrently.
+
+Setting "statement_timeout" limits how long each statement creating a user
+may take, so that a statement blocked on a lock, such as a GRANT on an object
+in use, doesn't hold the request, and any role lock, open indefinitely. The
+driver can't cancel statements, so a statement that runs too long is aborted
+by killing its session with ALTER SYSTEM KILL SESSION, and the partially
+created user is then dropped.
+
+The "revocation_sql" parameter customizes the SQL string used to revoke a user.
+If not set, the user's sessions are killed and the user is dropped, with the
+"revocation_sql" of config/revocation if one is configured.
+Example of a decent revocation SQL query to use:
+
+	REVOKE CONNECT FROM {{name}};
+	DROP USER {{name}};
+
+Setting "revocation_mode" to "lock" locks revoked users and expires their
+passwords instead of dropping them, keeping the account and its schema for
+environments with retention requirements. Locked users must be cleaned up
+outside of Vault.
+
+Dropping a user fails if it owns any objects. If users of the role create
+objects, set "revoke_cascade" to drop them with CASCADE, which also drops the
+objects they own. Otherwise, before a DROP USER without CASCADE runs, the
+objects owned by the user are looked up in DBA_OBJECTS, and the revocation
+fails with an error listing them, so that they can be dealt with.
+
+Tables a user drops go to the recycle bin, where they still take up space
+and count as objects it owns. Setting "purge_recyclebin" purges them on
+revocation, with PURGE TABLESPACE ... USER for each tablespace holding them,
+before the revocation statements run: a DROP USER without CASCADE then
+doesn't fail on them, and users kept by "revocation_mode" "lock" or a custom
+"revocation_sql" don't hold on to the space. DROP USER CASCADE purges them
+itself. The connection user needs the privilege to purge other users'
+objects, such as DROP ANY TABLE.
+
+Scheduler jobs owned by a user run as it, and parallel queries of its
+sessions run on slaves attributed to it. Either keeps the user connected
+after its sessions are killed, or connects it again, failing DROP USER with
+ORA-01940 on busy systems. Setting "stop_jobs" disables the jobs the user
+owns on revocation, stops those that are running, and kills the parallel
+query slaves working for its sessions, found through gv$px_session, before
+the sessions themselves are killed. Jobs are disabled for users only locked
+as well, since they keep running as a locked user. The connection user needs
+to be able to read DBA_SCHEDULER_JOBS and gv$px_session, and to alter other
+users' jobs with MANAGE SCHEDULER and CREATE ANY JOB. With a revocation
+package, the slaves are left to the package along with the sessions.
+
+Setting "drop_grace_period" gives a window to recover users whose lease was
+revoked by accident. On revocation, the user's sessions are killed and it is
+locked, with its password expired, and it is only dropped once the period
+has passed, by the backend's periodic function. Until then, it is listed
+under "pending-drops/", and deleting it there cancels the drop, leaving the
+user locked and no longer managed by Vault.
+
+Setting "drop_when_drained" as well shortens the window where a session
+outlives its user. On revocation, the user is locked with its password
+expired straight away, but its sessions are left to finish, and it is
+dropped by the periodic function as soon as it has none left. Sessions still
+open once "drop_grace_period" has passed are killed before the drop.
+
+For roles whose users create data that must be kept, set "archive_schema" to
+an existing schema. On revocation, each table owned by the user is copied
+into it with CREATE TABLE AS SELECT before the user is dropped with CASCADE.
+Archived tables are named after the original table followed by "$" and a
+hash of the user and table, shortened if needed, and commented with the user
+and table they came from. Only the rows of tables are kept: views, code and
+other objects, as well as indexes and constraints, are dropped with the user.
+This requires the CREATE ANY TABLE, SELECT ANY TABLE and COMMENT ANY TABLE
+privileges, and quota for the archive schema on its tablespace.
+
+Setting "expiry_enforcement" registers a DBMS_SCHEDULER job with each user
+that runs when its lease expires, so that credentials stop working on time
+even if revocation by Vault is delayed or fails. With "lock", the job locks
+the user and kills its sessions; with "drop", it also drops the user with
+CASCADE. The job is moved when the lease is renewed, and dropped when it is
+revoked. This requires the CREATE JOB privilege, and the privileges used by
+the job must be granted directly rather than through a role.
+
+The user's sessions are killed with ALTER SYSTEM KILL SESSION before the
+revocation SQL runs, which requires the ALTER SYSTEM privilege. Sessions are
+found by their exact username, so the sessions of other accounts, even ones
+whose names share a prefix with the user's, are never killed. They aren't
+matched by CLIENT_IDENTIFIER, since a client can set its own with
+DBMS_SESSION.SET_IDENTIFIER and so keep its sessions out of the kill. Setting
+"skip_session_kill" skips this, for databases where sessions are cleaned up by
+other means, such as profile limits.
+
+Killing a session rolls back its transaction, even one about to commit. For
+workloads where that is unacceptable, setting "session_termination" to
+"post_transaction" disconnects sessions with ALTER SYSTEM DISCONNECT SESSION
+... POST_TRANSACTION instead, letting their current transaction finish first.
+The user can't be dropped until its sessions are gone, so revocation is then
+retried for the "drop_retry_period" of config/revocation, disconnecting any
+remaining sessions again, and fails if a transaction runs longer than that.
+
+Each write to a role creates a new version of it. The previous versions are
+kept, and the role can be restored to one of them with the "rollback"
+endpoint.
+
+Listing "role-summaries/" returns a summary of each role under "key_info",
+along with the role names.
+`
+
+const pathRoleSummariesHelpSyn = `
+List the roles along with a summary of each.
+`
+
+const pathRoleSummariesHelpDesc = `
+Listing this path returns the role names, and under "key_info" a summary of
+each role: its identification and container, the database its users are
+created in, the lease and max lease TTLs credentials are issued with, and
+when credentials were last issued for it. Roles can't be disabled, so the
+summaries have no disabled flag.
+`
@@ -0,0 +1,89 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathStaticCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathStaticCredsRead,
+		},
+
+		HelpSynopsis:    pathStaticCredsHelpSyn,
+		HelpDescription: pathStaticCredsHelpDesc,
+	}
+}
+
+func (b *backend) pathStaticCredsRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role, err := b.StaticRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown static role: %s", name)), nil
+	}
+
+	// The password is served as stored, without connecting to the database.
+	// The ttl tells clients when to read it again: it is the time left until
+	// the next rotation, and 0 once one is due, or if the role isn't rotated
+	// on a schedule.
+	var ttl time.Duration
+	if !role.NextVaultRotation.IsZero() {
+		ttl = time.Until(role.NextVaultRotation)
+		if ttl < 0 {
+			ttl = 0
+		}
+	}
+	username, password := role.activeCredentials()
+	respData := map[string]interface{}{
+		"username":            username,
+		"password":            password,
+		"last_vault_rotation": formatRotationTime(role.LastVaultRotation),
+		"rotation_period":     int64(role.RotationPeriod.Seconds()),
+		"ttl":                 int64(ttl.Seconds()),
+	}
+	if connConfig, err := b.ConnectionConfig(req.Storage); err != nil {
+		return nil, err
+	} else if connConfig != nil {
+		if connect := connectString(connConfig.ConnectionURL); connect != "" {
+			respData["connect_string"] = connect
+		}
+	}
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+const pathStaticCredsHelpSyn = `
+Read the current password of a static role's account.
+`
+
+const pathStaticCredsHelpDesc = `
+This path returns the username and current password of the account managed
+by a static role. No lease is issued: the password stays valid until Vault
+rotates it. In "dual" rotation mode, the account served changes with each
+rotation, and the credentials served before it stay valid until the next.
+
+Along with the password, "last_vault_rotation" is when Vault last rotated
+it, empty for an imported password it hasn't rotated yet, and "ttl" the
+number of seconds until it is next due to be rotated, so that clients know
+when to read it again. The ttl is 0 once a rotation is due, as while a
+failed rotation is retried, and for roles without a "rotation_period" or
+"rotation_schedule", whose password only changes when the role is created.
+`
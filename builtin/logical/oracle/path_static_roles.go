@@ -0,0 +1,862 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the static role.",
+			},
+
+			"username": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Existing database account whose password the role
+manages. Required, and can't be changed once the role is written.`,
+			},
+
+			"rotation_mode": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How the password is rotated. Either "single" to rotate
+the password of "username" in place, or "dual" to alternate between
+"username" and "secondary_username", rotating whichever isn't being served.
+Defaults to "single", and can't be changed once the role is written.`,
+			},
+
+			"secondary_username": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Second existing account, alternated with "username" in
+"dual" rotation mode. Required in that mode, and can't be changed once the
+role is written.`,
+			},
+
+			"rotation_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How often the password is rotated, such as "24h". At least
+a minute. If neither this nor "rotation_schedule" is set, the password is
+only rotated when the role is created.`,
+			},
+
+			"rotation_schedule": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `When the password is rotated, as a five field cron
+expression in UTC, such as "0 2 * * 0" for 02:00 on Sundays. Cannot be
+used with "rotation_period".`,
+			},
+
+			"rotation_window": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long after each time of "rotation_schedule" the
+rotation may run, such as "1h". A rotation that couldn't run within the
+window, such as while Vault was sealed, waits for the next scheduled time.
+At least 5 minutes. If not set, a late rotation runs as soon as it can.`,
+			},
+
+			"rotation_statements": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `SQL statements executed to rotate the password, in the
+same forms as "sql" on roles, where PL/SQL blocks must be given as a JSON
+array. The '{{name}}' and '{{password}}' values will be substituted, and the
+password must be used by at least one of them. Defaults to
+'ALTER USER {{name}} IDENTIFIED BY "{{password}}"'.`,
+			},
+
+			"password_mode": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How passwords are generated for the role. Either "uuid"
+or "strong". Defaults to the mode set on the connection.`,
+			},
+
+			"password_length": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `Length of generated passwords, between 8 and 30. Only
+applies with "password_mode" "strong". Defaults to 30.`,
+			},
+
+			"password_special_chars": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Special characters generated passwords draw from, at
+least two of which are used in each. Only applies with "password_mode"
+"strong". Defaults to "_$#", which Oracle accepts in unquoted passwords.`,
+			},
+
+			"password": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Current password of the account, served until the first
+rotation. Only accepted with "skip_initial_rotation", when the role is
+created.`,
+			},
+
+			"skip_initial_rotation": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the password isn't rotated when the role is
+created. The account's current password must be given in "password", and is
+served until the first scheduled rotation.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead,
+			logical.UpdateOperation: b.pathStaticRoleCreate,
+			logical.DeleteOperation: b.pathStaticRoleDelete,
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func staticRoleKey(name string) string {
+	return "static-role/" + name
+}
+
+// lockStaticRole locks the static role for rotation or for changes to it.
+// The returned function unlocks it.
+func (b *backend) lockStaticRole(name string) func() {
+	lock := locksutil.LockForKey(b.roleLocks, staticRoleKey(name))
+	lock.Lock()
+	return lock.Unlock
+}
+
+func (b *backend) StaticRole(s logical.Storage, n string) (*staticRoleEntry, error) {
+	entry, err := s.Get(staticRoleKey(n))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result staticRoleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) putStaticRole(s logical.Storage, name string, role *staticRoleEntry) error {
+	entry, err := logical.StorageEntryJSON(staticRoleKey(name), role)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+func (b *backend) pathStaticRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("static-role/")
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathStaticRoleRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := b.StaticRole(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	// The password is only served from static-creds
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"username":               role.Username,
+			"rotation_mode":          role.rotationMode(),
+			"secondary_username":     role.SecondaryUsername,
+			"rotation_period":        int64(role.RotationPeriod.Seconds()),
+			"rotation_schedule":      role.RotationSchedule,
+			"rotation_window":        int64(role.RotationWindow.Seconds()),
+			"rotation_statements":    role.RotationStatements,
+			"password_mode":          role.PasswordMode,
+			"password_length":        role.PasswordLength,
+			"password_special_chars": role.PasswordSpecialChars,
+
+			// How rotation is going, for monitoring to alert on roles that
+			// are stuck
+			"last_vault_rotation":   formatRotationTime(role.LastVaultRotation),
+			"last_rotation_attempt": formatRotationTime(role.LastRotationAttempt),
+			"last_rotation_outcome": role.lastRotationOutcome(),
+			"rotation_attempts":     role.RotationAttempts,
+			"rotation_failures":     role.RotationFailures,
+		},
+	}
+	if role.RotationFailures > 0 {
+		resp.Data["last_rotation_error"] = role.LastRotationError
+		resp.Data["next_rotation_retry"] = formatRotationTime(role.NextRotationRetry)
+	}
+	return resp, nil
+}
+
+func (b *backend) pathStaticRoleDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	unlock := b.lockStaticRole(name)
+	defer unlock()
+
+	// The account is left as it is, with the last password Vault set
+	if err := req.Storage.Delete(staticRoleKey(name)); err != nil {
+		return nil, err
+	}
+	b.staticRotations.remove(name)
+	return nil, nil
+}
+
+func (b *backend) pathStaticRoleCreate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	unlock := b.lockStaticRole(name)
+	defer unlock()
+
+	role, err := b.StaticRole(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unquoted identifiers are stored upper cased, and the account is looked
+	// up and substituted as one
+	username := strings.ToUpper(data.Get("username").(string))
+	if role != nil {
+		if username != "" && username != role.Username {
+			return logical.ErrorResponse("username of an existing static role can't be changed"), nil
+		}
+		username = role.Username
+	}
+	if username == "" {
+		return logical.ErrorResponse("username is required"), nil
+	}
+	if !oracleIdentifierRegex.MatchString(username) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid username: %q", username)), nil
+	}
+
+	rotationMode := data.Get("rotation_mode").(string)
+	secondaryUsername := strings.ToUpper(data.Get("secondary_username").(string))
+	if role != nil {
+		if (rotationMode != "" && rotationMode != role.rotationMode()) ||
+			(secondaryUsername != "" && secondaryUsername != role.SecondaryUsername) {
+			return logical.ErrorResponse("rotation_mode and secondary_username of an existing static role can't be changed"), nil
+		}
+		rotationMode, secondaryUsername = role.RotationMode, role.SecondaryUsername
+	}
+	switch rotationMode {
+	case "", staticRotationModeSingle:
+		if secondaryUsername != "" {
+			return logical.ErrorResponse(`secondary_username requires rotation_mode "dual"`), nil
+		}
+	case staticRotationModeDual:
+		if secondaryUsername == "" {
+			return logical.ErrorResponse(`rotation_mode "dual" requires secondary_username`), nil
+		}
+		if !oracleIdentifierRegex.MatchString(secondaryUsername) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid secondary_username: %q", secondaryUsername)), nil
+		}
+		if secondaryUsername == username {
+			return logical.ErrorResponse("secondary_username must be a different account from username"), nil
+		}
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid rotation_mode: %q", rotationMode)), nil
+	}
+
+	var rotationPeriod time.Duration
+	if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+		rotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	} else if role != nil {
+		rotationPeriod = role.RotationPeriod
+	}
+	if rotationPeriod < 0 || (rotationPeriod > 0 && rotationPeriod < minStaticRotationPeriod) {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"rotation_period must be at least %s", minStaticRotationPeriod)), nil
+	}
+
+	rotationSchedule := role.rotationSchedule()
+	if rotationScheduleRaw, ok := data.GetOk("rotation_schedule"); ok {
+		rotationSchedule = strings.TrimSpace(rotationScheduleRaw.(string))
+	}
+	if rotationSchedule != "" {
+		if rotationPeriod > 0 {
+			return logical.ErrorResponse(`"rotation_schedule" cannot be used with "rotation_period"`), nil
+		}
+		schedule, err := parseCronSchedule(rotationSchedule)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid rotation_schedule: %s", err)), nil
+		}
+		if schedule.next(time.Now()).IsZero() {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"rotation_schedule %q never matches", rotationSchedule)), nil
+		}
+	}
+
+	var rotationWindow time.Duration
+	if rotationWindowRaw, ok := data.GetOk("rotation_window"); ok {
+		rotationWindow = time.Duration(rotationWindowRaw.(int)) * time.Second
+	} else if role != nil {
+		rotationWindow = role.RotationWindow
+	}
+	if rotationWindow != 0 {
+		if rotationSchedule == "" {
+			return logical.ErrorResponse(`"rotation_window" requires "rotation_schedule"`), nil
+		}
+		if rotationWindow < minStaticRotationWindow {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"rotation_window must be at least %s", minStaticRotationWindow)), nil
+		}
+	}
+
+	var rotationStatements string
+	if rotationStatementsRaw, ok := data.GetOk("rotation_statements"); ok {
+		rotationStatements = rotationStatementsRaw.(string)
+	} else if role != nil {
+		rotationStatements = role.RotationStatements
+	}
+	if rotationStatements != "" {
+		// The statements are rendered with placeholder values, so that errors
+		// in them are caught now rather than at the next rotation
+		stmts, err := renderStatements(rotationStatements, map[string]string{
+			"name":     "foo",
+			"password": staticPasswordPlaceholder,
+		})
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"Error rendering rotation_statements: %s", err)), nil
+		}
+		if !strings.Contains(strings.Join(stmts, ";"), staticPasswordPlaceholder) {
+			return logical.ErrorResponse("rotation_statements must set the password, using '{{password}}'"), nil
+		}
+	}
+
+	// The password settings are kept unless given. The length and special
+	// characters only apply to strong passwords, so require that mode.
+	passwordMode := role.passwordMode()
+	if passwordModeRaw, ok := data.GetOk("password_mode"); ok {
+		passwordMode = passwordModeRaw.(string)
+	}
+	switch passwordMode {
+	case "", passwordModeUUID, passwordModeStrong:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf(
+			"invalid password_mode: %q", passwordMode)), nil
+	}
+	var passwordLength int
+	if passwordLengthRaw, ok := data.GetOk("password_length"); ok {
+		passwordLength = passwordLengthRaw.(int)
+	} else if role != nil {
+		passwordLength = role.PasswordLength
+	}
+	var passwordSpecialChars string
+	if passwordSpecialCharsRaw, ok := data.GetOk("password_special_chars"); ok {
+		passwordSpecialChars = passwordSpecialCharsRaw.(string)
+	} else if role != nil {
+		passwordSpecialChars = role.PasswordSpecialChars
+	}
+	if (passwordLength != 0 || passwordSpecialChars != "") && passwordMode != passwordModeStrong {
+		return logical.ErrorResponse(`password_length and password_special_chars require password_mode "strong"`), nil
+	}
+	if passwordLength != 0 && (passwordLength < minStrongPasswordLength || passwordLength > oraclePasswordLength) {
+		return logical.ErrorResponse(fmt.Sprintf(
+			"password_length must be between %d and %d", minStrongPasswordLength, oraclePasswordLength)), nil
+	}
+	if err := validateSpecialChars(passwordSpecialChars); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	password := data.Get("password").(string)
+	skipInitialRotation := data.Get("skip_initial_rotation").(bool)
+	switch {
+	case role != nil && password != "":
+		return logical.ErrorResponse("password can only be given when the static role is created"), nil
+	case role == nil && password != "" && !skipInitialRotation:
+		return logical.ErrorResponse("password can only be given with skip_initial_rotation"), nil
+	case role == nil && password == "" && skipInitialRotation:
+		return logical.ErrorResponse("skip_initial_rotation requires the account's current password"), nil
+	}
+
+	// A new rotation period takes effect from the last rotation, so that
+	// shortening it may make a rotation due straight away. Schedules, and
+	// imported passwords that were never rotated, take effect from now.
+	// Updates leaving all three alone keep the next rotation, so that one
+	// that is due, or waiting out its backoff, isn't pushed back.
+	if role != nil {
+		rescheduled := rotationPeriod != role.RotationPeriod ||
+			rotationSchedule != role.RotationSchedule || rotationWindow != role.RotationWindow
+		role.RotationStatements = rotationStatements
+		role.PasswordMode = passwordMode
+		role.PasswordLength = passwordLength
+		role.PasswordSpecialChars = passwordSpecialChars
+		role.RotationPeriod = rotationPeriod
+		role.RotationSchedule = rotationSchedule
+		role.RotationWindow = rotationWindow
+		if rescheduled {
+			from := role.LastVaultRotation
+			if from.IsZero() || rotationSchedule != "" {
+				from = time.Now().UTC()
+			}
+			role.NextVaultRotation = role.nextRotation(from)
+		}
+
+		// A dual role whose creation failed after rotating the secondary
+		// account has the primary one rotated now, which stores the role
+		if role.primaryRotationPending() {
+			if err := b.rotateStaticRole(req.Storage, name, role); err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+		if err := b.putStaticRole(req.Storage, name, role); err != nil {
+			return nil, err
+		}
+		b.staticRotations.schedule(name, role)
+		return nil, nil
+	}
+
+	role = &staticRoleEntry{
+		Username:             username,
+		RotationMode:         rotationMode,
+		SecondaryUsername:    secondaryUsername,
+		RotationPeriod:       rotationPeriod,
+		RotationSchedule:     rotationSchedule,
+		RotationWindow:       rotationWindow,
+		RotationStatements:   rotationStatements,
+		PasswordMode:         passwordMode,
+		PasswordLength:       passwordLength,
+		PasswordSpecialChars: passwordSpecialChars,
+	}
+
+	// An account managed by two roles would have its password rotated from
+	// under each of them
+	names, err := req.Storage.List("static-role/")
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range names {
+		otherRole, err := b.StaticRole(req.Storage, other)
+		if err != nil {
+			return nil, err
+		}
+		if otherRole == nil {
+			continue
+		}
+		for _, account := range role.accounts() {
+			if strutil.StrListContains(otherRole.accounts(), account) {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"account %s is already managed by static role %q", account, other)), nil
+			}
+		}
+	}
+
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, account := range role.accounts() {
+		exists, err := userExists(tx, account, false)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return logical.ErrorResponse(fmt.Sprintf("account %s does not exist", account)), nil
+		}
+	}
+	tx.Rollback()
+
+	// An imported password is served as it is until the first scheduled
+	// rotation, so that applications already using it keep working. It is
+	// checked by logging in with it, since serving a wrong one would break
+	// them at their next connection instead. In dual mode, it is the
+	// password of "username", and the first rotation moves applications to
+	// the secondary account.
+	if skipInitialRotation {
+		if err := checkLogin(b.ConnectString(), username, password); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"could not log in as %s with the given password: %s", username, err)), nil
+		}
+		role.Password = password
+		role.NextVaultRotation = role.nextRotation(time.Now().UTC())
+		if err := b.putStaticRole(req.Storage, name, role); err != nil {
+			return nil, err
+		}
+		b.staticRotations.schedule(name, role)
+		return nil, nil
+	}
+
+	// Otherwise the password is rotated as soon as the account is managed,
+	// so that only Vault knows it. In dual mode, both accounts are rotated,
+	// the secondary first, leaving "username" served. The role is stored
+	// once the secondary is rotated, so if the primary then fails, writing
+	// the role again finishes its rotation.
+	for i := range role.accounts() {
+		if err := b.rotateStaticRole(req.Storage, name, role); err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("rotated the password of %s but not of %s, write the role again to rotate it: %s",
+					role.SecondaryUsername, role.Username, err)
+			}
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// rotateStaticRole gives the account of the static role a new password, and
+// stores it with the role, along with when it is next due to be rotated.
+// In dual mode, the account that isn't being served is rotated, and then
+// served in place of the other, so that applications never read a password
+// that is being changed, and the password they read last stays valid until
+// the next rotation. The caller holds the role's lock.
+//
+// The rotation statements run one by one and, being DDL, commit as they
+// run, so the new password is stored after they have all taken effect. If
+// a statement fails once the password has changed, or storing it fails, the
+// account is left with a password nobody knows until the role is rotated
+// again.
+func (b *backend) rotateStaticRole(s logical.Storage, name string, role *staticRoleEntry) error {
+	role.RotationAttempts++
+	role.LastRotationAttempt = time.Now().UTC()
+
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+	password, err := role.generatePassword(b.PasswordMode())
+	if err != nil {
+		return err
+	}
+
+	// The password is passed as a bind variable, as for other users
+	placeholderUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	placeholder := "VAULT_PASSWORD_" + strings.Replace(placeholderUUID, "-", "", -1)
+	username := role.idleUsername()
+	stmts, err := renderStatements(role.rotationStatements(), map[string]string{
+		"name":     username,
+		"password": placeholder,
+	})
+	if err != nil {
+		return err
+	}
+	for i, stmt := range stmts {
+		query, args := bindPassword(stmt, placeholder, password)
+		if _, err := db.Exec(query, args...); err != nil {
+			return fmt.Errorf("could not rotate the password of %s, after running %d of %d statements: %s",
+				username, i, len(stmts), err)
+		}
+	}
+
+	switch {
+	case !role.dual():
+		role.Password = password
+	case role.SecondaryActive:
+		role.Password = password
+		role.SecondaryActive = false
+	default:
+		role.SecondaryPassword = password
+		role.SecondaryActive = true
+	}
+	role.LastVaultRotation = time.Now().UTC()
+	role.NextVaultRotation = role.nextRotation(role.LastVaultRotation)
+	role.RotationFailures = 0
+	role.LastRotationError = ""
+	role.NextRotationRetry = time.Time{}
+	if err := b.putStaticRole(s, name, role); err != nil {
+		return fmt.Errorf("rotated the password of %s but could not store it: %s", username, err)
+	}
+	b.staticRotations.schedule(name, role)
+	return nil
+}
+
+// staticRoleEntry is a role managing the password of an existing account,
+// rather than issuing users. The current password is stored with it.
+type staticRoleEntry struct {
+	Username             string        `json:"username" mapstructure:"username" structs:"username"`
+	Password             string        `json:"password" mapstructure:"password" structs:"password"`
+	LastVaultRotation    time.Time     `json:"last_vault_rotation" mapstructure:"last_vault_rotation" structs:"last_vault_rotation"`
+	RotationPeriod       time.Duration `json:"rotation_period" mapstructure:"rotation_period" structs:"rotation_period"`
+	RotationSchedule     string        `json:"rotation_schedule" mapstructure:"rotation_schedule" structs:"rotation_schedule"`
+	RotationWindow       time.Duration `json:"rotation_window" mapstructure:"rotation_window" structs:"rotation_window"`
+	NextVaultRotation    time.Time     `json:"next_vault_rotation" mapstructure:"next_vault_rotation" structs:"next_vault_rotation"`
+	RotationStatements   string        `json:"rotation_statements" mapstructure:"rotation_statements" structs:"rotation_statements"`
+	RotationMode         string        `json:"rotation_mode" mapstructure:"rotation_mode" structs:"rotation_mode"`
+	SecondaryUsername    string        `json:"secondary_username" mapstructure:"secondary_username" structs:"secondary_username"`
+	SecondaryPassword    string        `json:"secondary_password" mapstructure:"secondary_password" structs:"secondary_password"`
+	SecondaryActive      bool          `json:"secondary_active" mapstructure:"secondary_active" structs:"secondary_active"`
+	RotationFailures     int           `json:"rotation_failures" mapstructure:"rotation_failures" structs:"rotation_failures"`
+	LastRotationError    string        `json:"last_rotation_error" mapstructure:"last_rotation_error" structs:"last_rotation_error"`
+	NextRotationRetry    time.Time     `json:"next_rotation_retry" mapstructure:"next_rotation_retry" structs:"next_rotation_retry"`
+	RotationAttempts     int           `json:"rotation_attempts" mapstructure:"rotation_attempts" structs:"rotation_attempts"`
+	PasswordMode         string        `json:"password_mode" mapstructure:"password_mode" structs:"password_mode"`
+	PasswordLength       int           `json:"password_length" mapstructure:"password_length" structs:"password_length"`
+	PasswordSpecialChars string        `json:"password_special_chars" mapstructure:"password_special_chars" structs:"password_special_chars"`
+	LastRotationAttempt  time.Time     `json:"last_rotation_attempt" mapstructure:"last_rotation_attempt" structs:"last_rotation_attempt"`
+}
+
+// passwordMode returns the role's password mode, which is empty for roles
+// that don't exist yet, or that use the connection's.
+func (r *staticRoleEntry) passwordMode() string {
+	if r == nil {
+		return ""
+	}
+	return r.PasswordMode
+}
+
+// generatePassword generates a new password for the role's account, in the
+// role's password mode, or the given default one.
+func (r *staticRoleEntry) generatePassword(defaultMode string) (string, error) {
+	mode := r.PasswordMode
+	if mode == "" {
+		mode = defaultMode
+	}
+	if mode != passwordModeStrong || (r.PasswordLength == 0 && r.PasswordSpecialChars == "") {
+		return generatePassword(mode)
+	}
+
+	length, special := r.PasswordLength, r.PasswordSpecialChars
+	if length == 0 {
+		length = oraclePasswordLength
+	}
+	if special == "" {
+		special = passwordSpecialChars
+	}
+	return generateStrongPassword(length, special)
+}
+
+// validateSpecialChars checks the special characters of strong passwords.
+// Quotes and whitespace are refused, since passwords are substituted into
+// quoted identifiers and string literals.
+func validateSpecialChars(chars string) error {
+	for _, c := range chars {
+		if c > unicode.MaxASCII || !unicode.IsPrint(c) || unicode.IsSpace(c) ||
+			unicode.IsLetter(c) || unicode.IsDigit(c) || c == '"' || c == '\'' {
+			return fmt.Errorf("invalid password_special_chars: %q is not an ASCII special character other than a quote", c)
+		}
+	}
+	return nil
+}
+
+// lastRotationOutcome returns whether the last rotation attempted failed or
+// succeeded, or an empty string if none has been, as for imported
+// passwords.
+func (r *staticRoleEntry) lastRotationOutcome() string {
+	switch {
+	case r.RotationFailures > 0:
+		return "failed"
+	case r.RotationAttempts > 0:
+		return "succeeded"
+	default:
+		return ""
+	}
+}
+
+// formatRotationTime formats a rotation time for responses, as an empty
+// string if there was none.
+func formatRotationTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// dueAt returns when the role is next due to be rotated: at its next
+// rotation, or once the backoff of a failed one has passed.
+func (r *staticRoleEntry) dueAt() time.Time {
+	if r.NextRotationRetry.After(r.NextVaultRotation) {
+		return r.NextRotationRetry
+	}
+	return r.NextVaultRotation
+}
+
+const (
+	// staticRotationModeSingle rotates the password of the role's account in
+	// place
+	staticRotationModeSingle = "single"
+
+	// staticRotationModeDual alternates between two accounts, rotating the
+	// one that isn't being served
+	staticRotationModeDual = "dual"
+)
+
+func (r *staticRoleEntry) rotationMode() string {
+	if r.RotationMode == "" {
+		return staticRotationModeSingle
+	}
+	return r.RotationMode
+}
+
+func (r *staticRoleEntry) dual() bool {
+	return r.RotationMode == staticRotationModeDual
+}
+
+// primaryRotationPending returns whether the role is in dual mode and its
+// primary account has yet to be rotated on creation. Its password is then
+// still known outside Vault, as no imported password was given.
+func (r *staticRoleEntry) primaryRotationPending() bool {
+	return r.dual() && r.Password == ""
+}
+
+// accounts returns the accounts the role manages.
+func (r *staticRoleEntry) accounts() []string {
+	if r.dual() {
+		return []string{r.Username, r.SecondaryUsername}
+	}
+	return []string{r.Username}
+}
+
+// activeCredentials returns the username and password served from
+// static-creds.
+func (r *staticRoleEntry) activeCredentials() (string, string) {
+	if r.dual() && r.SecondaryActive {
+		return r.SecondaryUsername, r.SecondaryPassword
+	}
+	return r.Username, r.Password
+}
+
+// idleUsername returns the account the next rotation changes the password
+// of: the one that isn't being served in dual mode.
+func (r *staticRoleEntry) idleUsername() string {
+	if r.dual() && !r.SecondaryActive {
+		return r.SecondaryUsername
+	}
+	return r.Username
+}
+
+// staticPasswordPlaceholder stands in for the password when rotation
+// statements are checked
+const staticPasswordPlaceholder = "VAULT_PASSWORD_CHECK"
+
+// rotationSchedule returns the role's rotation schedule, which is empty for
+// roles that don't exist yet.
+func (r *staticRoleEntry) rotationSchedule() string {
+	if r == nil {
+		return ""
+	}
+	return r.RotationSchedule
+}
+
+// nextRotation returns when the password is next due to be rotated after
+// the given time, or the zero time if the role isn't rotated on a schedule.
+func (r *staticRoleEntry) nextRotation(from time.Time) time.Time {
+	switch {
+	case r.RotationSchedule != "":
+		// The schedule was checked when the role was written
+		schedule, err := parseCronSchedule(r.RotationSchedule)
+		if err != nil {
+			return time.Time{}
+		}
+		return schedule.next(from)
+	case r.RotationPeriod > 0:
+		return from.Add(r.RotationPeriod)
+	default:
+		return time.Time{}
+	}
+}
+
+// rotationStatements returns the statements rotating the password of the
+// role's account.
+func (r *staticRoleEntry) rotationStatements() string {
+	if r.RotationStatements == "" {
+		return staticRotationSQL
+	}
+	return r.RotationStatements
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the roles managing the passwords of existing accounts.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage static roles. Rather than creating users, a static
+role manages the password of an account that already exists, such as an
+application schema or an integration user, and serves it from
+"static-creds/<name>".
+
+The account must exist when the role is written, and its password is
+rotated straight away, so that only Vault knows it; applications using the
+account must then read it from Vault. To onboard an account without
+breaking the applications already using it, set "skip_initial_rotation" and
+give its current "password": the password is checked by logging in with it,
+and served as it is until the first scheduled rotation. An account can only
+be managed by one static role, and the account of a role can't be changed.
+
+In "dual" rotation mode, the role alternates between two accounts,
+"username" and "secondary_username", which should have the same privileges.
+Each rotation changes the password of the account that isn't being served,
+then serves it in place of the other. Applications never read a password
+that is being changed, and the credentials they read last stay valid until
+the following rotation, so they have a full rotation period to move over.
+
+Passwords are generated as set by the connection's "password_mode", unless
+the role sets its own. Application schemas often have a stricter password
+verify function than the users the backend creates, so in "strong" mode,
+"password_length" and "password_special_chars" set the length of the
+passwords and the special characters they draw from. Special characters
+other than "_", "$" and "#" require the password to be quoted, as the
+default rotation statement does, and may need escaping in the connect
+strings of clients.
+
+Setting "rotation_statements" replaces the ALTER USER the password is
+rotated with, such as to also refresh what depends on it: database links
+connecting as the account, or scheduler and DBMS_CREDENTIAL credentials
+holding its password. The statements run in order and commit as they run,
+so the one changing the account's password should come first, and those
+after it should be safe to run again, since a rotation failing part way
+through is retried in full.
+
+With a "rotation_period", the password is rotated again whenever the period
+has passed since the last rotation, by the backend's periodic function,
+which runs about once a minute. When the next rotation is due is stored
+with the role, so that rotations are kept to across restarts. A rotation
+that fails, such as while the database is down or the account locked, is
+logged and retried with exponential backoff, from a minute up to an hour
+between attempts, and the role keeps serving the password it last set.
+Reading the role returns when the password was last rotated, when a
+rotation was last attempted and whether it succeeded, and the number of
+rotations attempted and of those that failed in a row, so that monitoring
+can alert on roles stuck unrotated. While rotations are failing, it also
+returns the last error, and when the next attempt is due. Changing the
+period reschedules the next rotation from the last one.
+
+Setting "rotation_schedule" instead rotates the password at the times of a
+five field cron expression, in UTC, such as "0 2 * * 0" for 02:00 every
+Sunday, so that rotations land in maintenance windows rather than at
+arbitrary period boundaries. With a "rotation_window", a rotation only runs
+within the window following its scheduled time: one that couldn't, such as
+while Vault was sealed or while a failing rotation was retried, waits for
+the next scheduled time instead of running outside the window.
+
+Deleting a static role leaves the account as it is, with the last password
+Vault gave it.
+`
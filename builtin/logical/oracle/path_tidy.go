@@ -0,0 +1,249 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const issuedUserPrefix = "user/"
+
+// issuedUser records a user created by the backend that hasn't been
+// revoked yet, so that tidy can tell it from users left behind. Users are
+// recorded under their username as stored in DBA_USERS, which is what tidy
+// finds them by. Once the user has been created, the internal data of its
+// lease is recorded too, so that revoke-all can revoke it.
+type issuedUser struct {
+	Role         string                 `json:"role" mapstructure:"role" structs:"role"`
+	SecretType   string                 `json:"secret_type" mapstructure:"secret_type" structs:"secret_type"`
+	CreatedAt    time.Time              `json:"created_at" mapstructure:"created_at" structs:"created_at"`
+	InternalData map[string]interface{} `json:"internal_data" mapstructure:"internal_data" structs:"internal_data"`
+}
+
+// putIssuedUser records a user about to be created. It is recorded before
+// the user exists, so that tidy never sees a user of a live lease as
+// orphaned.
+func (b *backend) putIssuedUser(s logical.Storage, username string, quoted bool, roleName, secretType string) error {
+	return b.storeIssuedUser(s, username, quoted, &issuedUser{
+		Role:       roleName,
+		SecretType: secretType,
+		CreatedAt:  time.Now().UTC(),
+	})
+}
+
+// putIssuedUserData records the internal data of the lease of a user that
+// has been created.
+func (b *backend) putIssuedUserData(s logical.Storage, username string, quoted bool, roleName string, internalData map[string]interface{}) error {
+	user, err := b.issuedUser(s, storedUsername(username, quoted))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		user = &issuedUser{
+			Role:       roleName,
+			SecretType: SecretCredsType,
+			CreatedAt:  time.Now().UTC(),
+		}
+	}
+	user.InternalData = internalData
+	return b.storeIssuedUser(s, username, quoted, user)
+}
+
+func (b *backend) issuedUser(s logical.Storage, storedName string) (*issuedUser, error) {
+	entry, err := s.Get(issuedUserPrefix + storedName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result issuedUser
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) storeIssuedUser(s logical.Storage, username string, quoted bool, user *issuedUser) error {
+	entry, err := logical.StorageEntryJSON(issuedUserPrefix+storedUsername(username, quoted), user)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// deleteIssuedUser removes the record of a user once it has been revoked.
+// Failing to do so only leaves tidy unable to drop the user, so it is logged
+// rather than failing the revocation.
+func (b *backend) deleteIssuedUser(s logical.Storage, username string, quoted bool) {
+	if err := s.Delete(issuedUserPrefix + storedUsername(username, quoted)); err != nil {
+		b.logger.Warn("oracle/deleteIssuedUser: failed to remove issued user", "username", username, "error", err)
+	}
+}
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy$",
+		Fields: map[string]*framework.FieldSchema{
+			"username_pattern": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `LIKE pattern matching the usernames generated by the
+backend, such as 'V\_%'. Backslash escapes "_" and "%". Required.`,
+			},
+
+			"container": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Pluggable database to look for users in. Defaults to
+the database connected to.`,
+			},
+
+			"min_age": &framework.FieldSchema{
+				Type:    framework.TypeDurationSecond,
+				Default: 3600,
+				Description: `Users created more recently than this are left alone,
+since their creation may still be in progress.`,
+			},
+
+			"dry_run": &framework.FieldSchema{
+				Type:    framework.TypeBool,
+				Default: true,
+				Description: `If set, orphaned users are only reported. Unset it to
+drop them.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pattern := data.Get("username_pattern").(string)
+	if pattern == "" {
+		return logical.ErrorResponse("username_pattern is required"), nil
+	}
+	container := data.Get("container").(string)
+	if container != "" && !oracleIdentifierRegex.MatchString(container) {
+		return logical.ErrorResponse(fmt.Sprintf("invalid container: %q", container)), nil
+	}
+	minAge := time.Duration(data.Get("min_age").(int)) * time.Second
+	if minAge < 0 {
+		return logical.ErrorResponse("min_age must not be negative"), nil
+	}
+	dryRun := data.Get("dry_run").(bool)
+
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return nil, err
+		}
+		defer restoreContainer()
+	}
+
+	rows, err := tx.Query(fmt.Sprintf(tidyUsersSQL, quoteLiteral(pattern), int64(minAge/time.Second)))
+	if err != nil {
+		return nil, fmt.Errorf("could not list users: %s", err)
+	}
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreContainer(); err != nil {
+		return nil, err
+	}
+	tx.Rollback()
+
+	resp := &logical.Response{}
+	orphaned := []string{}
+	dropped := []string{}
+	for _, username := range usernames {
+		entry, err := req.Storage.Get(issuedUserPrefix + username)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			continue
+		}
+		orphaned = append(orphaned, username)
+		if dryRun {
+			continue
+		}
+
+		// The username is as stored, so it is quoted to be dropped as is
+		b.logger.Info("oracle/tidy: dropping orphaned user", "username", username)
+		if err := b.dropPartialUser(req.Storage, &walUser{
+			Username:       username,
+			Container:      container,
+			QuotedUsername: true,
+		}); err != nil {
+			resp.AddWarning(fmt.Sprintf("Could not drop user %s: %s", username, err))
+			continue
+		}
+		dropped = append(dropped, username)
+	}
+
+	resp.Data = map[string]interface{}{
+		"orphaned": orphaned,
+		"dropped":  dropped,
+	}
+	return resp, nil
+}
+
+const pathTidyHelpSyn = `
+Find, and optionally drop, users left behind by the backend.
+`
+
+const pathTidyHelpDesc = `
+Users can be left behind in the database without a lease, for example when
+Vault crashes part way through creating one, when revocation fails for good,
+or when storage is restored from a snapshot taken before users were created.
+
+This path lists the open accounts in DBA_USERS whose username matches the
+"username_pattern", and reports those the backend hasn't issued, or has
+revoked, as "orphaned". The pattern is a LIKE pattern, with backslash as the
+escape character, and should only match usernames generated by the backend,
+such as 'V\_%' for random usernames. Locked accounts are left alone, since
+they include users of roles that lock users on revocation, and accounts
+created more recently than "min_age" are skipped, since their creation may
+still be in progress.
+
+By default, orphaned users are only reported. With "dry_run" unset, they are
+dropped with CASCADE, after their sessions are killed.
+
+Users are looked up in the database connected to, or in the pluggable
+database given as "container".
+
+Users issued before the backend kept track of them have no record, so they
+are reported as orphaned even if their lease is still active. Review a dry
+run before dropping users, until the leases issued before then have expired.
+`
@@ -0,0 +1,222 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const pendingDropPrefix = "pending-drop/"
+
+// pendingDrop is a user whose lease has been revoked by a role with a drop
+// grace period. The user is locked, and dropped once the period has passed
+// by revoking it again from the internal data of its lease. Users of roles
+// with "drop_when_drained" are dropped as soon as they have no sessions left,
+// with DropAt as the deadline.
+type pendingDrop struct {
+	InternalData map[string]interface{} `json:"internal_data" mapstructure:"internal_data" structs:"internal_data"`
+	DropAt       time.Time              `json:"drop_at" mapstructure:"drop_at" structs:"drop_at"`
+	WhenDrained  bool                   `json:"when_drained" mapstructure:"when_drained" structs:"when_drained"`
+}
+
+func pathListPendingDrops(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "pending-drops/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathPendingDropList,
+		},
+
+		HelpSynopsis:    pathPendingDropsHelpSyn,
+		HelpDescription: pathPendingDropsHelpDesc,
+	}
+}
+
+func pathPendingDrops(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "pending-drops/(?P<name>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the locked user.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathPendingDropRead,
+			logical.DeleteOperation: b.pathPendingDropDelete,
+		},
+
+		HelpSynopsis:    pathPendingDropsHelpSyn,
+		HelpDescription: pathPendingDropsHelpDesc,
+	}
+}
+
+func (b *backend) pendingDrop(s logical.Storage, username string) (*pendingDrop, error) {
+	entry, err := s.Get(pendingDropPrefix + username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result pendingDrop
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathPendingDropList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(pendingDropPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathPendingDropRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pending, err := b.pendingDrop(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, nil
+	}
+
+	respData := map[string]interface{}{
+		"drop_at":      pending.DropAt.Format(time.RFC3339),
+		"when_drained": pending.WhenDrained,
+	}
+	for _, key := range []string{"role", "container", "reason"} {
+		if value, ok := pending.InternalData[key]; ok {
+			respData[key] = value
+		}
+	}
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// pathPendingDropDelete cancels the drop of a locked user, to recover it.
+// The user stays locked, and is no longer managed by the backend.
+func (b *backend) pathPendingDropDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(pendingDropPrefix + data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// deferDrop records a locked user to be dropped once the grace period has
+// passed, or once its sessions have drained if whenDrained is set.
+func (b *backend) deferDrop(s logical.Storage, username string, internalData map[string]interface{}, grace time.Duration, whenDrained bool) error {
+	entry, err := logical.StorageEntryJSON(pendingDropPrefix+username, &pendingDrop{
+		InternalData: internalData,
+		DropAt:       time.Now().Add(grace).UTC(),
+		WhenDrained:  whenDrained,
+	})
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// dropPendingUsers drops the locked users whose grace period has passed, and
+// those waiting for their sessions to drain that have none left. Users that
+// fail to be dropped are retried on the next run.
+func (b *backend) dropPendingUsers(s logical.Storage) error {
+	usernames, err := s.List(pendingDropPrefix)
+	if err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		pending, err := b.pendingDrop(s, username)
+		if err != nil {
+			return err
+		}
+		if pending == nil {
+			continue
+		}
+		if time.Now().Before(pending.DropAt) {
+			if !pending.WhenDrained {
+				continue
+			}
+			drained, err := b.userDrained(s, pending.InternalData)
+			if err != nil {
+				b.logger.Warn("oracle/dropPendingUsers: failed to look up sessions", "username", username, "error", err)
+				continue
+			}
+			if !drained {
+				continue
+			}
+		}
+
+		b.logger.Trace("oracle/dropPendingUsers: dropping user", "username", username)
+		if _, err := b.revokeCreds(s, pending.InternalData); err != nil {
+			b.logger.Warn("oracle/dropPendingUsers: failed to drop user", "username", username, "error", err)
+			continue
+		}
+		if err := s.Delete(pendingDropPrefix + username); err != nil {
+			return fmt.Errorf("failed to remove pending drop of %s: %s", username, err)
+		}
+	}
+	return nil
+}
+
+// userDrained returns whether the user of a lease has no sessions left. It
+// is looked up on its own rather than batched, since the periodic function
+// checks the pending drops one at a time.
+func (b *backend) userDrained(s logical.Storage, internalData map[string]interface{}) (bool, error) {
+	username, ok := internalData["username"].(string)
+	if !ok {
+		return false, fmt.Errorf("secret is missing username internal data")
+	}
+	quoted, _ := internalData["quoted_username"].(bool)
+	if err := validateUsername(username, quoted); err != nil {
+		return false, err
+	}
+	container, _ := internalData["container"].(string)
+	if container != "" && !oracleIdentifierRegex.MatchString(container) {
+		return false, fmt.Errorf("invalid container %q", container)
+	}
+
+	db, err := b.DB(s)
+	if err != nil {
+		return false, err
+	}
+	config, err := b.RevocationConfig(s)
+	if err != nil {
+		return false, err
+	}
+
+	stored := storedUsername(username, quoted)
+	sessions, err := querySessions(db, config.sessionQuerySQL(), []string{stored}, container)
+	if err != nil {
+		return false, err
+	}
+	return len(sessions[stored]) == 0, nil
+}
+
+const pathPendingDropsHelpSyn = `
+List and cancel the drops of users locked during their grace period.
+`
+
+const pathPendingDropsHelpDesc = `
+Roles with a "drop_grace_period" lock users on revocation rather than
+dropping them, and drop them once the grace period has passed. Until then,
+the locked users are listed here, with the time they will be dropped.
+
+Roles that also set "drop_when_drained" drop their users as soon as they
+have no sessions left, rather than waiting for the whole period.
+
+Deleting a user from this path cancels its drop, to recover it after a lease
+was revoked by accident. The user stays locked, with its password expired,
+and is no longer managed by Vault: unlock it and drop it outside of Vault
+once it is no longer needed.
+`
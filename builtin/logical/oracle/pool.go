@@ -0,0 +1,210 @@
+package oracle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// poolDisplayName is the display name users are generated with when they
+// are created for a pool, since there is no requester yet
+const poolDisplayName = "pool"
+
+// pooledCreds are credentials created ahead of time for a role with a pool,
+// waiting to be handed out.
+type pooledCreds struct {
+	Data         map[string]interface{} `json:"data" mapstructure:"data" structs:"data"`
+	InternalData map[string]interface{} `json:"internal_data" mapstructure:"internal_data" structs:"internal_data"`
+	TTL          time.Duration          `json:"ttl" mapstructure:"ttl" structs:"ttl"`
+	Renewable    bool                   `json:"renewable" mapstructure:"renewable" structs:"renewable"`
+	Warnings     []string               `json:"warnings" mapstructure:"warnings" structs:"warnings"`
+	CreatedAt    time.Time              `json:"created_at" mapstructure:"created_at" structs:"created_at"`
+}
+
+func poolPrefix(roleName string) string {
+	return "pool/" + roleName + "/"
+}
+
+// lockPool serializes taking credentials from the role's pool with draining
+// it. The returned function unlocks it.
+func (b *backend) lockPool(roleName string) func() {
+	lock := locksutil.LockForKey(b.poolLocks, roleName)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// takePooledCreds hands out credentials from the role's pool. It returns
+// nil if the pool is empty.
+func (b *backend) takePooledCreds(req *logical.Request, roleName, reason string) (*logical.Response, error) {
+	defer b.lockPool(roleName)()
+
+	ids, err := req.Storage.List(poolPrefix(roleName))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		key := poolPrefix(roleName) + id
+		entry, err := req.Storage.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var creds pooledCreds
+		if err := entry.DecodeJSON(&creds); err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Delete(key); err != nil {
+			return nil, err
+		}
+
+		// The lease starts now rather than when the user was created
+		ttl := creds.TTL
+		if ttl == 0 {
+			lease, err := b.Lease(req.Storage)
+			if err != nil {
+				return nil, err
+			}
+			ttl, _ = b.leaseTTL(lease)
+		}
+		creds.Data["expiration"] = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+		creds.InternalData["token_accessor"] = req.ClientTokenAccessor
+		creds.InternalData["reason"] = reason
+
+		resp := b.Secret(SecretCredsType).Response(creds.Data, creds.InternalData)
+		resp.Secret.TTL = ttl
+		resp.Secret.Renewable = creds.Renewable
+		resp.Warnings = creds.Warnings
+		return resp, nil
+	}
+	return nil, nil
+}
+
+// fillPool tops up the role's pool in the background. Only one fill runs
+// for each role at a time; if one is already running, it picks up the
+// credentials just taken.
+func (b *backend) fillPool(s logical.Storage, mountPoint, roleName string) {
+	b.poolFillingLock.Lock()
+	defer b.poolFillingLock.Unlock()
+	if b.poolFilling == nil {
+		b.poolFilling = make(map[string]bool)
+	}
+	if b.poolFilling[roleName] {
+		return
+	}
+	b.poolFilling[roleName] = true
+
+	go func() {
+		defer func() {
+			b.poolFillingLock.Lock()
+			delete(b.poolFilling, roleName)
+			b.poolFillingLock.Unlock()
+		}()
+
+		if err := b.topUpPool(s, mountPoint, roleName); err != nil {
+			b.logger.Warn("oracle/fillPool: failed to fill pool", "role", roleName, "error", err)
+		}
+	}()
+}
+
+// topUpPool creates users for the role until its pool is full. The role is
+// read again for each user, so that a fill stops when the pool is disabled.
+func (b *backend) topUpPool(s logical.Storage, mountPoint, roleName string) error {
+	for {
+		role, err := b.Role(s, roleName)
+		if err != nil {
+			return err
+		}
+		if role == nil || role.PoolSize == 0 {
+			return nil
+		}
+		ids, err := s.List(poolPrefix(roleName))
+		if err != nil {
+			return err
+		}
+		if len(ids) >= role.PoolSize {
+			return nil
+		}
+
+		b.logger.Trace("oracle/fillPool: creating pooled user", "role", roleName)
+		resp, err := b.createCreds(&logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "creds/" + roleName,
+			Storage:     s,
+			MountPoint:  mountPoint,
+			DisplayName: poolDisplayName,
+		}, &framework.FieldData{
+			Raw:    map[string]interface{}{"name": roleName},
+			Schema: pathRoleCreate(b).Fields,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.IsError() {
+			return resp.Error()
+		}
+
+		id, err := uuid.GenerateUUID()
+		if err == nil {
+			var entry *logical.StorageEntry
+			entry, err = logical.StorageEntryJSON(poolPrefix(roleName)+id, &pooledCreds{
+				Data:         resp.Data,
+				InternalData: resp.Secret.InternalData,
+				TTL:          resp.Secret.TTL,
+				Renewable:    resp.Secret.Renewable,
+				Warnings:     resp.Warnings,
+				CreatedAt:    time.Now().UTC(),
+			})
+			if err == nil {
+				err = s.Put(entry)
+			}
+		}
+		if err != nil {
+			// Nothing refers to the user if it isn't recorded, so drop it
+			if _, revokeErr := b.revokeCreds(s, resp.Secret.InternalData); revokeErr != nil {
+				b.logger.Warn("oracle/fillPool: failed to drop unrecorded pooled user", "username", resp.Data["username"], "error", revokeErr)
+			}
+			return err
+		}
+	}
+}
+
+// drainPool revokes the credentials waiting in the role's pool, so that
+// they aren't handed out once the role has changed or been deleted.
+func (b *backend) drainPool(s logical.Storage, roleName string) error {
+	defer b.lockPool(roleName)()
+
+	ids, err := s.List(poolPrefix(roleName))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		key := poolPrefix(roleName) + id
+		entry, err := s.Get(key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var creds pooledCreds
+		if err := entry.DecodeJSON(&creds); err != nil {
+			return err
+		}
+		b.logger.Trace("oracle/drainPool: revoking pooled user", "role", roleName, "username", creds.Data["username"])
+		if _, err := b.revokeCreds(s, creds.InternalData); err != nil {
+			return fmt.Errorf("could not revoke pooled user %v: %s", creds.Data["username"], err)
+		}
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package oracle
+
+import (
+	"sync"
+	"time"
+)
+
+// issuanceLimiter counts the credentials requested for each role in fixed
+// windows, so that a runaway client can't flood the database with CREATE
+// USER statements. Counts are kept in memory, so each Vault node limits the
+// requests it handles on its own.
+type issuanceLimiter struct {
+	sync.Mutex
+	windows map[string]*issuanceWindow
+}
+
+// issuanceWindow is the window requests for a role are currently counted in.
+type issuanceWindow struct {
+	start  time.Time
+	period time.Duration
+	count  int
+}
+
+// allow counts a request for the role and returns whether it is within the
+// limit for the period. If it isn't, it also returns how long until the
+// next window starts.
+func (l *issuanceLimiter) allow(role string, limit int, period time.Duration, now time.Time) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.windows == nil {
+		l.windows = make(map[string]*issuanceWindow)
+	}
+
+	window, ok := l.windows[role]
+	if !ok || window.period != period || !now.Before(window.start.Add(period)) {
+		window = &issuanceWindow{
+			start:  now,
+			period: period,
+		}
+		l.windows[role] = window
+	}
+
+	if window.count >= limit {
+		return false, window.start.Add(period).Sub(now)
+	}
+	window.count++
+	return true, 0
+}
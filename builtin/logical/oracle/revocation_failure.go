@@ -0,0 +1,187 @@
+package oracle
+
+import (
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const revocationFailurePrefix = "revocation-failure/"
+
+// revocationFailure is a revocation that failed with an error other than the
+// database being unreachable, so that it won't be retried by the backend.
+// It is kept, along with the internal data of the lease, until the user is
+// revoked or the failure is dismissed.
+type revocationFailure struct {
+	InternalData  map[string]interface{} `json:"internal_data" mapstructure:"internal_data" structs:"internal_data"`
+	Error         string                 `json:"error" mapstructure:"error" structs:"error"`
+	Attempts      int                    `json:"attempts" mapstructure:"attempts" structs:"attempts"`
+	FirstFailedAt time.Time              `json:"first_failed_at" mapstructure:"first_failed_at" structs:"first_failed_at"`
+	LastFailedAt  time.Time              `json:"last_failed_at" mapstructure:"last_failed_at" structs:"last_failed_at"`
+}
+
+func pathListRevocationFailures(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revocation-failures/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRevocationFailureList,
+		},
+
+		HelpSynopsis:    pathRevocationFailuresHelpSyn,
+		HelpDescription: pathRevocationFailuresHelpDesc,
+	}
+}
+
+func pathRevocationFailures(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revocation-failures/(?P<name>.+)",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the user that failed to be revoked.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRevocationFailureRead,
+			logical.DeleteOperation: b.pathRevocationFailureDelete,
+		},
+
+		HelpSynopsis:    pathRevocationFailuresHelpSyn,
+		HelpDescription: pathRevocationFailuresHelpDesc,
+	}
+}
+
+func (b *backend) revocationFailure(s logical.Storage, username string) (*revocationFailure, error) {
+	entry, err := s.Get(revocationFailurePrefix + username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result revocationFailure
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathRevocationFailureList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(revocationFailurePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathRevocationFailureRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	failure, err := b.revocationFailure(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if failure == nil {
+		return nil, nil
+	}
+
+	respData := map[string]interface{}{
+		"error":           failure.Error,
+		"attempts":        failure.Attempts,
+		"first_failed_at": failure.FirstFailedAt.Format(time.RFC3339),
+		"last_failed_at":  failure.LastFailedAt.Format(time.RFC3339),
+	}
+	for _, key := range []string{"role", "container", "reason", "issued_at"} {
+		if value, ok := failure.InternalData[key]; ok {
+			respData[key] = value
+		}
+	}
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// pathRevocationFailureDelete dismisses a failure, once the user has been
+// dealt with outside of Vault.
+func (b *backend) pathRevocationFailureDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(revocationFailurePrefix + data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// revocationFailureKey returns the name a failed revocation is recorded
+// under: the username as stored in DBA_USERS, so that the failures of a
+// lease revoked again are counted together.
+func revocationFailureKey(internalData map[string]interface{}) string {
+	username, _ := internalData["username"].(string)
+	quoted, _ := internalData["quoted_username"].(bool)
+	return storedUsername(username, quoted)
+}
+
+// recordRevocationFailure records a revocation that failed. Failing to do so
+// only leaves the failure to the server log, so it is logged rather than
+// returned.
+func (b *backend) recordRevocationFailure(s logical.Storage, internalData map[string]interface{}, revokeErr error) {
+	key := revocationFailureKey(internalData)
+	if key == "" {
+		return
+	}
+
+	failure, err := b.revocationFailure(s, key)
+	if err != nil {
+		b.logger.Warn("oracle/recordRevocationFailure: failed to read revocation failure", "username", key, "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	if failure == nil {
+		failure = &revocationFailure{
+			FirstFailedAt: now,
+		}
+	}
+	failure.InternalData = internalData
+	failure.Error = revokeErr.Error()
+	failure.Attempts++
+	failure.LastFailedAt = now
+
+	entry, err := logical.StorageEntryJSON(revocationFailurePrefix+key, failure)
+	if err == nil {
+		err = s.Put(entry)
+	}
+	if err != nil {
+		b.logger.Warn("oracle/recordRevocationFailure: failed to record revocation failure", "username", key, "error", err)
+	}
+}
+
+// clearRevocationFailure removes the record of earlier failures once a user
+// has been revoked.
+func (b *backend) clearRevocationFailure(s logical.Storage, internalData map[string]interface{}) {
+	key := revocationFailureKey(internalData)
+	if key == "" {
+		return
+	}
+	if err := s.Delete(revocationFailurePrefix + key); err != nil {
+		b.logger.Warn("oracle/clearRevocationFailure: failed to remove revocation failure", "username", key, "error", err)
+	}
+}
+
+const pathRevocationFailuresHelpSyn = `
+List and dismiss revocations that failed.
+`
+
+const pathRevocationFailuresHelpDesc = `
+Revocations that fail with an error other than the database being
+unreachable are recorded here, by username, with the last error, the number
+of attempts, and the role and reason the user was issued with. Revocations
+failing because the database can't be reached are queued and retried by the
+backend instead.
+
+A failure is removed once the user is revoked, for example when Vault
+retries the revocation of its lease. Once the user has been dealt with
+outside of Vault, delete the failure to dismiss it.
+`
@@ -0,0 +1,187 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// walTypeUser is the kind of WAL entry written before a user is created
+const walTypeUser = "user"
+
+// walTypeRevocation is the kind of WAL entry queueing the revocation of
+// credentials while the database can't be reached
+const walTypeRevocation = "revocation"
+
+// walUser records a user that is about to be created, along with what is
+// created with it, so that it can be dropped if its creation never
+// completes.
+type walUser struct {
+	Username       string `json:"username" mapstructure:"username"`
+	Role           string `json:"role" mapstructure:"role"`
+	Container      string `json:"container" mapstructure:"container"`
+	QuotedUsername bool   `json:"quoted_username" mapstructure:"quoted_username"`
+	ServiceTrigger string `json:"service_trigger" mapstructure:"service_trigger"`
+	ExpiryJob      string `json:"expiry_job" mapstructure:"expiry_job"`
+}
+
+// walRevocation records the revocation of credentials that failed because
+// the database couldn't be reached, so that it is retried.
+type walRevocation struct {
+	InternalData map[string]interface{} `json:"internal_data" mapstructure:"internal_data"`
+}
+
+func (b *backend) walRollback(req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case walTypeUser:
+		return b.userRollback(req, data)
+	case walTypeRevocation:
+		return b.revocationRollback(req, data)
+	default:
+		return fmt.Errorf("unknown type to rollback")
+	}
+}
+
+// userRollback drops a user whose creation never completed.
+func (b *backend) userRollback(req *logical.Request, data interface{}) error {
+	var entry walUser
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	return b.dropPartialUser(req.Storage, &entry)
+}
+
+// revocationRollback retries a queued revocation. The WAL entry is kept
+// while the database is unreachable. A user that no longer exists was
+// dropped by the attempt that was queued, before the database became
+// unreachable. Other failures won't go away by retrying, so, as on
+// revocation, they are recorded to be listed under revocation-failures,
+// and the WAL entry is cleared.
+func (b *backend) revocationRollback(req *logical.Request, data interface{}) error {
+	var entry walRevocation
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	// Without a connection, the revocation stays queued
+	if _, err := b.DB(req.Storage); err != nil {
+		return err
+	}
+
+	b.logger.Trace("oracle/revocationRollback: retrying queued revocation", "username", entry.InternalData["username"])
+	_, err := b.revokeSecretCreds(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   req.Storage,
+		Secret: &logical.Secret{
+			InternalData: entry.InternalData,
+		},
+	})
+	switch {
+	case err == nil || isNotExistError(err):
+		b.clearRevocationFailure(req.Storage, entry.InternalData)
+	case isTransientError(err):
+		return err
+	default:
+		b.logger.Warn("oracle/revocationRollback: queued revocation failed", "username", entry.InternalData["username"], "error", err)
+		b.recordRevocationFailure(req.Storage, entry.InternalData, err)
+	}
+	return nil
+}
+
+// dropPartialUser drops a user that was never handed out, along with the
+// logon trigger and scheduler job created with it. Creation may have failed
+// at any point, so anything that doesn't exist is skipped.
+func (b *backend) dropPartialUser(s logical.Storage, entry *walUser) error {
+	if err := validateUsername(entry.Username, entry.QuotedUsername); err != nil {
+		return err
+	}
+	for _, name := range []string{entry.Container, entry.ServiceTrigger, entry.ExpiryJob} {
+		if name != "" && !oracleIdentifierRegex.MatchString(name) {
+			return fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+
+	nameIdentifier := entry.Username
+	if entry.QuotedUsername {
+		nameIdentifier = quoteIdentifier(entry.Username)
+	}
+
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+
+	revocationConfig, err := b.RevocationConfig(s)
+	if err != nil {
+		return err
+	}
+	if err := b.killUserSessions(db, revocationConfig, revocationConfig.sessionKillSQL(), entry.Username, entry.QuotedUsername, entry.Container, nil); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if entry.Container != "" {
+		restoreContainer, err = switchContainer(tx, entry.Container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	// The user is dropped whatever the role's revocation settings are.
+	// ORA-04080, ORA-27475 and ORA-01918 are returned for triggers, jobs and
+	// users that don't exist.
+	var stmts []string
+	if entry.ServiceTrigger != "" {
+		stmts = append(stmts, fmt.Sprintf(dropTriggerSQL, entry.ServiceTrigger))
+	}
+	if entry.ExpiryJob != "" {
+		stmts = append(stmts, fmt.Sprintf(dropJobSQL, entry.ExpiryJob))
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil && !isNotExistError(err) {
+			return err
+		}
+	}
+
+	// The user itself is dropped through the revocation package, if there is
+	// one
+	if pkg := revocationConfig.revocationPackage(); pkg != "" {
+		_, err = tx.Exec(fmt.Sprintf(packageDropUserSQL, pkg), storedUsername(entry.Username, entry.QuotedUsername), 1)
+	} else {
+		_, err = tx.Exec(fmt.Sprintf(rollbackUserSQL, nameIdentifier))
+	}
+	if err != nil && !isNotExistError(err) {
+		return err
+	}
+
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	b.deleteIssuedUser(s, entry.Username, entry.QuotedUsername)
+	return nil
+}
+
+// isNotExistError returns whether the error is Oracle reporting that the
+// trigger, job or user being dropped doesn't exist.
+func isNotExistError(err error) bool {
+	for _, code := range []string{"ORA-04080", "ORA-27475", "ORA-01918"} {
+		if strings.Contains(err.Error(), code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1027 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const SecretCredsType = "creds"
+
+func secretCreds(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username",
+			},
+
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password",
+			},
+
+			"expiration": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Time the credentials expire, unless renewed",
+			},
+
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Role the credentials were issued for",
+			},
+
+			"db_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Database the user was created in",
+			},
+
+			"connect_string": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Address of the database, e.g. host:port/service_name",
+			},
+
+			"jdbc_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Address of the database as a JDBC thin driver URL",
+			},
+
+			"grants": &framework.FieldSchema{
+				Type:        framework.TypeMap,
+				Description: "System privileges, roles and object privileges granted to the user",
+			},
+
+			"sqlnet_ora": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "sqlnet.ora settings for connecting over TLS",
+			},
+
+			"tnsnames_ora": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "tnsnames.ora entry for connecting over TLS",
+			},
+
+			"wallet_location": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Directory of the wallet holding the trusted certificates",
+			},
+		},
+
+		Renew:  b.secretCredsRenew,
+		Revoke: b.secretCredsRevoke,
+	}
+}
+
+func (b *backend) secretCredsRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// The job enforcing the expiry of the credentials, if there is one, is
+	// moved to the new expiry. Its name and container are substituted into
+	// SQL, so check them as revocation does.
+	var job, container string
+	if jobRaw, ok := req.Secret.InternalData["expiry_job"]; ok {
+		job, _ = jobRaw.(string)
+	}
+	if containerRaw, ok := req.Secret.InternalData["container"]; ok {
+		container, _ = containerRaw.(string)
+	}
+	for field, name := range map[string]string{
+		"container":  container,
+		"expiry_job": job,
+	} {
+		if name != "" && !oracleIdentifierRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid %s internal data: %q", field, name)
+		}
+	}
+
+	// Get the lease information
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	// Renewals are capped at the max TTL currently in config/lease, so that
+	// shortening it applies to existing leases too. They are also capped at
+	// the max TTL in effect when the credentials were issued, so that
+	// lengthening it doesn't extend existing leases. Credentials issued
+	// before it was recorded only use the current max TTL.
+	leaseMax := lease.LeaseMax
+	var issuedMaxTTL time.Duration
+	if maxTTLRaw, ok := req.Secret.InternalData["max_ttl"]; ok {
+		issuedMaxTTL, err = parseutil.ParseDurationSecond(maxTTLRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_ttl internal data: %s", err)
+		}
+		if issuedMaxTTL > 0 && (leaseMax == 0 || issuedMaxTTL < leaseMax) {
+			leaseMax = issuedMaxTTL
+		}
+	}
+
+	if roleNameRaw, ok := req.Secret.InternalData["role"]; ok {
+		role, err := b.Role(req.Storage, roleNameRaw.(string))
+		if err != nil {
+			return nil, err
+		}
+		if role != nil {
+			b.logConfigDrift(req, "secretCredsRenew", role)
+		}
+	}
+
+	// Oracle users have no expiration of their own, so there is nothing to
+	// update in the database on renewal. The TTL is the one currently in
+	// config/lease, capped at the max TTL as it is on issuance.
+	ttl, _ := b.leaseTTL(lease)
+	if leaseMax > 0 && ttl > leaseMax {
+		ttl = leaseMax
+	}
+	f := framework.LeaseExtend(ttl, leaseMax, b.System())
+	resp, err := f(req, d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Renewals are capped at the max TTL of the lease
+	increment := req.Secret.Increment
+	if increment <= 0 {
+		increment = ttl
+	}
+	if resp.Secret.TTL < increment {
+		resp.AddWarning(fmt.Sprintf(
+			"TTL of %s would pass the max TTL of the lease and was capped at %s",
+			increment, resp.Secret.TTL))
+	}
+	if issuedMaxTTL > 0 && leaseMax < issuedMaxTTL {
+		resp.AddWarning(fmt.Sprintf(
+			"The max TTL of the lease was reduced from %s to %s by config/lease",
+			issuedMaxTTL, leaseMax))
+	}
+
+	if job != "" {
+		if err := b.rescheduleExpiryJob(req.Storage, job, container, resp.Secret.TTL); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// rescheduleExpiryJob moves the scheduler job enforcing the expiry of
+// credentials so that it runs once the TTL has passed.
+func (b *backend) rescheduleExpiryJob(s logical.Storage, job, container string, ttl time.Duration) error {
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(rescheduleJobSQL, job, int64(ttl/time.Second))); err != nil {
+		return err
+	}
+
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// secretCredsRevoke revokes the credentials. If the database can't be
+// reached, the revocation is queued in the WAL and retried by the WAL
+// rollback, rather than failing, so that the user isn't left behind once
+// Vault gives up on the lease. Other failures are recorded, to be listed
+// under revocation-failures.
+func (b *backend) secretCredsRevoke(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.revokeSecretCreds(req)
+	if err == nil {
+		b.clearRevocationFailure(req.Storage, req.Secret.InternalData)
+		return resp, nil
+	}
+	if !isTransientError(err) {
+		b.recordRevocationFailure(req.Storage, req.Secret.InternalData, err)
+		return resp, err
+	}
+
+	if _, walErr := framework.PutWAL(req.Storage, walTypeRevocation, &walRevocation{
+		InternalData: req.Secret.InternalData,
+	}); walErr != nil {
+		b.logger.Warn("oracle/secretCredsRevoke: failed to queue revocation", "error", walErr)
+		return nil, err
+	}
+	b.logger.Warn("oracle/secretCredsRevoke: database unavailable, queued revocation", "username", req.Secret.InternalData["username"], "error", err)
+	if resp == nil {
+		resp = &logical.Response{}
+	}
+	resp.AddWarning(fmt.Sprintf("The database could not be reached (%s), so the user will be revoked once it can be", err))
+	return resp, nil
+}
+
+func (b *backend) revokeSecretCreds(req *logical.Request) (resp *logical.Response, retErr error) {
+	target, err := b.revocationTarget(req.Storage, req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+	username := target.Username
+	quotedUsername := target.Quoted
+	container := target.Container
+	nameIdentifier := target.nameIdentifier()
+
+	revocationConfig, err := b.RevocationConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	revocationSQL := revocationConfig.revocationSQL()
+
+	var role *roleEntry
+	roleNameRaw, ok := req.Secret.InternalData["role"]
+	if ok {
+		var err error
+		role, err = b.Role(req.Storage, roleNameRaw.(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			if resp == nil {
+				resp = &logical.Response{}
+			}
+			resp.AddWarning(fmt.Sprintf("Role %q cannot be found. Using default revocation SQL.", roleNameRaw.(string)))
+		} else {
+			revocationSQL = role.revocationSQL(revocationConfig)
+			b.logConfigDrift(req, "secretCredsRevoke", role)
+		}
+	}
+
+	// Get our connection, which must be to the database the credentials were
+	// issued in
+	db, err := b.DB(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIssuingDatabase(req.Secret.InternalData, b.DBName()); err != nil {
+		return nil, err
+	}
+
+	// Service accounts outlive their leases, so they are locked rather than
+	// dropped, and only by the lease holding their current password
+	if generationRaw, ok := req.Secret.InternalData["service_account"]; ok {
+		if generation, _ := generationRaw.(string); generation != "" {
+			roleName, _ := roleNameRaw.(string)
+			var killSQL string
+			if role == nil || !role.SkipSessionKill {
+				killSQL = role.sessionKillSQL(revocationConfig)
+			}
+			if err := b.revokeServiceAccount(req.Storage, roleName, username, container, generation, quotedUsername, killSQL); err != nil {
+				return nil, err
+			}
+			b.recordRevocation(req)
+			return resp, nil
+		}
+	}
+
+	// From here on, a failed revocation says how far it got, since the
+	// statements that ran, being DDL, are not rolled back
+	progress := &revocationProgress{Statements: []string{}}
+	defer func() {
+		if retErr != nil {
+			retErr = progress.wrap(retErr)
+			b.logger.Warn("oracle/secretCredsRevoke: revocation failed", "username", username,
+				"sessions_killed", progress.SessionsKilled, "statements", progress.Statements)
+		}
+	}()
+
+	// Statements still running once the revocation timeout has passed are
+	// aborted, so that a stuck revocation doesn't hold up others
+	deadline := newRevocationDeadline(db, revocationConfig.revocationTimeout())
+
+	// Kill the sessions held by the user; they must be killed before the user
+	// can be dropped. Roles can skip this when the backend isn't allowed to
+	// kill sessions and they are cleaned up by other means. Roles dropping
+	// their users once drained leave the sessions to finish after the user is
+	// locked, and only kill those left at the end of the grace period.
+	dropNow, _ := req.Secret.InternalData["drop_now"].(bool)
+	deferred := role != nil && role.DropGracePeriod > 0 && !dropNow
+	var retryPeriod time.Duration
+	killSQL := role.sessionKillSQL(revocationConfig)
+	kill := func() error {
+		return b.killUserSessions(db, revocationConfig, killSQL, username, quotedUsername, container, progress)
+	}
+	killing := (role == nil || !role.SkipSessionKill) && !(deferred && role.DropWhenDrained)
+
+	// Roles can stop the scheduler jobs and parallel query slaves of the user
+	// first, since either keeps it connected and blocks the drop. Slaves are
+	// left to the revocation package, where there is one, along with the
+	// sessions.
+	if role != nil && role.StopJobs {
+		slaveKillSQL := killSQL
+		if !killing || revocationConfig.revocationPackage() != "" {
+			slaveKillSQL = ""
+		}
+		if err := b.stopUserWork(db, slaveKillSQL, username, quotedUsername, container, progress); err != nil {
+			return nil, err
+		}
+	}
+
+	if killing {
+		// A connection user that can't read the sessions view can't kill
+		// sessions either way. The revocation goes ahead without killing
+		// them, and only fails if the user is still connected.
+		if err := kill(); err != nil {
+			if !isSessionAccessError(err) {
+				return nil, err
+			}
+			b.logger.Warn("oracle/secretCredsRevoke: cannot read sessions, skipping session kill", "username", username, "error", err)
+			if resp == nil {
+				resp = &logical.Response{}
+			}
+			resp.AddWarning(fmt.Sprintf(
+				"The sessions of the user were not killed, since they could not be looked up (%s). Grant the connection user SELECT on gv$session, or set session_query_sql in config/revocation to a view it can read.", err))
+		} else {
+			retryPeriod = revocationConfig.dropRetryPeriod()
+		}
+	}
+	if err := deadline.check(); err != nil {
+		return nil, err
+	}
+
+	// Execute the revocation statements within a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+	if err := deadline.watch(tx); err != nil {
+		return nil, err
+	}
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return nil, err
+		}
+		defer restoreContainer()
+	}
+
+	// The user may be gone already: credentials returned again for a retried
+	// idempotency token share their user with the original lease, whose
+	// revocation may have dropped it, and revoke-all drops users ahead of
+	// their leases
+	exists, err := userExists(tx, username, quotedUsername)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := restoreContainer(); err != nil {
+			return nil, err
+		}
+		b.deleteIssuedUser(req.Storage, username, quotedUsername)
+		b.recordRevocation(req)
+		return resp, nil
+	}
+
+	// Roles with a grace period only lock the user for now, leaving it to be
+	// dropped by the periodic function once the period has passed
+	if deferred {
+		lockSQL := lockRevocationSQL
+		if role.identification() != identificationPassword {
+			lockSQL = lockNoPasswordRevocationSQL
+		}
+		query := Query(lockSQL, map[string]string{
+			"name": nameIdentifier,
+		})
+		if err := deadline.execTx(tx, query); err != nil {
+			return nil, err
+		}
+		progress.ran(query)
+		if err := restoreContainer(); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		if err := b.deferDrop(req.Storage, username, req.Secret.InternalData, role.DropGracePeriod, role.DropWhenDrained); err != nil {
+			return nil, err
+		}
+		b.recordRevocation(req)
+		return b.revocationResponse(username, progress, resp), nil
+	}
+
+	// Drop the logon trigger restricting the user to a service, if there is
+	// one. It may already be gone if an earlier attempt at revocation failed
+	// part way through.
+	if target.ServiceTrigger != "" {
+		query := fmt.Sprintf(dropTriggerSQL, target.ServiceTrigger)
+		if err := deadline.execTx(tx, query); err != nil &&
+			!strings.Contains(err.Error(), "ORA-04080") {
+			return nil, err
+		}
+		progress.ran(query)
+	}
+
+	// Drop the job enforcing the expiry of the credentials, if there is one.
+	// It is gone already if it has run.
+	if target.ExpiryJob != "" {
+		query := fmt.Sprintf(dropJobSQL, target.ExpiryJob)
+		if err := deadline.execTx(tx, query); err != nil &&
+			!strings.Contains(err.Error(), "ORA-27475") {
+			return nil, err
+		}
+		progress.ran(query)
+	}
+
+	// Purge the objects the user has dropped into the recycle bin, for roles
+	// that ask for it. They count as objects it owns, failing a DROP USER
+	// without CASCADE, and take up space for as long as a locked user is
+	// kept.
+	if role != nil && role.PurgeRecyclebin {
+		tablespaces, err := recyclebinTablespaces(tx, username, quotedUsername)
+		if err != nil {
+			return nil, err
+		}
+		for _, tablespace := range tablespaces {
+			query := fmt.Sprintf(purgeRecyclebinSQL, quoteIdentifier(tablespace), nameIdentifier)
+			if err := deadline.execTx(tx, query); err != nil {
+				return nil, err
+			}
+			progress.ran(query)
+		}
+	}
+
+	// Keep the tables of users of roles with an archive schema, which are
+	// dropped with the user
+	if role != nil && role.ArchiveSchema != "" {
+		if err := deadline.check(); err != nil {
+			return nil, err
+		}
+		archived, err := archiveTables(tx, username, quotedUsername, role.ArchiveSchema, b.UsernameLength())
+		if err != nil {
+			return nil, err
+		}
+		if len(archived) > 0 {
+			b.logger.Info("oracle/secretCredsRevoke: archived tables", "username", username, "tables", archived)
+		}
+	}
+
+	statements, err := revocationStatements(revocationSQL, target, revocationConfig.revocationPackage())
+	if err != nil {
+		return nil, err
+	}
+
+	// A DROP USER ending the revocation SQL is batched with those of
+	// concurrent revocations, once the rest has been committed. Statements
+	// of revocations with a timeout must run in the watched transaction, and
+	// users dropped through the revocation package are dropped one at a
+	// time.
+	var dropSQL string
+	if deadline == nil && len(statements) > 0 {
+		last := statements[len(statements)-1]
+		if last.Args == nil && anyDropUserRegex.MatchString(last.Query) {
+			dropSQL = last.Query
+		}
+	}
+	for i, statement := range statements {
+		// Dropping a user that owns objects fails with ORA-01922, which
+		// doesn't say which objects, so they are listed instead
+		if statement.CheckOwned {
+			objects, err := ownedObjects(tx, username, quotedUsername)
+			if err != nil {
+				return nil, err
+			}
+			if len(objects) > 0 {
+				return nil, &ownedObjectsError{Username: username, Objects: objects}
+			}
+		}
+		if dropSQL != "" && i == len(statements)-1 {
+			break
+		}
+
+		stmt, err := tx.Prepare(statement.Query)
+		if err != nil {
+			return nil, err
+		}
+		defer stmt.Close()
+
+		if err := b.execRevocation(stmt, deadline, username, retryPeriod, kill, statement.Args...); err != nil {
+			return nil, err
+		}
+		progress.ran(statement.Query)
+	}
+
+	if err := restoreContainer(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if dropSQL != "" {
+		if err := b.dropUser(db, container, dropSQL, username, quotedUsername, retryPeriod, kill); err != nil {
+			return nil, err
+		}
+		progress.ran(dropSQL)
+	}
+
+	b.deleteIssuedUser(req.Storage, username, quotedUsername)
+	b.recordRevocation(req)
+
+	return b.revocationResponse(username, progress, resp), nil
+}
+
+// revocationTarget is the user revoked by a lease, and what was created with
+// it, as recorded in the lease's internal data.
+type revocationTarget struct {
+	Username       string
+	Quoted         bool
+	Container      string
+	ServiceTrigger string
+	ExpiryJob      string
+}
+
+// nameIdentifier returns the username as substituted into SQL.
+func (t *revocationTarget) nameIdentifier() string {
+	if t.Quoted {
+		return quoteIdentifier(t.Username)
+	}
+	return t.Username
+}
+
+// revocationTarget reads the user to revoke from the internal data of a
+// lease, checking what is substituted into SQL.
+func (b *backend) revocationTarget(s logical.Storage, internalData map[string]interface{}) (*revocationTarget, error) {
+	// Get the username from the internal data
+	usernameRaw, ok := internalData["username"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing username internal data")
+	}
+	username, _ := usernameRaw.(string)
+
+	// The container is recorded at issuance, so the user is dropped from the
+	// container it was created in even if the role has changed since, as is
+	// the logon trigger created for roles restricted to a service, and the
+	// scheduler job enforcing the expiry of the credentials
+	container, _ := internalData["container"].(string)
+	serviceTrigger, _ := internalData["service_trigger"].(string)
+	expiryJob, _ := internalData["expiry_job"].(string)
+
+	// Whether the username is quoted is also recorded at issuance. Leases
+	// issued before it was recorded use the role's setting, since dropping a
+	// case-sensitive user by its unquoted name would drop another user, or
+	// none at all.
+	var quotedUsername bool
+	if quotedRaw, ok := internalData["quoted_username"]; ok {
+		quotedUsername, _ = quotedRaw.(bool)
+	} else if roleName, _ := internalData["role"].(string); roleName != "" {
+		role, err := b.Role(s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil {
+			quotedUsername = role.QuotedUsername
+		}
+	}
+
+	// These are all substituted into SQL, so check them before they are used
+	if err := validateUsername(username, quotedUsername); err != nil {
+		return nil, fmt.Errorf("invalid username internal data: %s", err)
+	}
+	for field, name := range map[string]string{
+		"container":       container,
+		"service_trigger": serviceTrigger,
+		"expiry_job":      expiryJob,
+	} {
+		if name != "" && !oracleIdentifierRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid %s internal data: %q", field, name)
+		}
+	}
+
+	return &revocationTarget{
+		Username:       username,
+		Quoted:         quotedUsername,
+		Container:      container,
+		ServiceTrigger: serviceTrigger,
+		ExpiryJob:      expiryJob,
+	}, nil
+}
+
+// revocationStatement is a rendered revocation statement, with the values
+// bound to it.
+type revocationStatement struct {
+	Query string
+	Args  []interface{}
+
+	// CheckOwned is set for a DROP USER without CASCADE, before which the
+	// objects owned by the user are looked up
+	CheckOwned bool
+}
+
+// revocationStatements renders the revocation SQL for the user. Users are
+// dropped through the revocation package, if there is one.
+func revocationStatements(revocationSQL string, target *revocationTarget, pkg string) ([]revocationStatement, error) {
+	var statements []revocationStatement
+	for _, query := range strutil.ParseArbitraryStringSlice(revocationSQL, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		query, err := renderQuery(query, map[string]string{
+			"name": target.nameIdentifier(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		statement := revocationStatement{
+			Query:      query,
+			CheckOwned: dropUserRegex.MatchString(query),
+		}
+		if pkg != "" {
+			if match := anyDropUserRegex.FindStringSubmatch(query); match != nil {
+				cascade := 0
+				if match[1] != "" {
+					cascade = 1
+				}
+				statement.Query = fmt.Sprintf(packageDropUserSQL, pkg)
+				statement.Args = []interface{}{storedUsername(target.Username, target.Quoted), cascade}
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// execRevocation runs a revocation statement. Sessions opened since the
+// user's sessions were killed make dropping it fail with ORA-01940, so while
+// it does, the sessions are killed again and the statement retried with
+// backoff, until the retry period is over or the revocation times out.
+func (b *backend) execRevocation(stmt *sql.Stmt, timeout *revocationDeadline, username string, retryPeriod time.Duration, kill func() error, args ...interface{}) error {
+	deadline := time.Now().Add(retryPeriod)
+	if timeout != nil && timeout.at.Before(deadline) {
+		deadline = timeout.at
+	}
+	delay := dropRetryDelay
+	for {
+		err := timeout.exec(stmt, args...)
+		if err == nil || !strings.Contains(err.Error(), "ORA-01940") || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		b.logger.Warn("oracle/secretCredsRevoke: user is still connected, killing its sessions again", "username", username)
+		if err := kill(); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxDropRetryDelay {
+			delay = maxDropRetryDelay
+		}
+	}
+}
+
+// revocationDeadline aborts a revocation that runs past the revocation
+// timeout. Statements can't be cancelled through the driver, so those still
+// running at the deadline are aborted by killing the session of the
+// revocation's transaction from another connection. A nil deadline never
+// passes.
+type revocationDeadline struct {
+	db      *sql.DB
+	timeout time.Duration
+	at      time.Time
+	killSQL string
+}
+
+func newRevocationDeadline(db *sql.DB, timeout time.Duration) *revocationDeadline {
+	if timeout <= 0 {
+		return nil
+	}
+	return &revocationDeadline{
+		db:      db,
+		timeout: timeout,
+		at:      time.Now().Add(timeout),
+	}
+}
+
+// watch records the session of the transaction, to be killed if a statement
+// is still running at the deadline.
+func (d *revocationDeadline) watch(tx *sql.Tx) error {
+	if d == nil {
+		return nil
+	}
+	var session oracleSession
+	if err := tx.QueryRow(currentSessionSQL).Scan(&session.SID, &session.Serial, &session.Instance); err != nil {
+		return err
+	}
+	d.killSQL = sessionKillStatement(sessionKillSQL, "", session)
+	return nil
+}
+
+// check returns an error if the deadline has passed.
+func (d *revocationDeadline) check() error {
+	if d == nil || time.Now().Before(d.at) {
+		return nil
+	}
+	return fmt.Errorf("revocation did not complete within %s", d.timeout)
+}
+
+// exec runs the statement, aborting it at the deadline.
+func (d *revocationDeadline) exec(stmt *sql.Stmt, args ...interface{}) error {
+	if d == nil {
+		_, err := stmt.Exec(args...)
+		return err
+	}
+	if err := d.check(); err != nil {
+		return err
+	}
+	err := execWithTimeout(d.db, stmt, args, time.Until(d.at), d.killSQL)
+	if err != nil && !time.Now().Before(d.at) {
+		return fmt.Errorf("revocation did not complete within %s (%s)", d.timeout, err)
+	}
+	return err
+}
+
+// execTx runs the query in the transaction, aborting it at the deadline.
+func (d *revocationDeadline) execTx(tx *sql.Tx, query string) error {
+	if d == nil {
+		_, err := tx.Exec(query)
+		return err
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return d.exec(stmt)
+}
+
+// revocationProgress records how far a revocation has got: the number of
+// the user's sessions killed, and the statements that have run.
+type revocationProgress struct {
+	SessionsKilled int
+	Statements     []string
+}
+
+func (p *revocationProgress) ran(query string) {
+	p.Statements = append(p.Statements, query)
+}
+
+// wrap adds the progress to the error a revocation failed with. The error
+// message starts with the original one, so that it is still recognized as
+// transient.
+func (p *revocationProgress) wrap(err error) error {
+	return &revocationError{Err: err, SessionsKilled: p.SessionsKilled, Statements: p.Statements}
+}
+
+// revocationError is returned by a revocation that failed part way through.
+type revocationError struct {
+	Err            error
+	SessionsKilled int
+	Statements     []string
+}
+
+func (e *revocationError) Error() string {
+	ran := "no statements"
+	if len(e.Statements) > 0 {
+		ran = fmt.Sprintf("%d statements: %s", len(e.Statements), strings.Join(e.Statements, "; "))
+	}
+	return fmt.Sprintf("%s (after killing %d sessions and running %s)", e.Err, e.SessionsKilled, ran)
+}
+
+// revocationResponse logs a completed revocation, and returns its progress
+// in the response.
+func (b *backend) revocationResponse(username string, progress *revocationProgress, resp *logical.Response) *logical.Response {
+	b.logger.Info("oracle/secretCredsRevoke: revoked user", "username", username,
+		"sessions_killed", progress.SessionsKilled, "statements", progress.Statements)
+
+	if resp == nil {
+		resp = &logical.Response{}
+	}
+	resp.Data = map[string]interface{}{
+		"sessions_killed": progress.SessionsKilled,
+		"statements":      progress.Statements,
+	}
+	return resp
+}
+
+// revokeCreds revokes credentials the way their lease would have been
+// revoked, but without any grace period: those that were never leased, such
+// as those waiting in a pool, and users whose grace period has passed.
+func (b *backend) revokeCreds(s logical.Storage, internalData map[string]interface{}) (*logical.Response, error) {
+	data := make(map[string]interface{}, len(internalData)+1)
+	for k, v := range internalData {
+		data[k] = v
+	}
+	data["drop_now"] = true
+
+	return b.secretCredsRevoke(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret: &logical.Secret{
+			InternalData: data,
+		},
+	}, nil)
+}
+
+// checkIssuingDatabase returns an error if the credentials were issued in a
+// different database than the one connected to, as after config/connection
+// was pointed at another database. Their user wouldn't be found there, and
+// revocation would succeed while leaving it behind in the database it was
+// created in. Credentials issued before the database was recorded, or while
+// its name couldn't be determined, are revoked wherever the connection is.
+func checkIssuingDatabase(internalData map[string]interface{}, dbName string) error {
+	issued, _ := internalData["db_name"].(string)
+	if issued == "" || dbName == "" || strings.EqualFold(issued, dbName) {
+		return nil
+	}
+	return fmt.Errorf("credentials were issued in database %s, but the connection is to %s; revoke them once config/connection points to %s", issued, dbName, issued)
+}
+
+// logConfigDrift logs when credentials are renewed or revoked under a
+// different role or connection configuration than they were issued with,
+// since their revocation may then not undo what their creation did.
+// Credentials issued before the configuration was recorded are skipped.
+func (b *backend) logConfigDrift(req *logical.Request, op string, role *roleEntry) {
+	issuedRaw, ok := req.Secret.InternalData["config_fingerprint"]
+	if !ok {
+		return
+	}
+	issued, _ := issuedRaw.(string)
+
+	connConfig, err := b.ConnectionConfig(req.Storage)
+	if err != nil {
+		b.logger.Warn("oracle/"+op+": could not read connection configuration", "error", err)
+		return
+	}
+	current, err := configFingerprint(role, connConfig)
+	if err != nil {
+		b.logger.Warn("oracle/"+op+": could not fingerprint configuration", "error", err)
+		return
+	}
+	if current == issued {
+		return
+	}
+
+	issuedAt, _ := req.Secret.InternalData["issued_at"].(string)
+	b.logger.Warn("oracle/"+op+": configuration has changed since the credentials were issued",
+		"username", req.Secret.InternalData["username"], "role", req.Secret.InternalData["role"], "issued_at", issuedAt)
+}
+
+// recordRevocation marks the issuance of the credentials revoked in the
+// tracking table it was recorded in, if any. The table is taken from the
+// lease, so that it is updated even if the configuration has changed since.
+// The user is gone by now, so failing the revocation would only have it
+// retried against a user that no longer exists; failures are logged instead.
+func (b *backend) recordRevocation(req *logical.Request) {
+	var table, id string
+	if tableRaw, ok := req.Secret.InternalData["tracking_table"]; ok {
+		table, _ = tableRaw.(string)
+	}
+	if idRaw, ok := req.Secret.InternalData["tracking_id"]; ok {
+		id, _ = idRaw.(string)
+	}
+	if table == "" || id == "" {
+		return
+	}
+	if !validQualifiedName(table) {
+		b.logger.Warn("oracle/secretCredsRevoke: invalid tracking table in internal data", "table", table)
+		return
+	}
+
+	db, err := b.DB(req.Storage)
+	if err == nil {
+		_, err = db.Exec(fmt.Sprintf(trackingRevokeSQL, table), id)
+	}
+	if err != nil {
+		b.logger.Warn("oracle/secretCredsRevoke: failed to record revocation in tracking table", "table", table, "issuance_id", id, "error", err)
+	}
+}
+
+// killUserSessions kills the sessions of a user, through the revocation
+// package if one is configured, adding to the progress of the revocation if
+// there is one. Sessions killed through the package aren't counted, so the
+// call is recorded instead.
+func (b *backend) killUserSessions(db *sql.DB, config *revocationConfig, killSQL, username string, quoted bool, container string, progress *revocationProgress) error {
+	pkg := config.revocationPackage()
+	if pkg == "" {
+		killed, err := b.killSessions(db, config.sessionQuerySQL(), killSQL, username, quoted, container)
+		if progress != nil {
+			progress.SessionsKilled += killed
+		}
+		return err
+	}
+
+	postTransaction := 0
+	if killSQL == sessionDisconnectSQL {
+		postTransaction = 1
+	}
+	query := fmt.Sprintf(packageKillSessionsSQL, pkg)
+	if _, err := db.Exec(query, storedUsername(username, quoted), container, postTransaction); err != nil {
+		return fmt.Errorf("could not kill sessions through %s: %s", pkg, err)
+	}
+	if progress != nil {
+		progress.ran(query)
+	}
+	return nil
+}
+
+// stopUserWork stops what the user has running apart from its own sessions,
+// which would keep it connected, or connect it again, once they are killed.
+// The scheduler jobs it owns are disabled, and stopped if they are running.
+// Unless killSQL is empty, the parallel query slaves working for its
+// sessions are then killed with it, ahead of their query coordinators.
+// ORA-27475 and ORA-27366 are returned for jobs that were dropped, or that
+// stopped running, since they were looked up.
+func (b *backend) stopUserWork(db *sql.DB, killSQL, username string, quoted bool, container string, progress *revocationProgress) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	jobs, running, err := userJobs(tx, username, quoted)
+	if err != nil {
+		return err
+	}
+	owner := quoteIdentifier(storedUsername(username, quoted))
+	for _, job := range jobs {
+		name := strings.Replace(owner+"."+quoteIdentifier(job), "'", "''", -1)
+		queries := []string{fmt.Sprintf(disableJobSQL, name)}
+		if running[job] {
+			queries = append(queries, fmt.Sprintf(stopJobSQL, name))
+		}
+		for _, query := range queries {
+			if _, err := tx.Exec(query); err != nil &&
+				!strings.Contains(err.Error(), "ORA-27475") &&
+				!strings.Contains(err.Error(), "ORA-27366") {
+				return fmt.Errorf("could not stop scheduler job %s: %s", job, err)
+			}
+			progress.ran(query)
+		}
+	}
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if killSQL == "" {
+		return nil
+	}
+	stored := storedUsername(username, quoted)
+	sessions, err := querySessions(db, pxSlaveSessionQuerySQL, []string{stored}, container)
+	if err != nil {
+		// The sessions are looked up again to be killed, which warns about
+		// views the connection user can't read
+		if isSessionAccessError(err) {
+			b.logger.Warn("oracle/secretCredsRevoke: cannot read parallel query sessions, skipping", "username", username, "error", err)
+			return nil
+		}
+		return err
+	}
+	if len(sessions[stored]) == 0 {
+		return nil
+	}
+	killStmts := make([]string, len(sessions[stored]))
+	for i, session := range sessions[stored] {
+		killStmts[i] = sessionKillStatement(killSQL, stored, session)
+	}
+	if _, err := db.Exec(killSessionsBlock(killStmts)); err != nil {
+		return fmt.Errorf("could not kill parallel query slaves: %s", err)
+	}
+	progress.SessionsKilled += len(killStmts)
+	return nil
+}
@@ -0,0 +1,163 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const SecretProxyCredsType = "proxy_creds"
+
+func secretProxyCreds(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: SecretProxyCredsType,
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username of the proxy user",
+			},
+
+			"password": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Password",
+			},
+
+			"target_schema": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Schema the proxy user connects through",
+			},
+
+			"proxy_user": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Username to connect with, naming the target schema in brackets",
+			},
+
+			"expiration": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Time the credentials expire, unless renewed",
+			},
+		},
+
+		Renew:  b.secretProxyCredsRenew,
+		Revoke: b.secretProxyCredsRevoke,
+	}
+}
+
+func (b *backend) secretProxyCredsRenew(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	lease, err := b.Lease(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		lease = &configLease{}
+	}
+
+	ttl, _ := b.leaseTTL(lease)
+	f := framework.LeaseExtend(ttl, lease.LeaseMax, b.System())
+	return f(req, d)
+}
+
+// secretProxyCredsRevoke undoes what issuing the proxy credentials did, in
+// reverse: the proxy user's permission to connect through the target schema
+// is revoked, so that no more sessions are made through it, the sessions
+// already made through it are killed, and the proxy user is dropped.
+// Leases issued before the target schema was recorded only drop the user.
+func (b *backend) secretProxyCredsRevoke(
+	req *logical.Request, d *framework.FieldData) (resp *logical.Response, retErr error) {
+	usernameRaw, ok := req.Secret.InternalData["username"]
+	if !ok {
+		return nil, fmt.Errorf("secret is missing username internal data")
+	}
+	username, _ := usernameRaw.(string)
+	targetSchema, _ := req.Secret.InternalData["target_schema"].(string)
+
+	// These are substituted into SQL, so check them before they are used
+	if err := validateUsername(username, false); err != nil {
+		return nil, fmt.Errorf("invalid username internal data: %s", err)
+	}
+	if targetSchema != "" && !oracleIdentifierRegex.MatchString(targetSchema) {
+		return nil, fmt.Errorf("invalid target_schema internal data: %q", targetSchema)
+	}
+
+	progress := &revocationProgress{Statements: []string{}}
+	defer func() {
+		if retErr != nil {
+			retErr = progress.wrap(retErr)
+			b.logger.Warn("oracle/secretProxyCredsRevoke: revocation failed", "username", username,
+				"sessions_killed", progress.SessionsKilled, "statements", progress.Statements)
+		}
+	}()
+
+	revocationConfig, err := b.RevocationConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetSchema != "" {
+		db, err := b.DB(req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		// The permission goes with the proxy user, but is revoked first so
+		// that no sessions are made through it while the others are killed.
+		// ORA-01918 is returned once either user is gone.
+		query := fmt.Sprintf(proxyRevokeThroughSQL, targetSchema, username)
+		if _, err := db.Exec(query); err != nil && !isNotExistError(err) {
+			return nil, err
+		}
+		progress.ran(query)
+
+		killed, err := b.killProxiedSessions(db, revocationConfig.sessionKillSQL(), username)
+		progress.SessionsKilled += killed
+		if err != nil {
+			if !isSessionAccessError(err) {
+				return nil, err
+			}
+			b.logger.Warn("oracle/secretProxyCredsRevoke: cannot read proxied sessions, skipping session kill", "username", username, "error", err)
+			resp = &logical.Response{}
+			resp.AddWarning(fmt.Sprintf(
+				"The sessions made through the proxy user were not killed, since they could not be looked up (%s). Grant the connection user SELECT on gv$session and AUDIT_VIEWER.", err))
+		}
+	}
+
+	if err := b.dropPartialUser(req.Storage, &walUser{
+		Username: username,
+	}); err != nil {
+		return nil, err
+	}
+	if pkg := revocationConfig.revocationPackage(); pkg != "" {
+		progress.ran(fmt.Sprintf(packageDropUserSQL, pkg))
+	} else {
+		progress.ran(fmt.Sprintf(rollbackUserSQL, username))
+	}
+
+	return b.revocationResponse(username, progress, resp), nil
+}
+
+// killProxiedSessions kills the sessions made through the proxy user with
+// killSQL, returning how many were killed. They run as the target schema,
+// and are told apart from its other sessions by the logons recorded in the
+// unified audit trail, which must audit them.
+func (b *backend) killProxiedSessions(db *sql.DB, killSQL, username string) (int, error) {
+	stored := storedUsername(username, false)
+	sessions, err := querySessions(db, proxySessionQuerySQL, []string{stored}, "")
+	if err != nil {
+		return 0, err
+	}
+	if len(sessions[stored]) == 0 {
+		return 0, nil
+	}
+
+	killStmts := make([]string, len(sessions[stored]))
+	for i, session := range sessions[stored] {
+		killStmts[i] = sessionKillStatement(killSQL, stored, session)
+	}
+	if _, err := db.Exec(killSessionsBlock(killStmts)); err != nil {
+		return 0, err
+	}
+	return len(killStmts), nil
+}
@@ -0,0 +1,157 @@
+package oracle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// serviceAccount records the latest issuance of credentials for a service
+// account. Only the lease of that issuance holds the current password, so
+// only its revocation locks the account.
+type serviceAccount struct {
+	Generation string    `json:"generation" mapstructure:"generation" structs:"generation"`
+	LastIssued time.Time `json:"last_issued" mapstructure:"last_issued" structs:"last_issued"`
+}
+
+func serviceAccountKey(roleName, username string) string {
+	return "service-account/" + roleName + "/" + username
+}
+
+// lockServiceAccount locks the service account for issuance or revocation.
+// The returned function unlocks it.
+func (b *backend) lockServiceAccount(roleName, username string) func() {
+	lock := locksutil.LockForKey(b.accountLocks, serviceAccountKey(roleName, username))
+	lock.Lock()
+	return lock.Unlock
+}
+
+func (b *backend) serviceAccount(s logical.Storage, roleName, username string) (*serviceAccount, error) {
+	entry, err := s.Get(serviceAccountKey(roleName, username))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result serviceAccount
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) putServiceAccount(s logical.Storage, roleName, username string, account *serviceAccount) error {
+	entry, err := logical.StorageEntryJSON(serviceAccountKey(roleName, username), account)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// serviceAccountUsername returns the username of the role's service account
+// for a requester, made of the role name and the requester's display name.
+// Usernames that are too long are shortened and end in a hash of both, so
+// that they stay distinct.
+func serviceAccountUsername(roleName, displayName string, role *roleEntry, config *usernameConfig, length int) (string, []string, error) {
+	separator := config.separator()
+	username := sanitizeDisplayName(roleName) + separator + sanitizeDisplayName(displayName)
+	var warnings []string
+	if len(username) > length {
+		sum := sha256.Sum256([]byte(roleName + "\x00" + displayName))
+		suffix := separator + hex.EncodeToString(sum[:])[:identityLength]
+		if length <= len(suffix) {
+			return "", nil, fmt.Errorf("username length %d is too short for service accounts", length)
+		}
+		username = username[:length-len(suffix)] + suffix
+		warnings = append(warnings, fmt.Sprintf(
+			"service account username was shortened to the username length of %d", length))
+	}
+
+	switch role.usernameCase() {
+	case usernameCaseUpper:
+		username = strings.ToUpper(username)
+	case usernameCaseLower:
+		username = strings.ToLower(username)
+	}
+	if !role.QuotedUsername && !oracleIdentifierRegex.MatchString(username) {
+		return "", nil, fmt.Errorf("service account username %q is not a valid identifier", username)
+	}
+
+	return username, warnings, nil
+}
+
+// revokeServiceAccount locks a service account when the lease holding its
+// current password is revoked. Leases from earlier issuances are revoked
+// without touching the account, since their passwords have already been
+// replaced. Its sessions are ended with killSQL, unless it is empty.
+func (b *backend) revokeServiceAccount(s logical.Storage, roleName, username, container, generation string, quoted bool, killSQL string) error {
+	defer b.lockServiceAccount(roleName, username)()
+
+	account, err := b.serviceAccount(s, roleName, username)
+	if err != nil {
+		return err
+	}
+	if account == nil || account.Generation != generation {
+		return nil
+	}
+
+	nameIdentifier := username
+	if quoted {
+		nameIdentifier = quoteIdentifier(username)
+	}
+
+	db, err := b.DB(s)
+	if err != nil {
+		return err
+	}
+
+	if killSQL != "" {
+		revocationConfig, err := b.RevocationConfig(s)
+		if err != nil {
+			return err
+		}
+		if err := b.killUserSessions(db, revocationConfig, killSQL, username, quoted, container, nil); err != nil {
+			if !isSessionAccessError(err) {
+				return err
+			}
+			b.logger.Warn("oracle/revokeServiceAccount: cannot read sessions, skipping session kill", "username", username, "error", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	restoreContainer := func() error { return nil }
+	if container != "" {
+		restoreContainer, err = switchContainer(tx, container)
+		if err != nil {
+			return err
+		}
+		defer restoreContainer()
+	}
+
+	if _, err := tx.Exec(Query(lockRevocationSQL, map[string]string{
+		"name": nameIdentifier,
+	})); err != nil {
+		return err
+	}
+
+	if err := restoreContainer(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.Delete(serviceAccountKey(roleName, username))
+}
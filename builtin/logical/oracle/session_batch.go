@@ -0,0 +1,168 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxSessionBatchSize keeps the usernames of a lookup within the 1000
+// expressions Oracle allows in an IN list.
+const maxSessionBatchSize = 500
+
+// oracleSession identifies a session in gv$session. Server is the kind of
+// server process serving it, if the session query returns it.
+type oracleSession struct {
+	SID      int
+	Serial   int
+	Instance int
+	Server   string
+}
+
+// shared returns whether the session is connected through a dispatcher to a
+// shared server. Shared server sessions that are idle between calls have no
+// server, and are listed as NONE.
+func (s oracleSession) shared() bool {
+	return s.Server == "SHARED" || s.Server == "NONE"
+}
+
+// lookupSessions returns the sessions of the user, as stored in gv$session,
+// in the container. The lookup is batched with those of concurrent
+// revocations using the same query in the same container, so that when many
+// leases expire at once, such as when the mount is disabled, gv$session is
+// queried once for many users rather than once for each.
+func (l *batcher) lookupSessions(querySQL, container, username string,
+	query func(usernames []string) (map[string][]oracleSession, error)) ([]oracleSession, error) {
+	result, err := l.add(querySQL+"\x00"+container, username, maxSessionBatchSize,
+		func(usernames []string) (interface{}, error) {
+			return query(usernames)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string][]oracleSession)[username], nil
+}
+
+// sessionQuery returns the query finding the sessions of users, as stored in
+// gv$session, created in the given container, and the values bound to it.
+// querySQL lists the sessions of the users bound in place of '{{usernames}}'.
+// Users created in a PDB are local to it, so only the sessions of that PDB
+// are theirs. Users created without a container live in the database
+// connected to: when that is the root of a CDB they are common users, whose
+// sessions in every container are found.
+func sessionQuery(querySQL string, usernames []string, container string) (string, []interface{}) {
+	binds := make([]string, len(usernames))
+	args := make([]interface{}, 0, len(usernames)+1)
+	for i, username := range usernames {
+		binds[i] = fmt.Sprintf(":%d", i+1)
+		args = append(args, username)
+	}
+	query := Query(querySQL, map[string]string{
+		"usernames": strings.Join(binds, ", "),
+	})
+	if container != "" {
+		query += fmt.Sprintf(containerSessionSQL, len(usernames)+1)
+		args = append(args, container)
+	}
+	return query, args
+}
+
+// querySessions looks up the sessions of users, as stored in gv$session, in
+// the container, by username. Session queries configured before the server
+// column was queried return only four columns, leaving it empty.
+func querySessions(db *sql.DB, querySQL string, usernames []string, container string) (map[string][]oracleSession, error) {
+	query, args := sessionQuery(querySQL, usernames, container)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, &sessionLookupError{Err: err}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string][]oracleSession)
+	for rows.Next() {
+		var session oracleSession
+		var username string
+		dest := []interface{}{&session.SID, &session.Serial, &session.Instance, &username}
+		if len(columns) > 4 {
+			dest = append(dest, &session.Server)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		sessions[username] = append(sessions[username], session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &sessionLookupError{Err: err}
+	}
+	return sessions, nil
+}
+
+// sessionLookupError is returned when the sessions of users can't be looked
+// up.
+type sessionLookupError struct {
+	Err error
+}
+
+func (e *sessionLookupError) Error() string {
+	return fmt.Sprintf("could not list sessions for user: %s", e.Err)
+}
+
+// isSessionAccessError returns whether the error is the session query
+// failing because the connection user can't read the view it queries:
+// ORA-00942 is returned for views it has no SELECT on, and ORA-01031 for
+// those it isn't privileged enough to read.
+func isSessionAccessError(err error) bool {
+	lookupErr, ok := err.(*sessionLookupError)
+	if !ok {
+		return false
+	}
+	msg := lookupErr.Err.Error()
+	return strings.Contains(msg, "ORA-00942") || strings.Contains(msg, "ORA-01031")
+}
+
+// killSessionsBlock returns a PL/SQL block running the kill statements in a
+// single round trip. A failing statement doesn't stop the others, so that
+// as much access as possible is removed, and the first failure is raised
+// once they have all run. Sessions that have ended since they were looked
+// up, or were already marked for kill, such as those still finishing their
+// transaction after an earlier disconnect, are skipped.
+func killSessionsBlock(killStmts []string) string {
+	calls := make([]string, len(killStmts))
+	for i, stmt := range killStmts {
+		calls[i] = fmt.Sprintf("  kill('%s');", quoteLiteral(stmt))
+	}
+	return fmt.Sprintf(killSessionsBlockSQL, strings.Join(calls, "\n"))
+}
+
+// killSessions ends the sessions held by the user in the container, found
+// with querySQL, with killSQL, returning how many were killed. This isn't
+// done in a transaction because even if we fail along the way, we want to
+// remove as much access as possible. The lookup is batched with those of
+// concurrent revocations.
+func (b *backend) killSessions(db *sql.DB, querySQL, killSQL, username string, quoted bool, container string) (int, error) {
+	stored := storedUsername(username, quoted)
+	sessions, err := b.sessionBatches.lookupSessions(querySQL, container, stored,
+		func(usernames []string) (map[string][]oracleSession, error) {
+			return querySessions(db, querySQL, usernames, container)
+		})
+	if err != nil {
+		return 0, err
+	}
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	killStmts := make([]string, len(sessions))
+	for i, session := range sessions {
+		killStmts[i] = sessionKillStatement(killSQL, stored, session)
+	}
+	if _, err := db.Exec(killSessionsBlock(killStmts)); err != nil {
+		return 0, err
+	}
+	return len(killStmts), nil
+}
@@ -0,0 +1,242 @@
+package oracle
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// minStaticRotationPeriod is the shortest rotation period of static roles.
+// Rotations are run by the periodic function, which runs about once a
+// minute, so shorter periods would not be kept to.
+const minStaticRotationPeriod = time.Minute
+
+// minStaticRotationWindow is the shortest rotation window of static roles,
+// leaving the periodic function a few runs to rotate within it.
+const minStaticRotationWindow = 5 * time.Minute
+
+// staticRotationRetryBase and staticRotationRetryMax bound the backoff of
+// failed rotations, which doubles from the base with each failure in a row
+// up to the maximum.
+const (
+	staticRotationRetryBase = time.Minute
+	staticRotationRetryMax  = time.Hour
+)
+
+// staticRotationBackoff returns how long to wait before retrying a rotation
+// that has failed the given number of times in a row.
+func staticRotationBackoff(failures int) time.Duration {
+	backoff := staticRotationRetryBase
+	for i := 1; i < failures && backoff < staticRotationRetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > staticRotationRetryMax {
+		backoff = staticRotationRetryMax
+	}
+	return backoff
+}
+
+// staticRotation is a static role in the rotation queue.
+type staticRotation struct {
+	name  string
+	at    time.Time
+	index int
+}
+
+// staticRotationHeap implements heap.Interface, ordering static roles by the
+// time they are next due to be rotated.
+type staticRotationHeap []*staticRotation
+
+func (h staticRotationHeap) Len() int           { return len(h) }
+func (h staticRotationHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h staticRotationHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *staticRotationHeap) Push(x interface{}) {
+	item := x.(*staticRotation)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *staticRotationHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// staticRotationQueue holds the static roles rotated on a schedule, ordered
+// by when they are next due. It is loaded from the next rotation times
+// stored with the roles, so that the schedule survives restarts, and is
+// reloaded whenever it may have gone stale.
+type staticRotationQueue struct {
+	lock   sync.Mutex
+	loaded bool
+	heap   staticRotationHeap
+	byName map[string]*staticRotation
+}
+
+// load fills the queue from storage, unless it is loaded already.
+func (q *staticRotationQueue) load(b *backend, s logical.Storage) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.loaded {
+		return nil
+	}
+	names, err := s.List("static-role/")
+	if err != nil {
+		return err
+	}
+	q.heap = nil
+	q.byName = make(map[string]*staticRotation)
+	for _, name := range names {
+		role, err := b.StaticRole(s, name)
+		if err != nil {
+			return err
+		}
+		if role != nil && !role.NextVaultRotation.IsZero() {
+			q.set(name, role.dueAt())
+		}
+	}
+	q.loaded = true
+	return nil
+}
+
+// invalidate has the queue reloaded from storage before it is next used.
+func (q *staticRotationQueue) invalidate() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.loaded = false
+}
+
+// push schedules the rotation of the static role, replacing any rotation
+// already scheduled for it.
+func (q *staticRotationQueue) push(name string, at time.Time) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.loaded {
+		q.set(name, at)
+	}
+}
+
+// schedule queues the next rotation of the static role, or takes it out of
+// the queue if it isn't rotated on a schedule.
+func (q *staticRotationQueue) schedule(name string, role *staticRoleEntry) {
+	if role.NextVaultRotation.IsZero() {
+		q.remove(name)
+		return
+	}
+	q.push(name, role.dueAt())
+}
+
+// remove takes the static role out of the queue.
+func (q *staticRotationQueue) remove(name string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if item, ok := q.byName[name]; ok {
+		heap.Remove(&q.heap, item.index)
+		delete(q.byName, name)
+	}
+}
+
+// popDue takes the static roles due to be rotated by the given time out of
+// the queue, in the order they became due.
+func (q *staticRotationQueue) popDue(now time.Time) []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var names []string
+	for len(q.heap) > 0 && !q.heap[0].at.After(now) {
+		item := heap.Pop(&q.heap).(*staticRotation)
+		delete(q.byName, item.name)
+		names = append(names, item.name)
+	}
+	return names
+}
+
+func (q *staticRotationQueue) set(name string, at time.Time) {
+	if item, ok := q.byName[name]; ok {
+		item.at = at
+		heap.Fix(&q.heap, item.index)
+		return
+	}
+	item := &staticRotation{name: name, at: at}
+	heap.Push(&q.heap, item)
+	q.byName[name] = item
+}
+
+// rotateDueStaticRoles rotates the passwords of the static roles that are
+// due to be rotated. A failed rotation is logged and recorded with the role,
+// and retried with exponential backoff, while the role keeps serving the
+// password it has.
+func (b *backend) rotateDueStaticRoles(s logical.Storage) error {
+	if err := b.staticRotations.load(b, s); err != nil {
+		return err
+	}
+
+	for _, name := range b.staticRotations.popDue(time.Now()) {
+		if err := b.rotateScheduledStaticRole(s, name); err != nil {
+			b.logger.Warn("oracle/rotateDueStaticRoles: rotation failed", "role", name, "error", err)
+		}
+	}
+	return nil
+}
+
+func (b *backend) rotateScheduledStaticRole(s logical.Storage, name string) error {
+	unlock := b.lockStaticRole(name)
+	defer unlock()
+
+	// The role may have been changed or deleted since it was queued
+	role, err := b.StaticRole(s, name)
+	if err != nil {
+		b.staticRotations.invalidate()
+		return err
+	}
+	if role == nil || role.NextVaultRotation.IsZero() {
+		return nil
+	}
+	now := time.Now()
+	if due := role.dueAt(); due.After(now) {
+		b.staticRotations.push(name, due)
+		return nil
+	}
+
+	// A rotation that missed its window waits for the next scheduled time
+	if role.RotationWindow > 0 && now.After(role.NextVaultRotation.Add(role.RotationWindow)) {
+		missed := role.NextVaultRotation
+		role.NextVaultRotation = role.nextRotation(now)
+		role.NextRotationRetry = time.Time{}
+		if err := b.putStaticRole(s, name, role); err != nil {
+			b.staticRotations.invalidate()
+			return err
+		}
+		b.staticRotations.schedule(name, role)
+		b.logger.Warn("oracle/rotateDueStaticRoles: rotation window missed, waiting for the next one",
+			"role", name, "missed", missed, "next", role.NextVaultRotation)
+		return nil
+	}
+
+	if err := b.rotateStaticRole(s, name, role); err != nil {
+		// The failure is recorded so that it shows on the role, and survives
+		// restarts along with the backoff
+		role.RotationFailures++
+		role.LastRotationError = err.Error()
+		role.NextRotationRetry = now.Add(staticRotationBackoff(role.RotationFailures)).UTC()
+		if putErr := b.putStaticRole(s, name, role); putErr != nil {
+			b.logger.Warn("oracle/rotateDueStaticRoles: could not record rotation failure", "role", name, "error", putErr)
+		}
+		b.staticRotations.push(name, role.dueAt())
+		return err
+	}
+	return nil
+}
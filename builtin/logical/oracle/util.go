@@ -0,0 +1,1363 @@
+package oracle
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/strutil"
+)
+
+const (
+	// oracleDriverName is the database/sql driver used to talk to Oracle. It
+	// is registered by github.com/mattn/go-oci8, which is only linked into
+	// builds using the oci8 build tag since it requires cgo and the Oracle
+	// Instant Client.
+	oracleDriverName = "oci8"
+
+	// Oracle limits identifiers and passwords to 30 bytes prior to 12.2
+	oracleUsernameLength    = 30
+	oracleDisplayNameLength = 10
+	oraclePasswordLength    = 30
+
+	// From 12.2, identifiers may be up to 128 bytes
+	oracleLongUsernameLength = 128
+
+	// identityLength is the number of characters of the requester's token
+	// accessor included in usernames
+	identityLength = 8
+
+	// randomUsernamePrefix starts random usernames, which would otherwise
+	// start with a digit half of the time
+	randomUsernamePrefix = "V"
+
+	// maxUsernameHintLength is the number of characters of a username hint
+	// included in usernames
+	maxUsernameHintLength = 8
+
+	// defaultTTL is the lease duration of credentials when config/lease
+	// sets neither a lease nor a default TTL
+	defaultTTL = time.Hour
+
+	// idempotencyWindow is how long credentials issued for an idempotency
+	// token are returned again for the same token
+	idempotencyWindow = 10 * time.Minute
+
+	// maxIdempotencyTokenLength is the longest idempotency token accepted
+	maxIdempotencyTokenLength = 256
+
+	// maxReasonLength is the longest reason accepted when issuing
+	// credentials, so that it fits in a VARCHAR2 column
+	maxReasonLength = 4000
+
+	// maxUsernameAttempts is the number of usernames generated before giving
+	// up when they collide with existing users
+	maxUsernameAttempts = 3
+
+	// maxVariableLength is the longest value accepted for a variable
+	// supplied when issuing credentials
+	maxVariableLength = 4000
+
+	// maxCreateAttempts is the number of times credential creation is
+	// attempted when it fails with transient errors
+	maxCreateAttempts = 3
+
+	// createRetryDelay is how long to wait before retrying credential
+	// creation, multiplied by the number of attempts so far
+	createRetryDelay = 500 * time.Millisecond
+
+	// defaultDropRetryPeriod is how long a user that is still connected is
+	// retried for on revocation, unless configured in config/revocation
+	defaultDropRetryPeriod = 10 * time.Second
+
+	// dropRetryDelay is how long to wait before the first retry of a user
+	// that is still connected, doubled for each retry up to maxDropRetryDelay
+	dropRetryDelay    = 250 * time.Millisecond
+	maxDropRetryDelay = 2 * time.Second
+
+	// maxPasswordAttempts is the number of passwords tried for a user when
+	// the database's password verify function rejects them
+	maxPasswordAttempts = 3
+
+	// passwordVerifierSaltLength and passwordVerifierIterations are the salt
+	// length and PBKDF2 iterations of Oracle 12c password verifiers
+	passwordVerifierSaltLength = 16
+	passwordVerifierIterations = 4096
+
+	// maxPoolSize is the largest pool of pre-created users a role may have
+	maxPoolSize = 100
+
+	// maxRoleVersions is the number of previous versions kept for each role
+	maxRoleVersions = 10
+)
+
+const (
+	// identificationPassword creates users identified by a generated password
+	identificationPassword = "password"
+
+	// identificationExternal creates users identified externally, e.g. by the
+	// operating system or Kerberos
+	identificationExternal = "external"
+
+	// identificationGlobal creates users identified globally by an enterprise
+	// directory, for Enterprise User Security
+	identificationGlobal = "global"
+)
+
+const (
+	// usernameCasePreserve leaves the case of generated usernames as is
+	usernameCasePreserve = "preserve"
+
+	// usernameCaseUpper upper cases generated usernames
+	usernameCaseUpper = "upper"
+
+	// usernameCaseLower lower cases generated usernames
+	usernameCaseLower = "lower"
+)
+
+const (
+	// executionModeTransaction runs the statements creating a user in a
+	// single transaction, which only undoes DML on failure
+	executionModeTransaction = "transaction"
+
+	// executionModeStatement commits each statement creating a user as it
+	// runs, and reports which one failed
+	executionModeStatement = "statement"
+)
+
+const (
+	// revocationModeDrop drops users on revocation
+	revocationModeDrop = "drop"
+
+	// revocationModeLock locks users on revocation, keeping their schema
+	revocationModeLock = "lock"
+)
+
+const (
+	// sessionTerminationImmediate kills sessions right away, rolling back
+	// their transactions
+	sessionTerminationImmediate = "immediate"
+
+	// sessionTerminationPostTransaction disconnects sessions once their
+	// current transaction ends
+	sessionTerminationPostTransaction = "post_transaction"
+)
+
+const (
+	// passwordModeUUID generates passwords from a truncated UUID
+	passwordModeUUID = "uuid"
+
+	// passwordModeStrong generates mixed case alphanumeric passwords that
+	// also include special characters, for databases with strict password
+	// verify functions
+	passwordModeStrong = "strong"
+)
+
+const (
+	// expiryEnforcementLock locks users, and kills their sessions, once their
+	// lease expires
+	expiryEnforcementLock = "lock"
+
+	// expiryEnforcementDrop drops users once their lease expires
+	expiryEnforcementDrop = "drop"
+)
+
+const (
+	// usernameTruncationDisplayName shortens the display name of usernames
+	// that are too long, before cutting off the end
+	usernameTruncationDisplayName = "display_name"
+
+	// usernameTruncationEnd cuts off the end of usernames that are too long
+	usernameTruncationEnd = "end"
+
+	// usernameTruncationError refuses to issue credentials when the username
+	// is too long
+	usernameTruncationError = "error"
+)
+
+// Characters used in strong passwords. Oracle allows "_", "$" and "#" in
+// addition to alphanumerics without having to quote the password.
+const (
+	passwordUpperChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordLowerChars   = "abcdefghijklmnopqrstuvwxyz"
+	passwordDigitChars   = "0123456789"
+	passwordSpecialChars = "_$#"
+)
+
+// minStrongPasswordLength is the shortest length of strong passwords, which
+// hold two characters of each class
+const minStrongPasswordLength = 8
+
+// sessionQuerySQL finds the sessions of users, given as a list of binds in
+// place of '{{usernames}}', on all instances of a RAC database, so that they
+// can all be killed from the one connected to. Usernames are matched exactly,
+// as stored, rather than with LIKE, so that the sessions of accounts sharing
+// a prefix with them are never killed. They are bound rather than
+// substituted into the query. The server column tells shared server
+// sessions apart, see sessionKillStatement.
+const sessionQuerySQL = `SELECT sid, serial#, inst_id, username, server FROM gv$session WHERE username IN ({{usernames}})`
+
+// containerSessionSQL restricts a session query to the sessions of a
+// pluggable database, given as the bind with the given position. Seen from
+// the root of a CDB, gv$session lists the sessions of every container,
+// including those of local users with the same name in other PDBs.
+const containerSessionSQL = ` AND con_id = (SELECT con_id FROM v$containers WHERE name = UPPER(:%d))`
+
+// killSessionsBlockSQL runs kill statements, given as calls to kill, in a
+// single round trip. ORA-00030 and ORA-00031 are returned for sessions that
+// no longer exist and sessions already marked for kill.
+const killSessionsBlockSQL = `DECLARE
+  failed VARCHAR2(512);
+  PROCEDURE kill(stmt VARCHAR2) IS
+  BEGIN
+    EXECUTE IMMEDIATE stmt;
+  EXCEPTION
+    WHEN OTHERS THEN
+      IF SQLCODE NOT IN (-30, -31) AND failed IS NULL THEN
+        failed := SQLERRM;
+      END IF;
+  END;
+BEGIN
+%s
+  IF failed IS NOT NULL THEN
+    RAISE_APPLICATION_ERROR(-20001, failed);
+  END IF;
+END;`
+
+// dropUsersBlockSQL runs DROP USER statements, given as calls to drop_user,
+// in a single round trip.
+const dropUsersBlockSQL = `DECLARE
+  failed VARCHAR2(512);
+  PROCEDURE drop_user(stmt VARCHAR2) IS
+  BEGIN
+    EXECUTE IMMEDIATE stmt;
+  EXCEPTION
+    WHEN OTHERS THEN
+      IF failed IS NULL THEN
+        failed := SQLERRM;
+      END IF;
+  END;
+BEGIN
+%s
+  IF failed IS NOT NULL THEN
+    RAISE_APPLICATION_ERROR(-20001, failed);
+  END IF;
+END;`
+
+const currentSessionSQL = `SELECT sid, serial#, SYS_CONTEXT('USERENV', 'INSTANCE') FROM v$session WHERE sid = SYS_CONTEXT('USERENV', 'SID')`
+
+// sessionKillSQL kills a session on the given instance, which may not be
+// the one the statement runs on
+const sessionKillSQL = `ALTER SYSTEM KILL SESSION '{{sid}},{{serial}},@{{inst_id}}' IMMEDIATE`
+
+// sharedSessionKillSQL ends a shared server session on the given instance.
+// Killing such a session only marks it as killed, leaving its virtual
+// circuit and dispatcher connection in place until the client next makes a
+// call, while disconnecting it destroys the virtual circuit right away.
+const sharedSessionKillSQL = `ALTER SYSTEM DISCONNECT SESSION '{{sid}},{{serial}},@{{inst_id}}' IMMEDIATE`
+
+// sessionDisconnectSQL disconnects a session on the given instance once its
+// current transaction ends
+const sessionDisconnectSQL = `ALTER SYSTEM DISCONNECT SESSION '{{sid}},{{serial}},@{{inst_id}}' POST_TRANSACTION`
+
+const profileQuerySQL = `SELECT COUNT(*) FROM dba_profiles WHERE profile = '%s'`
+
+const verifyFunctionQuerySQL = `SELECT limit FROM dba_profiles WHERE profile = '%s' AND resource_name = 'PASSWORD_VERIFY_FUNCTION'`
+
+const profileLimitsSQL = `%s PROFILE %s LIMIT PASSWORD_LIFE_TIME %d/86400 IDLE_TIME %d`
+
+// serviceTriggerSQL creates a logon trigger refusing connections by the user
+// made through any service other than the allowed one
+const serviceTriggerSQL = `CREATE TRIGGER %s AFTER LOGON ON {{name}}.SCHEMA
+BEGIN
+  IF UPPER(SYS_CONTEXT('USERENV', 'SERVICE_NAME')) <> UPPER('%s') THEN
+    RAISE_APPLICATION_ERROR(-20001, 'Connections are only allowed through service %s');
+  END IF;
+END;`
+
+const dropTriggerSQL = `DROP TRIGGER %s`
+
+const dbNameQuerySQL = `SELECT SYS_CONTEXT('USERENV', 'DB_NAME') FROM DUAL`
+
+const compatibleQuerySQL = `SELECT value FROM v$parameter WHERE name = 'compatible'`
+
+const userQuerySQL = `SELECT COUNT(*) FROM dba_users WHERE username = '%s'`
+
+const trackingInsertSQL = `INSERT INTO %s (issuance_id, username, role_name, mount_point, issued_at) VALUES (:1, :2, :3, :4, SYSTIMESTAMP)`
+
+const trackingRevokeSQL = `UPDATE %s SET revoked_at = SYSTIMESTAMP WHERE issuance_id = :1 AND revoked_at IS NULL`
+
+const grantedSysPrivsSQL = `SELECT privilege FROM dba_sys_privs WHERE grantee = '%s' ORDER BY privilege`
+
+const grantedRolesSQL = `SELECT granted_role FROM dba_role_privs WHERE grantee = '%s' ORDER BY granted_role`
+
+const grantedObjectPrivsSQL = `SELECT privilege || ' ON ' || owner || '.' || table_name FROM dba_tab_privs WHERE grantee = '%s' ORDER BY owner, table_name, privilege`
+
+// recyclebinTablespacesSQL lists the tablespaces holding objects a user has
+// dropped into the recycle bin
+const recyclebinTablespacesSQL = `SELECT DISTINCT ts_name FROM dba_recyclebin WHERE owner = :1 AND ts_name IS NOT NULL ORDER BY ts_name`
+
+// purgeRecyclebinSQL purges the objects of a user in the recycle bin that
+// are held by a tablespace. PURGE has no form scoped to a user across
+// tablespaces.
+const purgeRecyclebinSQL = `PURGE TABLESPACE %s USER %s`
+
+// userJobsSQL lists the scheduler jobs owned by a user that are enabled or
+// running, and whether each is running
+const userJobsSQL = `SELECT job_name, CASE WHEN state = 'RUNNING' THEN 1 ELSE 0 END FROM dba_scheduler_jobs WHERE owner = :1 AND (enabled = 'TRUE' OR state = 'RUNNING') ORDER BY job_name`
+
+// disableJobSQL disables a scheduler job so that it isn't started again.
+// force disables it whatever depends on it, leaving it running if it is.
+const disableJobSQL = `BEGIN DBMS_SCHEDULER.DISABLE('%s', force => TRUE); END;`
+
+// stopJobSQL stops a running scheduler job. force ends the job slave at
+// once, rather than waiting for it to notice the request.
+const stopJobSQL = `BEGIN DBMS_SCHEDULER.STOP_JOB('%s', force => TRUE); END;`
+
+// pxSlaveSessionQuerySQL looks up the parallel query slaves working for the
+// sessions of users, by the username of their query coordinator. The join
+// is wrapped so that containerSessionSQL can be appended to it.
+const pxSlaveSessionQuerySQL = `SELECT sid, serial#, inst_id, username, server FROM (SELECT s.sid, s.serial#, s.inst_id, qc.username, s.server, qc.con_id FROM gv$px_session px JOIN gv$session s ON s.sid = px.sid AND s.serial# = px.serial# AND s.inst_id = px.inst_id JOIN gv$session qc ON qc.sid = px.qcsid AND qc.inst_id = px.qcinst_id WHERE px.sid <> px.qcsid) WHERE username IN ({{usernames}})`
+
+const ownedObjectsSQL = `SELECT object_type || ' ' || object_name FROM dba_objects WHERE owner = '%s' ORDER BY object_type, object_name`
+
+// ownedTablesSQL lists the tables owned by a user that can be archived,
+// leaving out temporary tables and those that are part of other objects
+const ownedTablesSQL = `SELECT table_name FROM dba_tables WHERE owner = '%s' AND temporary = 'N' AND nested = 'NO' AND secondary = 'N' ORDER BY table_name`
+
+const archiveTableSQL = `CREATE TABLE %s.%s AS SELECT * FROM %s.%s`
+
+const archiveCommentSQL = `COMMENT ON TABLE %s.%s IS '%s'`
+
+// tidyUsersSQL lists the open accounts matching a pattern that are older
+// than the given number of seconds
+const tidyUsersSQL = `SELECT username FROM dba_users WHERE username LIKE '%s' ESCAPE '\' AND account_status NOT LIKE '%%LOCKED%%' AND created < SYSDATE - %d/86400 ORDER BY username`
+
+// maxListedOwnedObjects is the number of objects named in the error for a
+// user that can't be dropped because of the objects it owns
+const maxListedOwnedObjects = 10
+
+const commitSQL = `COMMIT`
+
+const userStatusSQL = `SELECT account_status, TO_CHAR(expiry_date, 'YYYY-MM-DD"T"HH24:MI:SS'), profile FROM dba_users WHERE username = '%s'`
+
+// createSessionQuerySQL counts the grants of CREATE SESSION to a user,
+// whether made directly, to PUBLIC or through roles
+const createSessionQuerySQL = `
+SELECT COUNT(*) FROM dba_sys_privs
+WHERE privilege = 'CREATE SESSION'
+AND grantee IN (
+	SELECT granted_role FROM dba_role_privs
+	START WITH grantee = '%[1]s'
+	CONNECT BY PRIOR granted_role = grantee
+	UNION ALL SELECT '%[1]s' FROM dual
+	UNION ALL SELECT 'PUBLIC' FROM dual
+)`
+
+const containerQuerySQL = `SELECT SYS_CONTEXT('USERENV', 'CON_NAME') FROM DUAL`
+
+const setContainerSQL = `ALTER SESSION SET CONTAINER = %s`
+
+const defaultRevocationSQL = `
+REVOKE CONNECT FROM {{name}};
+DROP USER {{name}};
+`
+
+// proxyCreationSQL creates a proxy user
+const proxyCreationSQL = `CREATE USER {{name}} IDENTIFIED BY "{{password}}"`
+
+// proxyRevokeThroughSQL removes the permission of the proxy user to connect
+// through the target schema
+const proxyRevokeThroughSQL = `ALTER USER %s REVOKE CONNECT THROUGH %s`
+
+// proxySessionQuerySQL finds the sessions made through proxy users, bound in
+// place of '{{usernames}}', and returns them by proxy user. gv$session only
+// shows such sessions as sessions of the target schema, so they are matched
+// with the logons of the proxy users in the unified audit trail, whose
+// sessionid is the session's audsid.
+const proxySessionQuerySQL = `SELECT DISTINCT s.sid, s.serial#, s.inst_id, a.dbproxy_username, s.server FROM gv$session s JOIN unified_audit_trail a ON a.sessionid = s.audsid AND a.instance_id = s.inst_id WHERE a.action_name = 'LOGON' AND a.dbproxy_username IN ({{usernames}})`
+
+// proxyGrantSessionSQL lets a proxy user connect, which it needs in order
+// to connect through the target schema
+const proxyGrantSessionSQL = `GRANT CREATE SESSION TO {{name}}`
+
+// serviceAccountRotateSQL gives an existing service account a new password
+const serviceAccountRotateSQL = `ALTER USER {{name}} IDENTIFIED BY "{{password}}" ACCOUNT UNLOCK`
+
+// serviceAccountRotateValuesSQL gives an existing service account a new
+// password verifier, for roles with identified_by_values
+const serviceAccountRotateValuesSQL = `ALTER USER {{name}} IDENTIFIED BY VALUES '{{password_verifier}}' ACCOUNT UNLOCK`
+
+// staticRotationSQL gives the account of a static role a new password
+const staticRotationSQL = `ALTER USER {{name}} IDENTIFIED BY "{{password}}"`
+
+// lockRevocationSQL locks the user and expires its password instead of
+// dropping it
+const lockRevocationSQL = `ALTER USER {{name}} ACCOUNT LOCK PASSWORD EXPIRE`
+
+// lockNoPasswordRevocationSQL locks users that are not identified by a
+// password
+const lockNoPasswordRevocationSQL = `ALTER USER {{name}} ACCOUNT LOCK`
+
+// cascadeRevocationSQL also drops the objects owned by the user
+const cascadeRevocationSQL = `
+REVOKE CONNECT FROM {{name}};
+DROP USER {{name}} CASCADE;
+`
+
+// expiryJobSQL creates the scheduler job enforcing the expiry of a user's
+// credentials. The job runs once, when the lease expires, and is dropped
+// once it has run.
+const expiryJobSQL = `BEGIN
+  DBMS_SCHEDULER.CREATE_JOB(
+    job_name   => '%s',
+    job_type   => 'PLSQL_BLOCK',
+    job_action => '%s',
+    start_date => SYSTIMESTAMP + NUMTODSINTERVAL(%d, 'SECOND'),
+    enabled    => TRUE,
+    auto_drop  => TRUE);
+END;`
+
+// expiryJobActionSQL locks the user and kills its sessions, disconnecting
+// shared server sessions instead, optionally followed by dropping the user
+const expiryJobActionSQL = `BEGIN
+  EXECUTE IMMEDIATE 'ALTER USER %s ACCOUNT LOCK';
+  FOR s IN (SELECT sid, serial# AS serial, inst_id, server FROM gv$session WHERE username = '%s') LOOP
+    EXECUTE IMMEDIATE 'ALTER SYSTEM ' || CASE WHEN s.server IN ('SHARED', 'NONE') THEN 'DISCONNECT' ELSE 'KILL' END ||
+      ' SESSION ''' || s.sid || ',' || s.serial || ',@' || s.inst_id || ''' IMMEDIATE';
+  END LOOP;%s
+END;`
+
+const expiryJobDropSQL = `
+  EXECUTE IMMEDIATE 'DROP USER %s CASCADE';`
+
+// rescheduleJobSQL moves the expiry job to the new expiry of a renewed lease
+const rescheduleJobSQL = `BEGIN
+  DBMS_SCHEDULER.SET_ATTRIBUTE('%s', 'start_date', SYSTIMESTAMP + NUMTODSINTERVAL(%d, 'SECOND'));
+END;`
+
+const dropJobSQL = `BEGIN DBMS_SCHEDULER.DROP_JOB('%s', TRUE); END;`
+
+// packageKillSessionsSQL kills the sessions of a user through the revocation
+// package, given the username as stored, the container the user lives in, if
+// any, and 1 to disconnect sessions once their transaction is done rather
+// than right away.
+const packageKillSessionsSQL = `BEGIN %s.KILL_SESSIONS(:1, :2, :3); END;`
+
+// packageDropUserSQL drops a user through the revocation package, given the
+// username as stored and 1 to drop it with CASCADE.
+const packageDropUserSQL = `BEGIN %s.DROP_USER(:1, :2); END;`
+
+// rollbackUserSQL drops a user whose creation never completed, along with
+// anything created in its schema
+const rollbackUserSQL = `DROP USER %s CASCADE`
+
+// tnsnamesEntryTemplate is the tnsnames.ora entry for an Easy Connect string
+const tnsnamesEntryTemplate = `%s =
+  (DESCRIPTION =
+    (ADDRESS = (PROTOCOL = %s)(HOST = %s)(PORT = %s))
+    (CONNECT_DATA = (SERVICE_NAME = %s))
+  )
+`
+
+// sqlnetWalletTemplate points clients at the wallet holding the trusted
+// certificates
+const sqlnetWalletTemplate = `WALLET_LOCATION =
+  (SOURCE = (METHOD = FILE)(METHOD_DATA = (DIRECTORY = %s)))
+`
+
+// sepsMkstoreTemplate is the command adding credentials to a Secure External
+// Password Store wallet. mkstore prompts for the password, so that it
+// doesn't appear in the command line.
+const sepsMkstoreTemplate = `mkstore -wrl %s -createCredential %s %s`
+
+// transientErrorCodes are the Oracle errors after which creating
+// credentials is retried: the connection being lost or refused by the
+// listener, the instance starting up or shutting down, and busy resources.
+var transientErrorCodes = []string{
+	"ORA-00054", // resource busy and acquire with NOWAIT specified or timeout expired
+	"ORA-01033", // ORACLE initialization or shutdown in progress
+	"ORA-01089", // immediate shutdown in progress
+	"ORA-03113", // end-of-file on communication channel
+	"ORA-03114", // not connected to ORACLE
+	"ORA-03135", // connection lost contact
+	"ORA-04021", // timeout occurred while waiting to lock object
+	"ORA-12170", // TNS:Connect timeout occurred
+	"ORA-12514", // TNS:listener does not currently know of service
+	"ORA-12516", // TNS:listener could not find available handler
+	"ORA-12519", // TNS:no appropriate service handler found
+	"ORA-12520", // TNS:listener could not find available handler for requested type of server
+	"ORA-12537", // TNS:connection closed
+	"ORA-12541", // TNS:no listener
+	"ORA-12547", // TNS:lost contact
+}
+
+// reservedVariables are the values the backend provides to role SQL, which
+// variables supplied with requests can't replace
+var reservedVariables = []string{"name", "password", "password_verifier", "external_name", "global_dn", "reason"}
+
+var (
+	// templateVariableRegex matches the names of variables that can be
+	// referenced in templates
+	templateVariableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// oracleIdentifierRegex matches unquoted Oracle identifiers such as
+	// tablespace and profile names
+	oracleIdentifierRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*$`)
+
+	// oracleObjectNameRegex matches an object name, optionally qualified by
+	// its schema
+	oracleObjectNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*(\.[A-Za-z][A-Za-z0-9_$#]*)?$`)
+
+	// oracleServiceNameRegex matches a database service name
+	oracleServiceNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.$#-]*$`)
+
+	// oracleSizeRegex matches a size clause, e.g. in a tablespace quota
+	oracleSizeRegex = regexp.MustCompile(`^(?i:UNLIMITED|[0-9]+[KMGTPE]?)$`)
+
+	// dropUserRegex matches a DROP USER statement without CASCADE, which
+	// fails if the user owns any objects
+	dropUserRegex = regexp.MustCompile(`^(?i:DROP\s+USER\s+\S+)$`)
+
+	// anyDropUserRegex matches a DROP USER statement with or without
+	// CASCADE, capturing the CASCADE
+	anyDropUserRegex = regexp.MustCompile(`^(?i:DROP\s+USER\s+\S+(\s+CASCADE)?)$`)
+)
+
+// oracleObjectPrivileges are the object privileges that may be used in a
+// role's object grants.
+var oracleObjectPrivileges = []string{
+	"ALTER",
+	"DEBUG",
+	"DELETE",
+	"EXECUTE",
+	"FLASHBACK",
+	"INDEX",
+	"INSERT",
+	"READ",
+	"REFERENCES",
+	"SELECT",
+	"UPDATE",
+	"WRITE",
+}
+
+// templateFuncs returns the functions available in role SQL templates in
+// addition to the substituted values, e.g. '{{name | upper}}' or
+// '{{timestamp "2006-01-02"}}'.
+func templateFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"replace": func(old, new, s string) string {
+			return strings.Replace(s, old, new, -1)
+		},
+		"truncate": func(n int, s string) string {
+			if n >= 0 && len(s) > n {
+				return s[:n]
+			}
+			return s
+		},
+		"timestamp": func(layout string) string {
+			return now.Format(layout)
+		},
+	}
+}
+
+// renderQuery renders a role SQL template. Each value in data is available
+// as '{{key}}', as with Query, and can be passed to the functions returned by
+// templateFuncs. Unlike Query, referencing an unknown value is an error.
+func renderQuery(tpl string, data map[string]string) (string, error) {
+	funcs := templateFuncs(time.Now().UTC())
+	for k, v := range data {
+		v := v
+		funcs[k] = func() string { return v }
+	}
+
+	t, err := template.New("query").Funcs(funcs).Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// managedProfileName returns the name of the profile maintained for a role,
+// upper casing it and replacing characters not valid in an unquoted
+// identifier.
+func managedProfileName(roleName string) string {
+	name := []byte("VAULT_" + strings.ToUpper(roleName))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > oracleUsernameLength {
+		name = name[:oracleUsernameLength]
+	}
+	return string(name)
+}
+
+// managedProfileSQL returns the statement creating or altering a managed
+// profile so that its password lifetime and idle time match the max TTL.
+// Oracle accepts fractions of days for the password lifetime, while the idle
+// time is in whole minutes.
+func managedProfileSQL(create bool, profile string, maxTTL time.Duration) string {
+	verb := "ALTER"
+	if create {
+		verb = "CREATE"
+	}
+
+	lifetime := int64(maxTTL / time.Second)
+	if lifetime < 1 {
+		lifetime = 1
+	}
+	idle := int64((maxTTL + time.Minute - 1) / time.Minute)
+	if idle < 1 {
+		idle = 1
+	}
+
+	return fmt.Sprintf(profileLimitsSQL, verb, profile, lifetime, idle)
+}
+
+// ensureManagedProfile creates the profile if it doesn't exist, or updates
+// its limits if it does.
+func ensureManagedProfile(tx *sql.Tx, profile string, maxTTL time.Duration) error {
+	var count int
+	if err := tx.QueryRow(fmt.Sprintf(profileQuerySQL, profile)).Scan(&count); err != nil {
+		return fmt.Errorf("could not look up profile %s: %s", profile, err)
+	}
+	if _, err := tx.Exec(managedProfileSQL(count == 0, profile, maxTTL)); err != nil {
+		return fmt.Errorf("could not update profile %s: %s", profile, err)
+	}
+	return nil
+}
+
+// passwordVerifyFunction returns the password verify function of a profile,
+// or an empty string if it has none. Profiles that leave it to the DEFAULT
+// profile use its function.
+func passwordVerifyFunction(tx *sql.Tx, profile string) (string, error) {
+	profile = strings.ToUpper(profile)
+
+	var limit string
+	err := tx.QueryRow(fmt.Sprintf(verifyFunctionQuerySQL, profile)).Scan(&limit)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToUpper(limit) {
+	case "", "NULL":
+		return "", nil
+	case "DEFAULT":
+		if profile == "DEFAULT" {
+			return "", nil
+		}
+		return passwordVerifyFunction(tx, "DEFAULT")
+	}
+	return limit, nil
+}
+
+// renderStatements splits a semicolon-separated SQL string into statements
+// and renders each of them with renderQuery, skipping empty statements.
+func renderStatements(sql string, data map[string]string) ([]string, error) {
+	var stmts []string
+	for _, query := range strutil.ParseArbitraryStringSlice(sql, ";") {
+		query = strings.TrimSpace(query)
+		if len(query) == 0 {
+			continue
+		}
+
+		query, err := renderQuery(query, data)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, query)
+	}
+
+	return stmts, nil
+}
+
+// serviceTriggerName returns the name of the logon trigger restricting the
+// user to a service. It is derived from a hash of the username, since the
+// username itself may already be as long as an identifier can be.
+func serviceTriggerName(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return "VAULT_SVC_" + strings.ToUpper(hex.EncodeToString(sum[:10]))
+}
+
+// serviceTrigger returns the statement creating the logon trigger that
+// restricts the user to the service. The '{{name}}' value is left to be
+// substituted.
+func serviceTrigger(trigger, service string) string {
+	service = quoteLiteral(service)
+	return fmt.Sprintf(serviceTriggerSQL, trigger, service, service)
+}
+
+// expiryJobName returns the name of the scheduler job enforcing the expiry
+// of a user's credentials. Like trigger names, it is derived from the
+// username so that it fits in 30 characters.
+func expiryJobName(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return "VAULT_EXP_" + strings.ToUpper(hex.EncodeToString(sum[:10]))
+}
+
+// expiryJob returns the statement creating the scheduler job that locks, or
+// drops, the user once the TTL has passed. The user's name is given both as
+// substituted into SQL and as it appears in gv$session.
+func expiryJob(job, nameIdentifier, sessionUsername, mode string, ttl time.Duration) string {
+	var drop string
+	if mode == expiryEnforcementDrop {
+		drop = fmt.Sprintf(expiryJobDropSQL, quoteLiteral(nameIdentifier))
+	}
+	action := fmt.Sprintf(expiryJobActionSQL,
+		quoteLiteral(nameIdentifier), quoteLiteral(sessionUsername), drop)
+	return fmt.Sprintf(expiryJobSQL, job, quoteLiteral(action), int64(ttl/time.Second))
+}
+
+// setupStatementsHash returns a hash identifying a role's setup statements.
+func setupStatementsHash(stmts string) string {
+	sum := sha256.Sum256([]byte(stmts))
+	return hex.EncodeToString(sum[:])
+}
+
+// bindPassword rewrites a statement, rendered with the placeholder in place
+// of the password, into an anonymous PL/SQL block that runs it with EXECUTE
+// IMMEDIATE and passes the password as a bind variable. DDL such as CREATE
+// USER doesn't accept bind variables itself, but this keeps the password out
+// of the statement text sent by the client. The bind arguments, one for each
+// use of the password, are returned with the block.
+func bindPassword(stmt, placeholder, password string) (string, []interface{}) {
+	var buf bytes.Buffer
+	var args []interface{}
+
+	buf.WriteString("BEGIN EXECUTE IMMEDIATE '")
+	for i, part := range strings.Split(stmt, placeholder) {
+		if i > 0 {
+			fmt.Fprintf(&buf, "' || :p%d || '", i)
+			args = append(args, password)
+		}
+		buf.WriteString(quoteLiteral(part))
+	}
+	buf.WriteString("'; END;")
+
+	return buf.String(), args
+}
+
+// generatePassword generates a password using the given mode.
+func generatePassword(mode string) (string, error) {
+	switch mode {
+	case passwordModeStrong:
+		return generateStrongPassword(oraclePasswordLength, passwordSpecialChars)
+	default:
+		password, err := uuid.GenerateUUID()
+		if err != nil {
+			return "", err
+		}
+		if len(password) > oraclePasswordLength {
+			password = password[:oraclePasswordLength]
+		}
+		return password, nil
+	}
+}
+
+// passwordVerifier computes the Oracle 12c ("T:") verifier of a password
+// with a random salt, for creating users with IDENTIFIED BY VALUES.
+func passwordVerifier(password string) (string, error) {
+	salt := make([]byte, passwordVerifierSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return passwordVerifierWithSalt(password, salt), nil
+}
+
+// passwordVerifierWithSalt computes the Oracle 12c verifier of a password.
+// The verifier is the SHA-512 hash of a PBKDF2 key derived from the password
+// and salt, followed by the salt, all hex encoded.
+func passwordVerifierWithSalt(password string, salt []byte) string {
+	keySalt := append(append([]byte{}, salt...), "AUTH_PBKDF2_SPEEDY_KEY"...)
+	key := pbkdf2SHA512([]byte(password), keySalt, passwordVerifierIterations, sha512.Size)
+	sum := sha512.Sum512(append(key, salt...))
+	return "T:" + strings.ToUpper(hex.EncodeToString(sum[:])+hex.EncodeToString(salt))
+}
+
+// pbkdf2SHA512 derives a key with PBKDF2 (RFC 2898) using HMAC-SHA-512.
+func pbkdf2SHA512(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha512.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// generateStrongPassword generates a password with at least two upper case
+// letters, lower case letters, digits and special characters each, which
+// satisfies Oracle's strictest bundled verify function. Special characters
+// are taken from special. The password starts with a letter, as Oracle
+// requires of unquoted passwords.
+func generateStrongPassword(length int, special string) (string, error) {
+	classes := []string{passwordUpperChars, passwordLowerChars, passwordDigitChars, special}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		for i := 0; i < 2; i++ {
+			c, err := randomChar(class)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so the required characters aren't always first
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	// Swap a letter to the front if needed. There's always one, since the
+	// password has at least four.
+	if !isLetter(password[0]) {
+		for i, c := range password {
+			if isLetter(c) {
+				password[0], password[i] = password[i], password[0]
+				break
+			}
+		}
+	}
+
+	return string(password), nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// randomChar returns a random character from chars.
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// randomInt returns a uniformly random int in [0, n).
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// quoteLiteral escapes a value for use within a single-quoted SQL string
+// literal.
+func quoteLiteral(value string) string {
+	return strings.Replace(value, "'", "''", -1)
+}
+
+// quoteIdentifier returns the name as a quoted identifier, which Oracle
+// keeps the case of. Double quotes are not valid within quoted identifiers,
+// so any are removed.
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, "", -1) + `"`
+}
+
+// Query templates a query for us.
+func Query(tpl string, data map[string]string) string {
+	for k, v := range data {
+		tpl = strings.Replace(tpl, fmt.Sprintf("{{%s}}", k), v, -1)
+	}
+
+	return tpl
+}
+
+// sessionKillStatement returns the statement ending a session of a user,
+// substituting the session's SID, serial number, instance and server type,
+// and the username as stored, into the kill SQL. Shared server sessions are
+// disconnected rather than killed by the default kill SQL, so that their
+// virtual circuits are freed; custom kill SQL can tell them apart with
+// '{{server}}'.
+func sessionKillStatement(killSQL, username string, session oracleSession) string {
+	if killSQL == sessionKillSQL && session.shared() {
+		killSQL = sharedSessionKillSQL
+	}
+	return Query(killSQL, map[string]string{
+		"sid":     strconv.Itoa(session.SID),
+		"serial":  strconv.Itoa(session.Serial),
+		"inst_id": strconv.Itoa(session.Instance),
+		"server":  quoteLiteral(session.Server),
+		"name":    quoteLiteral(username),
+	})
+}
+
+// detectUsernameLength returns the longest username the database accepts,
+// based on its compatibility setting.
+func detectUsernameLength(db *sql.DB) (int, error) {
+	var compatible string
+	if err := db.QueryRow(compatibleQuerySQL).Scan(&compatible); err != nil {
+		return 0, fmt.Errorf("could not determine database compatibility: %s", err)
+	}
+	return usernameLengthForVersion(compatible), nil
+}
+
+// detectDBName returns the name of the database connected to.
+func detectDBName(db *sql.DB) (string, error) {
+	var name string
+	if err := db.QueryRow(dbNameQuerySQL).Scan(&name); err != nil {
+		return "", fmt.Errorf("could not determine database name: %s", err)
+	}
+	return name, nil
+}
+
+// grantsSummary lists the system privileges, roles and object privileges
+// granted directly to a user. Privileges the user holds through its roles
+// are not expanded.
+func grantsSummary(tx *sql.Tx, username string, quoted bool) (map[string][]string, error) {
+	if !quoted {
+		username = strings.ToUpper(username)
+	}
+
+	summary := make(map[string][]string, 3)
+	for key, query := range map[string]string{
+		"system_privileges": grantedSysPrivsSQL,
+		"roles":             grantedRolesSQL,
+		"object_privileges": grantedObjectPrivsSQL,
+	} {
+		rows, err := tx.Query(fmt.Sprintf(query, quoteLiteral(username)))
+		if err != nil {
+			return nil, err
+		}
+
+		grants := []string{}
+		for rows.Next() {
+			var grant string
+			if err := rows.Scan(&grant); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			grants = append(grants, grant)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		summary[key] = grants
+	}
+
+	return summary, nil
+}
+
+// usernameLengthForVersion returns the longest username accepted by a
+// database with the given compatibility setting, e.g. "12.2.0".
+func usernameLengthForVersion(version string) int {
+	var major, minor int
+	fmt.Sscanf(version, "%d.%d", &major, &minor)
+	if major > 12 || major == 12 && minor >= 2 {
+		return oracleLongUsernameLength
+	}
+	return oracleUsernameLength
+}
+
+// connectString strips the credentials from a connection string of the form
+// "user/password@connect_identifier", returning the connect identifier, e.g.
+// an Easy Connect string such as "host:port/service_name". The password may
+// itself contain "@", so the string is split at the last one.
+func connectString(connURL string) string {
+	if i := strings.LastIndex(connURL, "@"); i >= 0 {
+		return connURL[i+1:]
+	}
+	return connURL
+}
+
+// configFingerprint returns a hash of the configuration credentials are
+// issued under: the role, and the connection without its credentials, so
+// that rotating the password of the connecting user isn't a change. Role
+// fields added by upgrades change it as well.
+func configFingerprint(role *roleEntry, connConfig *connectionConfig) (string, error) {
+	var conn connectionConfig
+	if connConfig != nil {
+		conn = *connConfig
+		conn.ConnectionURL = connectString(conn.ConnectionURL)
+	}
+	buf, err := json.Marshal([]interface{}{role, conn})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// jdbcURL returns the URL for the Oracle JDBC thin driver equivalent to a
+// connect string. Connect descriptors, TNS aliases and URLs with a protocol
+// such as "tcps://" follow the "@" as they are, while Easy Connect strings
+// are given the leading "//" the thin driver expects.
+func jdbcURL(connectString string) string {
+	if connectString == "" {
+		return ""
+	}
+
+	const prefix = "jdbc:oracle:thin:@"
+	if strings.HasPrefix(connectString, "(") || strings.Contains(connectString, "://") {
+		return prefix + connectString
+	}
+	connectString = strings.TrimPrefix(connectString, "//")
+	if !strings.ContainsAny(connectString, ":/") {
+		return prefix + connectString
+	}
+	return prefix + "//" + connectString
+}
+
+// isTransientError returns whether an error is one that creating credentials
+// may succeed after retrying.
+func isTransientError(err error) bool {
+	if err == driver.ErrBadConn {
+		return true
+	}
+	for _, code := range transientErrorCodes {
+		if strings.Contains(err.Error(), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// storedUsername returns a username as Oracle stores it, in DBA_USERS and
+// gv$session. Unquoted usernames are stored upper cased, while quoted ones
+// keep their case.
+func storedUsername(username string, quoted bool) string {
+	if quoted {
+		return username
+	}
+	return strings.ToUpper(username)
+}
+
+// validateUsername checks a username read back from internal data, the WAL
+// or storage before it is substituted into SQL, so that a corrupted value
+// can't change the statements it is used in. Unquoted usernames must be
+// valid identifiers. Quoted ones can't contain double quotes, which would
+// end the identifier, or NUL characters.
+func validateUsername(username string, quoted bool) error {
+	if username == "" || len(username) > oracleLongUsernameLength {
+		return fmt.Errorf("invalid username %q", username)
+	}
+	if quoted {
+		if strings.ContainsAny(username, "\"\x00") {
+			return fmt.Errorf("invalid quoted username %q", username)
+		}
+		return nil
+	}
+	if !oracleIdentifierRegex.MatchString(username) {
+		return fmt.Errorf("invalid username %q", username)
+	}
+	return nil
+}
+
+// validQualifiedName returns whether a name is an unquoted identifier,
+// optionally qualified with a schema.
+func validQualifiedName(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !oracleIdentifierRegex.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkLogin logs in as the user through the connect string, returning the
+// error if that fails.
+func checkLogin(connectString, username, password string) error {
+	db, err := sql.Open(oracleDriverName, fmt.Sprintf("%s/%s@%s", username, password, connectString))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Ping()
+}
+
+// isPasswordVerifyError returns whether an error is the database rejecting
+// a password with ORA-28003, raised when a password verify function fails.
+func isPasswordVerifyError(err error) bool {
+	return strings.Contains(err.Error(), "ORA-28003")
+}
+
+// usesTCPS returns whether a connect string connects over TLS, either as an
+// Easy Connect string with the "tcps://" protocol or as a connect descriptor
+// with a TCPS address.
+func usesTCPS(connectString string) bool {
+	s := strings.ToUpper(strings.Replace(connectString, " ", "", -1))
+	return strings.HasPrefix(s, "TCPS://") || strings.Contains(s, "(PROTOCOL=TCPS)")
+}
+
+// tnsAlias returns the net service name used for a role in the tnsnames.ora
+// snippet returned with credentials.
+func tnsAlias(roleName string) string {
+	alias := []byte("VAULT_" + strings.ToUpper(roleName))
+	for i, c := range alias {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.') {
+			alias[i] = '_'
+		}
+	}
+	return string(alias)
+}
+
+// tnsnamesEntry returns a tnsnames.ora entry for a connect string. Connect
+// descriptors are used as they are, while Easy Connect strings are turned
+// into the equivalent descriptor. The server type, instance name and any
+// parameters of an Easy Connect string are not carried over.
+func tnsnamesEntry(alias, connectString string) string {
+	if strings.HasPrefix(connectString, "(") {
+		return fmt.Sprintf("%s = %s\n", alias, connectString)
+	}
+
+	protocol, address := "TCP", connectString
+	if i := strings.Index(address, "://"); i >= 0 {
+		protocol, address = strings.ToUpper(address[:i]), address[i+3:]
+	}
+	address = strings.TrimPrefix(address, "//")
+	if i := strings.Index(address, "?"); i >= 0 {
+		address = address[:i]
+	}
+
+	var service string
+	if i := strings.Index(address, "/"); i >= 0 {
+		address, service = address[:i], address[i+1:]
+	}
+	if i := strings.IndexAny(service, ":/"); i >= 0 {
+		service = service[:i]
+	}
+
+	host, port := address, "1521"
+	if i := strings.LastIndex(address, ":"); i > strings.LastIndex(address, "]") {
+		host, port = address[:i], address[i+1:]
+	}
+	host = strings.Trim(host, "[]")
+
+	return fmt.Sprintf(tnsnamesEntryTemplate, alias, protocol, host, port, service)
+}
+
+// sqlnetConfig returns the sqlnet.ora settings for connecting over TLS with
+// the server certificate checked against the trusted certificates in the
+// wallet. Clients authenticate with their password, so no client
+// certificate is required.
+func sqlnetConfig(walletLocation string) string {
+	config := "SSL_CLIENT_AUTHENTICATION = FALSE\nSSL_SERVER_DN_MATCH = TRUE\n"
+	if walletLocation != "" {
+		config = fmt.Sprintf(sqlnetWalletTemplate, walletLocation) + config
+	}
+	return config
+}
+
+// proxyGrantThroughSQL returns the statement allowing the proxy user to
+// connect through the target schema, with only the given roles of the
+// schema enabled if there are any.
+func proxyGrantThroughSQL(targetSchema, username string, roles []string) string {
+	stmt := fmt.Sprintf("ALTER USER %s GRANT CONNECT THROUGH %s", targetSchema, username)
+	if len(roles) > 0 {
+		stmt += " WITH ROLE " + strings.Join(roles, ", ")
+	}
+	return stmt
+}
+
+// sepsSqlnetConfig returns the sqlnet.ora settings for clients to log in
+// with the credentials stored in their wallet by connecting as "/@alias".
+func sepsSqlnetConfig(walletLocation string) string {
+	config := "SQLNET.WALLET_OVERRIDE = TRUE\n"
+	if walletLocation != "" {
+		config = fmt.Sprintf(sqlnetWalletTemplate, walletLocation) + config
+	}
+	return config
+}
+
+// userExists returns whether a user with the username exists. Unquoted
+// usernames are stored upper cased.
+func userExists(tx *sql.Tx, username string, quoted bool) (bool, error) {
+	username = storedUsername(username, quoted)
+
+	var count int
+	if err := tx.QueryRow(fmt.Sprintf(userQuerySQL, quoteLiteral(username))).Scan(&count); err != nil {
+		return false, fmt.Errorf("could not check for existing user: %s", err)
+	}
+	return count > 0, nil
+}
+
+// ownedObjects returns the objects owned by a user, as their type followed
+// by their name. Unquoted usernames are stored upper cased.
+func ownedObjects(tx *sql.Tx, username string, quoted bool) ([]string, error) {
+	username = storedUsername(username, quoted)
+
+	rows, err := tx.Query(fmt.Sprintf(ownedObjectsSQL, quoteLiteral(username)))
+	if err != nil {
+		return nil, fmt.Errorf("could not check for owned objects: %s", err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
+// recyclebinTablespaces returns the tablespaces holding objects the user has
+// dropped into the recycle bin.
+func recyclebinTablespaces(tx *sql.Tx, username string, quoted bool) ([]string, error) {
+	rows, err := tx.Query(recyclebinTablespacesSQL, storedUsername(username, quoted))
+	if err != nil {
+		return nil, fmt.Errorf("could not check the recycle bin: %s", err)
+	}
+	defer rows.Close()
+
+	var tablespaces []string
+	for rows.Next() {
+		var tablespace string
+		if err := rows.Scan(&tablespace); err != nil {
+			return nil, err
+		}
+		tablespaces = append(tablespaces, tablespace)
+	}
+	return tablespaces, rows.Err()
+}
+
+// userJobs returns the scheduler jobs owned by the user that are enabled or
+// running, and which of them are running.
+func userJobs(tx *sql.Tx, username string, quoted bool) ([]string, map[string]bool, error) {
+	rows, err := tx.Query(userJobsSQL, storedUsername(username, quoted))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not look up scheduler jobs: %s", err)
+	}
+	defer rows.Close()
+
+	var jobs []string
+	running := make(map[string]bool)
+	for rows.Next() {
+		var job string
+		var isRunning int
+		if err := rows.Scan(&job, &isRunning); err != nil {
+			return nil, nil, err
+		}
+		jobs = append(jobs, job)
+		running[job] = isRunning == 1
+	}
+	return jobs, running, rows.Err()
+}
+
+// ownedObjectsError is returned when a user can't be revoked because it
+// owns objects, which DROP USER refuses to drop without CASCADE.
+type ownedObjectsError struct {
+	Username string
+	Objects  []string
+}
+
+func (e *ownedObjectsError) Error() string {
+	listed := e.Objects
+	if len(listed) > maxListedOwnedObjects {
+		listed = listed[:maxListedOwnedObjects]
+	}
+	more := ""
+	if len(e.Objects) > len(listed) {
+		more = fmt.Sprintf(" and %d more", len(e.Objects)-len(listed))
+	}
+	return fmt.Sprintf(
+		"user %s cannot be dropped because it owns %d objects: %s%s; drop them, or set revoke_cascade on the role to drop them with the user",
+		e.Username, len(e.Objects), strings.Join(listed, ", "), more)
+}
+
+// schemaExists returns whether a schema exists in the container, or in the
+// database connected to if there is none.
+func schemaExists(db *sql.DB, container, schema string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if container != "" {
+		restoreContainer, err := switchContainer(tx, container)
+		if err != nil {
+			return false, err
+		}
+		defer restoreContainer()
+	}
+	return userExists(tx, schema, false)
+}
+
+// switchContainer switches the session used by the transaction to the given
+// container (PDB). The returned function switches it back to the container
+// it was in before; it must be called before the transaction is committed,
+// since the session returns to the connection pool once the transaction is
+// done. Calling it more than once is harmless.
+func switchContainer(tx *sql.Tx, container string) (func() error, error) {
+	var original string
+	if err := tx.QueryRow(containerQuerySQL).Scan(&original); err != nil {
+		return nil, fmt.Errorf("could not determine current container: %s", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(setContainerSQL, container)); err != nil {
+		return nil, fmt.Errorf("could not switch to container %s: %s", container, err)
+	}
+
+	restored := false
+	return func() error {
+		if restored {
+			return nil
+		}
+		restored = true
+		_, err := tx.Exec(fmt.Sprintf(setContainerSQL, original))
+		return err
+	}, nil
+}
@@ -39,6 +39,10 @@ import (
 	"github.com/mitchellh/cli"
 )
 
+// optionalLogicalBackends holds the logical backends that are only built in
+// with build tags, such as those needing cgo database drivers.
+var optionalLogicalBackends = map[string]logical.Factory{}
+
 // Commands returns the mapping of CLI commands for Vault. The meta
 // parameter lets you set meta options for all commands.
 func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
@@ -62,6 +66,25 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 			}, nil
 		},
 		"server": func() (cli.Command, error) {
+			logicalBackends := map[string]logical.Factory{
+				"aws":        aws.Factory,
+				"consul":     consul.Factory,
+				"postgresql": postgresql.Factory,
+				"cassandra":  cassandra.Factory,
+				"pki":        pki.Factory,
+				"transit":    transit.Factory,
+				"mongodb":    mongodb.Factory,
+				"mssql":      mssql.Factory,
+				"mysql":      mysql.Factory,
+				"ssh":        ssh.Factory,
+				"rabbitmq":   rabbitmq.Factory,
+				"database":   database.Factory,
+				"totp":       totp.Factory,
+			}
+			for name, factory := range optionalLogicalBackends {
+				logicalBackends[name] = factory
+			}
+
 			return &command.ServerCommand{
 				Meta: *metaPtr,
 				AuditBackends: map[string]audit.Factory{
@@ -80,23 +103,9 @@ func Commands(metaPtr *meta.Meta) map[string]cli.CommandFactory {
 					"okta":     credOkta.Factory,
 					"radius":   credRadius.Factory,
 				},
-				LogicalBackends: map[string]logical.Factory{
-					"aws":        aws.Factory,
-					"consul":     consul.Factory,
-					"postgresql": postgresql.Factory,
-					"cassandra":  cassandra.Factory,
-					"pki":        pki.Factory,
-					"transit":    transit.Factory,
-					"mongodb":    mongodb.Factory,
-					"mssql":      mssql.Factory,
-					"mysql":      mysql.Factory,
-					"ssh":        ssh.Factory,
-					"rabbitmq":   rabbitmq.Factory,
-					"database":   database.Factory,
-					"totp":       totp.Factory,
-				},
-				ShutdownCh: command.MakeShutdownCh(),
-				SighupCh:   command.MakeSighupCh(),
+				LogicalBackends: logicalBackends,
+				ShutdownCh:      command.MakeShutdownCh(),
+				SighupCh:        command.MakeSighupCh(),
 			}, nil
 		},
 
@@ -0,0 +1,12 @@
+// +build oci8
+
+package cli
+
+import "github.com/hashicorp/vault/builtin/logical/oracle"
+
+// The Oracle backend is only useful with the go-oci8 driver, which needs
+// cgo and the Oracle Instant Client, so it is only registered when that is
+// linked in.
+func init() {
+	optionalLogicalBackends["oracle"] = oracle.Factory
+}